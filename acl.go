@@ -4,74 +4,560 @@ import (
 	"encoding/xml"
 	"github.com/peter-mount/go-kernel/v2/bolt"
 	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"strings"
 )
 
+// Permissions that can be granted in an ACL
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#permissions
+const (
+	PERM_FULL_CONTROL = "FULL_CONTROL"
+	PERM_READ         = "READ"
+	PERM_WRITE        = "WRITE"
+	PERM_READ_ACP     = "READ_ACP"
+	PERM_WRITE_ACP    = "WRITE_ACP"
+)
+
+// Grantee types
+const (
+	GRANTEE_USER  = "CanonicalUser"
+	GRANTEE_EMAIL = "AmazonCustomerByEmail"
+	GRANTEE_GROUP = "Group"
+)
+
+// Predefined groups
+const (
+	GROUP_ALL_USERS           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	GROUP_AUTHENTICATED_USERS = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	GROUP_LOG_DELIVERY        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+const xmlnsXSI = "http://www.w3.org/2001/XMLSchema-instance"
+
+// The ACL as stored with a bucket or object
+type ACL struct {
+	Owner  Owner
+	Grants []Grant
+}
+
+// The XML form of an ACL
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_AccessControlPolicy.html
 type AccessControlPolicy struct {
-	XMLName     xml.Name `xml:"AccessControlPolicy"`
-	Id          string   `xml:"Owner>ID"`
-	DisplayName string   `xml:"Owner>DisplayName"`
-	Grant       []Grant  `xml:"AccessControlList"`
+	XMLName xml.Name `xml:"AccessControlPolicy"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Owner   Owner    `xml:"Owner"`
+	Grants  []Grant  `xml:"AccessControlList>Grant"`
+}
+
+type Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName,omitempty"`
 }
 
 type Grant struct {
-	XMLName     xml.Name `xml:"Grant"`
-	Id          string   `xml:"Grantee>ID"`
-	DisplayName string   `xml:"Grantee>DisplayName"`
-	Permission  string   `xml:"Permission"`
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
 }
 
-// getAcl is a stub until we support this call.
-// It's required as some clients issue this request and currently we respond
-// with the entire object which breaks the client.
-// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectGETacl.html
-func (s *ObjectStore) getObjectAcl(r *rest.Rest) error {
+type Grantee struct {
+	// One of GRANTEE_USER, GRANTEE_EMAIL or GRANTEE_GROUP
+	Type         string `xml:"-"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+}
+
+// granteeXML is used to marshal a Grantee as encoding/xml cannot handle the
+// xsi:type attribute directly
+type granteeXML struct {
+	Xmlns        string `xml:"xmlns:xsi,attr"`
+	Type         string `xml:"xsi:type,attr"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+}
+
+func (g Grantee) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(&granteeXML{
+		Xmlns:        xmlnsXSI,
+		Type:         g.Type,
+		ID:           g.ID,
+		DisplayName:  g.DisplayName,
+		EmailAddress: g.EmailAddress,
+		URI:          g.URI,
+	}, start)
+}
+
+func (g *Grantee) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	v := &granteeXML{}
+	err := d.DecodeElement(v, &start)
+	if err != nil {
+		return err
+	}
+
+	*g = Grantee{
+		ID:           v.ID,
+		DisplayName:  v.DisplayName,
+		EmailAddress: v.EmailAddress,
+		URI:          v.URI,
+	}
+
+	// The decoder resolves the xsi prefix so match on the local name
+	for _, a := range start.Attr {
+		if a.Name.Local == "type" {
+			g.Type = a.Value
+		}
+	}
+
+	return nil
+}
+
+// newOwner returns the Owner for a credential
+func newOwner(cred *auth.Credential) Owner {
+	return Owner{
+		ID:          cred.CanonicalId(),
+		DisplayName: cred.DisplayName(),
+	}
+}
+
+func userGrant(owner Owner, permission string) Grant {
+	return Grant{
+		Grantee: Grantee{
+			Type:        GRANTEE_USER,
+			ID:          owner.ID,
+			DisplayName: owner.DisplayName,
+		},
+		Permission: permission,
+	}
+}
+
+func groupGrant(uri, permission string) Grant {
+	return Grant{
+		Grantee: Grantee{
+			Type: GRANTEE_GROUP,
+			URI:  uri,
+		},
+		Permission: permission,
+	}
+}
+
+// privateACL returns an ACL where only the owner has access
+func privateACL(owner Owner) *ACL {
+	return &ACL{
+		Owner:  owner,
+		Grants: []Grant{userGrant(owner, PERM_FULL_CONTROL)},
+	}
+}
+
+// cannedACL returns the ACL for a canned ACL name.
+// bucketOwner is the owner of the bucket, used by the bucket-owner-* acl's
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#canned-acl
+func cannedACL(name string, owner, bucketOwner Owner) (*ACL, error) {
+	acl := privateACL(owner)
+
+	switch name {
+	case "private", "aws-exec-read":
+	case "public-read":
+		acl.Grants = append(acl.Grants, groupGrant(GROUP_ALL_USERS, PERM_READ))
+	case "public-read-write":
+		acl.Grants = append(acl.Grants,
+			groupGrant(GROUP_ALL_USERS, PERM_READ),
+			groupGrant(GROUP_ALL_USERS, PERM_WRITE))
+	case "authenticated-read":
+		acl.Grants = append(acl.Grants, groupGrant(GROUP_AUTHENTICATED_USERS, PERM_READ))
+	case "bucket-owner-read":
+		if bucketOwner.ID != owner.ID {
+			acl.Grants = append(acl.Grants, userGrant(bucketOwner, PERM_READ))
+		}
+	case "bucket-owner-full-control":
+		if bucketOwner.ID != owner.ID {
+			acl.Grants = append(acl.Grants, userGrant(bucketOwner, PERM_FULL_CONTROL))
+		}
+	case "log-delivery-write":
+		acl.Grants = append(acl.Grants,
+			groupGrant(GROUP_LOG_DELIVERY, PERM_WRITE),
+			groupGrant(GROUP_LOG_DELIVERY, PERM_READ_ACP))
+	default:
+		return nil, awserror.InvalidArgument("Invalid canned ACL %q", name)
+	}
+
+	return acl, nil
+}
+
+// grantHeaders maps the x-amz-grant-* headers to the permission they grant
+var grantHeaders = map[string]string{
+	"X-Amz-Grant-Read":         PERM_READ,
+	"X-Amz-Grant-Write":        PERM_WRITE,
+	"X-Amz-Grant-Read-Acp":     PERM_READ_ACP,
+	"X-Amz-Grant-Write-Acp":    PERM_WRITE_ACP,
+	"X-Amz-Grant-Full-Control": PERM_FULL_CONTROL,
+}
+
+// parseGrantHeader parses the value of an x-amz-grant-* header.
+// This is a comma separated list of type="value" pairs, e.g.
+// id="1234", uri="http://acs.amazonaws.com/groups/global/AllUsers", emailAddress="a@b.com"
+func parseGrantHeader(v, permission string) ([]Grant, error) {
+	var grants []Grant
+	for _, e := range strings.Split(v, ",") {
+		kv := strings.SplitN(strings.TrimSpace(e), "=", 2)
+		if len(kv) != 2 {
+			return nil, awserror.InvalidArgument("Invalid grant %q", e)
+		}
+
+		value := strings.Trim(strings.TrimSpace(kv[1]), "\"")
+		g := Grant{Permission: permission}
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "id":
+			g.Grantee = Grantee{Type: GRANTEE_USER, ID: value}
+		case "uri":
+			g.Grantee = Grantee{Type: GRANTEE_GROUP, URI: value}
+		case "emailaddress":
+			g.Grantee = Grantee{Type: GRANTEE_EMAIL, EmailAddress: value}
+		default:
+			return nil, awserror.InvalidArgument("Invalid grantee type %q", kv[0])
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+// aclFromHeaders creates an ACL from either the x-amz-acl or x-amz-grant-* headers.
+// If neither are present then the private canned ACL is returned.
+func aclFromHeaders(headers map[string][]string, owner, bucketOwner Owner) (*ACL, error) {
+	var grants []Grant
+	for h, permission := range grantHeaders {
+		if v, ok := headers[h]; ok && len(v) > 0 {
+			g, err := parseGrantHeader(v[0], permission)
+			if err != nil {
+				return nil, err
+			}
+			grants = append(grants, g...)
+		}
+	}
+
+	canned, hasCanned := headers["X-Amz-Acl"]
+	if hasCanned && len(grants) > 0 {
+		return nil, awserror.InvalidRequest("Specifying both Canned ACLs and Header Grants is not allowed")
+	}
+
+	if len(grants) > 0 {
+		acl := &ACL{Owner: owner, Grants: grants}
+		return acl, acl.validate()
+	}
+
+	if hasCanned && len(canned) > 0 {
+		return cannedACL(canned[0], owner, bucketOwner)
+	}
+
+	return privateACL(owner), nil
+}
+
+// validate checks that the grants within an ACL are valid.
+// Grants by email address are rejected as they cannot be resolved to a user.
+func (a *ACL) validate() error {
+	for _, g := range a.Grants {
+		switch g.Permission {
+		case PERM_FULL_CONTROL, PERM_READ, PERM_WRITE, PERM_READ_ACP, PERM_WRITE_ACP:
+		default:
+			return awserror.MalformedACLError()
+		}
+
+		switch g.Grantee.Type {
+		case GRANTEE_USER:
+			if g.Grantee.ID == "" {
+				return awserror.MalformedACLError()
+			}
+		case GRANTEE_EMAIL:
+			// Users don't have email addresses so the grant could never apply
+			return awserror.UnresolvableGrantByEmailAddress()
+		case GRANTEE_GROUP:
+			if g.Grantee.URI != GROUP_ALL_USERS && g.Grantee.URI != GROUP_AUTHENTICATED_USERS && g.Grantee.URI != GROUP_LOG_DELIVERY {
+				return awserror.InvalidArgument("Invalid group uri %q", g.Grantee.URI)
+			}
+		default:
+			return awserror.MalformedACLError()
+		}
+	}
+	return nil
+}
+
+// policy returns the XML representation of the ACL
+func (a *ACL) policy() *AccessControlPolicy {
+	return &AccessControlPolicy{
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Owner:  a.Owner,
+		Grants: a.Grants,
+	}
+}
+
+// isOwner returns true if the credential owns the resource
+func (a *ACL) isOwner(cred *auth.Credential) bool {
+	return cred.IsAuthenticated() && a.Owner.ID == cred.CanonicalId()
+}
+
+// visibleTo returns true if the credential owns the resource or has been
+// granted a permission on it directly
+func (a *ACL) visibleTo(cred *auth.Credential) bool {
+	if a.isOwner(cred) {
+		return true
+	}
+
+	for _, g := range a.Grants {
+		if g.Grantee.Type == GRANTEE_USER && g.Grantee.matches(cred) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if the grantee includes the credential
+func (g *Grantee) matches(cred *auth.Credential) bool {
+	switch g.Type {
+	case GRANTEE_USER:
+		return cred.IsAuthenticated() && g.ID == cred.CanonicalId()
+	case GRANTEE_GROUP:
+		return g.URI == GROUP_ALL_USERS || (g.URI == GROUP_AUTHENTICATED_USERS && cred.IsAuthenticated())
+	default:
+		return false
+	}
+}
+
+// Allows returns true if the ACL grants the permission to the credential.
+// The owner is always allowed to read and write the ACL itself.
+func (a *ACL) Allows(cred *auth.Credential, permission string) bool {
+	if a.isOwner(cred) && (permission == PERM_READ_ACP || permission == PERM_WRITE_ACP) {
+		return true
+	}
+
+	for _, g := range a.Grants {
+		if (g.Permission == permission || g.Permission == PERM_FULL_CONTROL) && g.Grantee.matches(cred) {
+			return true
+		}
+	}
+	return false
+}
+
+// aclRequest is the new ACL sent with a PutBucketAcl or PutObjectAcl request.
+// It is read before the bucket is updated so a slow client does not hold the
+// write transaction whilst it sends the body.
+type aclRequest struct {
+	headers map[string][]string
+	// The ACL in the body, nil if it's in the headers
+	policy *AccessControlPolicy
+}
+
+// readACL reads an AccessControlPolicy from the request.
+// If the request has an x-amz-acl or x-amz-grant-* header then that is used
+// instead of the body
+func (s *ObjectStore) readACL(r *rest.Rest) (*aclRequest, error) {
+	headers := r.Request().Header
+	req := &aclRequest{headers: headers}
+
+	hasHeaders := false
+	if _, ok := headers["X-Amz-Acl"]; ok {
+		hasHeaders = true
+	}
+	for h := range grantHeaders {
+		if _, ok := headers[h]; ok {
+			hasHeaders = true
+		}
+	}
+	if hasHeaders {
+		// Reject invalid headers now, the owners are only known when applied
+		_, err := aclFromHeaders(headers, Owner{}, Owner{})
+		return req, err
+	}
+
+	reader, err := r.BodyReader()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.getBody(headers, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &AccessControlPolicy{}
+	err = xml.Unmarshal(body, policy)
+	if err != nil {
+		return nil, awserror.MalformedACLError()
+	}
+
+	if err := (&ACL{Grants: policy.Grants}).validate(); err != nil {
+		return nil, err
+	}
+
+	req.policy = policy
+	return req, nil
+}
+
+// apply returns the new ACL replacing current
+func (a *aclRequest) apply(current *ACL, bucketOwner Owner) (*ACL, error) {
+	if a.policy == nil {
+		return aclFromHeaders(a.headers, current.Owner, bucketOwner)
+	}
+
+	// The owner cannot be changed
+	if a.policy.Owner.ID != current.Owner.ID {
+		return nil, awserror.AccessDenied()
+	}
+
+	return &ACL{Owner: current.Owner, Grants: a.policy.Grants}, nil
+}
+
+// bucketOwner returns the owner of a bucket.
+// Buckets created before we recorded ownership are owned by root.
+func (s *ObjectStore) bucketOwner(meta *BucketMeta) Owner {
+	if meta.ACL != nil {
+		return meta.ACL.Owner
+	}
+	return newOwner(s.authService.RootCredential())
+}
+
+// bucketACL returns the ACL for a bucket
+func (s *ObjectStore) bucketACL(meta *BucketMeta) *ACL {
+	if meta.ACL != nil {
+		return meta.ACL
+	}
+	return privateACL(s.bucketOwner(meta))
+}
+
+// objectACL returns the ACL for an object.
+// Objects created before we recorded ACL's are owned by the bucket owner.
+func (s *ObjectStore) objectACL(meta *BucketMeta, obj *Object) *ACL {
+	if obj.ACL != nil {
+		return obj.ACL
+	}
+	return privateACL(s.bucketOwner(meta))
+}
+
+// getBucketAcl returns the ACL of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketAcl.html
+func (s *ObjectStore) getBucketAcl(r *rest.Rest) error {
 	bucketName := r.Var("BucketName")
-	objectName := r.Var("ObjectName")
 
-	obj := &Object{}
+	var acl *ACL
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		acl = s.bucketACL(meta)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200).
+		XML().
+		Value(acl.policy())
+
+	return nil
+}
+
+// putBucketAcl replaces the ACL of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAcl.html
+func (s *ObjectStore) putBucketAcl(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
 
-	err := s.boltService.Update(func(tx *bolt.Tx) error {
-		b, err := s.getBucket(tx, bucketName)
+	req, err := s.readACL(r)
+	if err != nil {
+		return err
+	}
+
+	err = s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		acl, err := req.apply(s.bucketACL(meta), s.bucketOwner(meta))
 		if err != nil {
 			return err
 		}
 
-		return obj.get(b, objectName)
+		meta.ACL = acl
+		return nil
 	})
 	if err != nil {
 		return err
 	}
 
-	// Dummy data
-	acl := &AccessControlPolicy{
-		Id:          "75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a",
-		DisplayName: "mtd@amazon.com",
-		Grant: []Grant{
-			Grant{
-				Id:          "75aa57f09aa0c8caeab4f8c24e99d10f8e7faeebf76c078efc7c6caea54ba06a",
-				DisplayName: "mtd@amazon.com",
-				Permission:  "FULL_CONTROL",
-			},
-		},
+	r.Status(200)
+
+	return nil
+}
+
+// getObjectAcl returns the ACL of an object
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectAcl.html
+func (s *ObjectStore) getObjectAcl(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+	objectName := r.Var("ObjectName")
+
+	var acl *ACL
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		b, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		obj := &Object{}
+		err = obj.get(b, objectName)
+		if err != nil {
+			return err
+		}
+
+		acl = s.objectACL(meta, obj)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	r.Status(200).
-		AddHeader("x-amz-id-2", "LriYPLdmOdAiIfgSm/F1YsViT1LW94/xUQxMsF7xiEb1a0wiIOIxl+zbwZ163pt7").
-		AddHeader("x-amz-request-id", "0A49CE4060975EAC").
-		AddHeader("Content-Length", "0").
 		XML().
-		Value(acl)
+		Value(acl.policy())
 
 	return nil
 }
 
-// putObjectAcl dummy implementation to stop it interfeering with uploadObject
-// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPUTacl.html
+// putObjectAcl replaces the ACL of an object
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectAcl.html
 func (s *ObjectStore) putObjectAcl(r *rest.Rest) error {
-	r.Status(200).
-		AddHeader("x-amz-id-2", "LriYPLdmOdAiIfgSm/F1YsViT1LW94/xUQxMsF7xiEb1a0wiIOIxl+zbwZ163pt7").
-		AddHeader("x-amz-request-id", "0A49CE4060975EAC").
-		AddHeader("Content-Length", "0")
+	bucketName := r.Var("BucketName")
+	objectName := r.Var("ObjectName")
+
+	req, err := s.readACL(r)
+	if err != nil {
+		return err
+	}
+
+	err = s.boltService.Update(func(tx *bolt.Tx) error {
+		b, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		obj := &Object{}
+		err = obj.get(b, objectName)
+		if err != nil {
+			return err
+		}
+
+		acl, err := req.apply(s.objectACL(meta, obj), s.bucketOwner(meta))
+		if err != nil {
+			return err
+		}
+
+		obj.ACL = acl
+		return obj.put(b)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200)
 
 	return nil
 }
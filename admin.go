@@ -0,0 +1,423 @@
+package objectstore
+
+import (
+	"encoding/json"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"github.com/peter-mount/objectstore/utils"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// The prefix of the admin api. "_admin" is not a valid bucket name.
+const adminPrefix = "/_admin"
+
+// AdminUser is a user as returned by the admin api
+type AdminUser struct {
+	Name       string            `json:"name"`
+	Arn        string            `json:"arn,omitempty"`
+	AccessKeys []*AdminAccessKey `json:"accessKeys"`
+	Disabled   bool              `json:"disabled"`
+	Groups     []string          `json:"groups,omitempty"`
+	Policies   []string          `json:"policies,omitempty"`
+	Quota      *auth.Quota       `json:"quota,omitempty"`
+}
+
+// AdminAccessKey is an access key as returned by the admin api.
+// The SecretKey is only returned when the key is created.
+type AdminAccessKey struct {
+	AccessKey string     `json:"accessKey"`
+	SecretKey string     `json:"secretKey,omitempty"`
+	Status    string     `json:"status"`
+	Created   *time.Time `json:"created,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+}
+
+// AdminBucket is a bucket as returned by the admin api
+type AdminBucket struct {
+	Name             string            `json:"name"`
+	Owner            string            `json:"owner"`
+	OwnerDisplayName string            `json:"ownerDisplayName,omitempty"`
+	Region           string            `json:"region"`
+	Created          *time.Time        `json:"created,omitempty"`
+	Tags             map[string]string `json:"tags,omitempty"`
+}
+
+// AdminError is the json error returned by the admin api
+type AdminError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func newAdminUser(u *auth.User, withSecret bool) *AdminUser {
+	return &AdminUser{
+		Name:       u.Name,
+		Arn:        u.Arn.String(),
+		AccessKeys: newAdminAccessKeys(u, withSecret),
+		Disabled:   u.Disabled,
+		Groups:     u.Groups,
+		Policies:   u.Policies,
+		Quota:      u.Quota,
+	}
+}
+
+func newAdminAccessKeys(u *auth.User, withSecret bool) []*AdminAccessKey {
+	keys := []*AdminAccessKey{}
+	for i := range u.AccessKeys {
+		keys = append(keys, newAdminAccessKey(&u.AccessKeys[i], withSecret))
+	}
+	return keys
+}
+
+func newAdminAccessKey(k *auth.AccessKey, withSecret bool) *AdminAccessKey {
+	a := &AdminAccessKey{
+		AccessKey: k.AccessKey,
+		Status:    k.Status,
+		Created:   k.Created,
+		Expires:   k.Expires,
+	}
+	if withSecret {
+		a.SecretKey = k.SecretKey
+	}
+	if t := k.LastUsed(); !t.IsZero() {
+		a.LastUsed = &t
+	}
+	return a
+}
+
+// adminRoutes registers the admin api. These must be registered before the
+// S3 routes as they would otherwise match the admin paths.
+func (s *ObjectStore) adminRoutes() {
+	s.restService.RestBuilder().
+		Decorate(s.adminDecorator).
+		// List buckets
+		Method("GET").
+		Path(adminPrefix + "/buckets").
+		Handler(s.adminListBuckets).
+		Build().
+		// List users
+		Method("GET").
+		Path(adminPrefix + "/users").
+		Handler(s.adminListUsers).
+		Build().
+		// Create user
+		Method("POST").
+		Path(adminPrefix + "/users").
+		Handler(s.adminCreateUser).
+		Build().
+		// Get user
+		Method("GET").
+		Path(adminPrefix + "/users/{UserName}").
+		Handler(s.adminGetUser).
+		Build().
+		// Delete user
+		Method("DELETE").
+		Path(adminPrefix + "/users/{UserName}").
+		Handler(s.adminDeleteUser).
+		Build().
+		// Disable user
+		Method("POST").
+		Path(adminPrefix + "/users/{UserName}/disable").
+		Handler(s.adminSetUserDisabled(true)).
+		Build().
+		// Enable user
+		Method("POST").
+		Path(adminPrefix + "/users/{UserName}/enable").
+		Handler(s.adminSetUserDisabled(false)).
+		Build().
+		// List the user's access keys
+		Method("GET").
+		Path(adminPrefix + "/users/{UserName}/keys").
+		Handler(s.adminListAccessKeys).
+		Build().
+		// Create an access key
+		Method("POST").
+		Path(adminPrefix + "/users/{UserName}/keys").
+		Handler(s.adminCreateAccessKey).
+		Build().
+		// Delete an access key
+		Method("DELETE").
+		Path(adminPrefix + "/users/{UserName}/keys/{AccessKeyId}").
+		Handler(s.adminDeleteAccessKey).
+		Build().
+		// Deactivate an access key
+		Method("POST").
+		Path(adminPrefix + "/users/{UserName}/keys/{AccessKeyId}/disable").
+		Handler(s.adminSetAccessKeyStatus(auth.AccessKeyInactive)).
+		Build().
+		// Activate an access key
+		Method("POST").
+		Path(adminPrefix + "/users/{UserName}/keys/{AccessKeyId}/enable").
+		Handler(s.adminSetAccessKeyStatus(auth.AccessKeyActive)).
+		Build()
+}
+
+// adminDecorator authenticates the request, only allowing root to call the
+// admin api, & returns errors as json
+func (s *ObjectStore) adminDecorator(h rest.RestHandler) rest.RestHandler {
+	return func(r *rest.Rest) error {
+		cred, err := s.authService.GetCredential(r)
+		if err == nil {
+			err = awserror.AccessDenied()
+			if cred.IsRoot() {
+				r.SetAttribute(auth.AUTH_KEY, cred)
+				err = h(r)
+			}
+		}
+
+		if err != nil {
+			e := adminError(err)
+			if e.Status == http.StatusInternalServerError {
+				log.Println(err)
+			}
+			r.Status(e.Status).
+				JSON().
+				Value(e)
+		}
+
+		return nil
+	}
+}
+
+// adminError converts an error into the error returned by the admin api
+func adminError(err error) *AdminError {
+	switch err {
+	case auth.ErrNoSuchUser:
+		return &AdminError{http.StatusNotFound, "NoSuchUser", err.Error()}
+	case auth.ErrUserExists:
+		return &AdminError{http.StatusConflict, "UserAlreadyExists", err.Error()}
+	case auth.ErrNoSuchAccessKey:
+		return &AdminError{http.StatusNotFound, "NoSuchAccessKey", err.Error()}
+	case auth.ErrTooManyAccessKeys:
+		return &AdminError{http.StatusConflict, "LimitExceeded", err.Error()}
+	}
+
+	if e, ok := err.(*awserror.Error); ok {
+		return &AdminError{e.Status, e.Code, e.Message}
+	}
+
+	e := awserror.InternalError()
+	return &AdminError{e.Status, e.Code, e.Message}
+}
+
+// adminListBuckets lists every bucket with it's owner, region & tags
+func (s *ObjectStore) adminListBuckets(r *rest.Rest) error {
+	result := []*AdminBucket{}
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name string, b *bolt.Bucket) error {
+			if isInternalBucket(name) {
+				return nil
+			}
+
+			meta := &BucketMeta{}
+			if err := meta.get(b); err != nil {
+				return err
+			}
+
+			owner := s.bucketOwner(meta)
+			bucket := &AdminBucket{
+				Name:             name,
+				Owner:            owner.ID,
+				OwnerDisplayName: owner.DisplayName,
+				Region:           s.bucketRegion(meta),
+				Tags:             meta.Tags,
+			}
+
+			// Buckets created before we kept metadata have no creation date
+			if !meta.CreationDate.IsZero() {
+				bucket.Created = &meta.CreationDate
+			}
+
+			result = append(result, bucket)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200).
+		JSON().
+		Value(result)
+
+	return nil
+}
+
+func (s *ObjectStore) adminListUsers(r *rest.Rest) error {
+	users, err := s.authService.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	result := []*AdminUser{}
+	for _, u := range users {
+		result = append(result, newAdminUser(u, false))
+	}
+
+	r.Status(200).
+		JSON().
+		Value(result)
+
+	return nil
+}
+
+func (s *ObjectStore) adminCreateUser(r *rest.Rest) error {
+	reader, err := r.BodyReader()
+	if err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	req := &AdminUser{}
+	err = json.Unmarshal(body, req)
+	if err != nil {
+		return awserror.InvalidArgument("Invalid request: %s", err.Error())
+	}
+
+	user := &auth.User{
+		Name:     req.Name,
+		Groups:   req.Groups,
+		Policies: req.Policies,
+		Quota:    req.Quota,
+	}
+
+	if req.Arn != "" {
+		arn, err := utils.ParseARN(req.Arn)
+		if err != nil {
+			return awserror.InvalidArgument("Invalid arn: %s", err.Error())
+		}
+		user.Arn = *arn
+	}
+
+	user, err = s.authService.CreateUser(user)
+	if err != nil {
+		return err
+	}
+
+	r.Status(http.StatusCreated).
+		JSON().
+		Value(newAdminUser(user, true))
+
+	return nil
+}
+
+func (s *ObjectStore) adminGetUser(r *rest.Rest) error {
+	user, err := s.authService.GetUser(r.Var("UserName"))
+	if err != nil {
+		return err
+	}
+
+	r.Status(200).
+		JSON().
+		Value(newAdminUser(user, false))
+
+	return nil
+}
+
+func (s *ObjectStore) adminDeleteUser(r *rest.Rest) error {
+	err := s.authService.DeleteUser(r.Var("UserName"))
+	if err != nil {
+		return err
+	}
+
+	r.Status(http.StatusNoContent)
+
+	return nil
+}
+
+func (s *ObjectStore) adminSetUserDisabled(disabled bool) rest.RestHandler {
+	return func(r *rest.Rest) error {
+		user, err := s.authService.SetUserDisabled(r.Var("UserName"), disabled)
+		if err != nil {
+			return err
+		}
+
+		r.Status(200).
+			JSON().
+			Value(newAdminUser(user, false))
+
+		return nil
+	}
+}
+
+func (s *ObjectStore) adminListAccessKeys(r *rest.Rest) error {
+	user, err := s.authService.GetUser(r.Var("UserName"))
+	if err != nil {
+		return err
+	}
+
+	r.Status(200).
+		JSON().
+		Value(newAdminAccessKeys(user, false))
+
+	return nil
+}
+
+// adminCreateAccessKey creates a new access key. The optional body sets when
+// it expires, e.g. {"expires":"2030-01-01T00:00:00Z"}
+func (s *ObjectStore) adminCreateAccessKey(r *rest.Rest) error {
+	reader, err := r.BodyReader()
+	if err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	req := &AdminAccessKey{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, req)
+		if err != nil {
+			return awserror.InvalidArgument("Invalid request: %s", err.Error())
+		}
+	}
+
+	key, err := s.authService.CreateAccessKey(r.Var("UserName"), req.Expires)
+	if err != nil {
+		return err
+	}
+
+	r.Status(http.StatusCreated).
+		JSON().
+		Value(newAdminAccessKey(key, true))
+
+	return nil
+}
+
+func (s *ObjectStore) adminDeleteAccessKey(r *rest.Rest) error {
+	err := s.authService.DeleteAccessKey(r.Var("UserName"), r.Var("AccessKeyId"))
+	if err != nil {
+		return err
+	}
+
+	r.Status(http.StatusNoContent)
+
+	return nil
+}
+
+func (s *ObjectStore) adminSetAccessKeyStatus(status string) rest.RestHandler {
+	return func(r *rest.Rest) error {
+		key, err := s.authService.SetAccessKeyStatus(r.Var("UserName"), r.Var("AccessKeyId"), status)
+		if err != nil {
+			return err
+		}
+
+		r.Status(200).
+			JSON().
+			Value(newAdminAccessKey(key, false))
+
+		return nil
+	}
+}
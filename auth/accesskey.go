@@ -0,0 +1,185 @@
+package auth
+
+import (
+  "fmt"
+  "github.com/peter-mount/go-kernel/v2/bolt"
+  "log"
+  "sync"
+  "time"
+)
+
+// The status of an access key
+const (
+  AccessKeyActive   = "Active"
+  AccessKeyInactive = "Inactive"
+)
+
+// The most access keys a user can have, enough to rotate them without downtime
+const maxAccessKeys = 2
+
+// The bbolt bucket holding when access keys were last used, keyed by access key
+const lastUsedBucket = "\001lastused"
+
+// How often the times access keys were last used are stored
+const lastUsedInterval = time.Minute
+
+// An access key of a user. A user can have several so they can be rotated
+type AccessKey struct {
+  AccessKey   string      `json:"accessKey" yaml:"accessKey"`
+  SecretKey   string      `json:"secretKey" yaml:"secretKey"`
+  // Active or Inactive, defaults to Active. Inactive keys cannot authenticate
+  Status      string      `json:"status" yaml:"status"`
+  // When the key was created
+  Created     *time.Time  `json:"created,omitempty" yaml:"created"`
+  // When the key expires, never if not set
+  Expires     *time.Time  `json:"expires,omitempty" yaml:"expires"`
+  // When the key was last used
+  lastUsed    time.Time
+}
+
+// IsActive returns true if the key can be used at a time
+func (k *AccessKey) IsActive( now time.Time ) bool {
+  return k.Status == AccessKeyActive && (k.Expires == nil || now.Before( *k.Expires ))
+}
+
+// LastUsed returns when the key was last used, zero if never or not known
+func (k *AccessKey) LastUsed() time.Time {
+  return k.lastUsed
+}
+
+// validateStatus checks the status of a key, defaulting it to Active
+func (k *AccessKey) validateStatus() error {
+  switch k.Status {
+    case "":
+      k.Status = AccessKeyActive
+    case AccessKeyActive, AccessKeyInactive:
+    default:
+      return fmt.Errorf( "invalid status %q, must be %s or %s", k.Status, AccessKeyActive, AccessKeyInactive )
+  }
+  return nil
+}
+
+// withKey returns a copy of the user authenticating with one of their access
+// keys, or nil if the user is disabled or the key is not active
+func (u *User) withKey( accessKey string ) *User {
+  if u == nil || u.Disabled {
+    return nil
+  }
+
+  for _, k := range u.AccessKeys {
+    if k.AccessKey == accessKey {
+      if !k.IsActive( time.Now() ) {
+        return nil
+      }
+      user := *u
+      user.AccessKey = k.AccessKey
+      user.SecretKey = k.SecretKey
+      return &user
+    }
+  }
+
+  return nil
+}
+
+// When access keys were used since the times were last stored
+type keysUsed struct {
+  mutex   sync.Mutex
+  pending map[string]time.Time
+}
+
+// keyUsed records an access key was used. So requests don't write to the
+// database the time is kept in memory until storeKeysUsed is next called.
+func (s *AuthService) keyUsed( accessKey string ) {
+  now := time.Now().UTC()
+
+  s.keysUsed.mutex.Lock()
+  defer s.keysUsed.mutex.Unlock()
+
+  if s.keysUsed.pending == nil {
+    s.keysUsed.pending = make( map[string]time.Time )
+  }
+  s.keysUsed.pending[ accessKey ] = now
+}
+
+// storeKeysUsed stores when access keys were used since it was last called in
+// a single transaction. Keys deleted in the meantime are ignored.
+func (s *AuthService) storeKeysUsed() {
+  s.keysUsed.mutex.Lock()
+  pending := s.keysUsed.pending
+  s.keysUsed.pending = nil
+  s.keysUsed.mutex.Unlock()
+
+  if len( pending ) == 0 {
+    return
+  }
+
+  err := s.boltService.Update( func( tx *bolt.Tx ) error {
+    b, err := tx.CreateBucketIfNotExists( lastUsedBucket )
+    if err != nil {
+      return err
+    }
+
+    for accessKey, t := range pending {
+      if s.accessKeyInUse( tx, accessKey ) {
+        if err := b.Put( accessKey, []byte( t.Format( time.RFC3339 ) ) ); err != nil {
+          return err
+        }
+      }
+    }
+    return nil
+  } )
+  if err != nil {
+    log.Printf( "Failed to record when %d access keys were used: %v", len( pending ), err )
+  }
+}
+
+// startKeysUsed stores when access keys were used every lastUsedInterval.
+// The returned function stops it once any remaining times are stored.
+func (s *AuthService) startKeysUsed() func() {
+  stop := make( chan struct{} )
+  stopped := make( chan struct{} )
+  ticker := time.NewTicker( lastUsedInterval )
+
+  go func() {
+    defer close( stopped )
+    defer ticker.Stop()
+
+    for {
+      select {
+        case <-stop:
+          s.storeKeysUsed()
+          return
+        case <-ticker.C:
+          s.storeKeysUsed()
+      }
+    }
+  }()
+
+  return func() {
+    close( stop )
+    <-stopped
+  }
+}
+
+// keysLastUsed sets when each of a user's access keys was last used,
+// including uses not yet stored
+func (s *AuthService) keysLastUsed( tx *bolt.Tx, user *User ) {
+  if b := tx.Bucket( lastUsedBucket ); b != nil {
+    for i, k := range user.AccessKeys {
+      if v := b.Get( k.AccessKey ); v != nil {
+        if t, err := time.Parse( time.RFC3339, string( v ) ); err == nil {
+          user.AccessKeys[i].lastUsed = t
+        }
+      }
+    }
+  }
+
+  s.keysUsed.mutex.Lock()
+  defer s.keysUsed.mutex.Unlock()
+
+  for i, k := range user.AccessKeys {
+    if t, exists := s.keysUsed.pending[ k.AccessKey ]; exists {
+      user.AccessKeys[i].lastUsed = t
+    }
+  }
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+  "testing"
+  "time"
+)
+
+func TestAccessKey_IsActive( t *testing.T ) {
+  now := time.Date( 2026, 10, 19, 12, 0, 0, 0, time.UTC )
+  past := now.Add( -time.Second )
+  future := now.Add( time.Second )
+
+  for i, test := range []struct {
+    status    string
+    expires   *time.Time
+    expected  bool
+  }{
+    {AccessKeyActive, nil, true},
+    {AccessKeyActive, &future, true},
+    // Expired keys cannot be used
+    {AccessKeyActive, &past, false},
+    {AccessKeyActive, &now, false},
+    {AccessKeyInactive, nil, false},
+    {AccessKeyInactive, &future, false},
+    // An unset status is only defaulted to Active by validateStatus
+    {"", nil, false},
+  } {
+    k := &AccessKey{Status: test.status, Expires: test.expires}
+    if got := k.IsActive( now ); got != test.expected {
+      t.Errorf( "%d: Expected %v got %v", i, test.expected, got )
+    }
+  }
+}
+
+func TestAccessKey_validateStatus( t *testing.T ) {
+  for i, test := range []struct {
+    status    string
+    expected  string
+    valid     bool
+  }{
+    // Keys default to Active
+    {"", AccessKeyActive, true},
+    {AccessKeyActive, AccessKeyActive, true},
+    {AccessKeyInactive, AccessKeyInactive, true},
+    // Status is case sensitive
+    {"active", "active", false},
+    {"Disabled", "Disabled", false},
+  } {
+    k := &AccessKey{Status: test.status}
+    err := k.validateStatus()
+    if (err == nil) != test.valid || k.Status != test.expected {
+      t.Errorf( "%d: Expected %q valid %v got %q %v", i, test.expected, test.valid, k.Status, err )
+    }
+  }
+}
+
+func TestUser_withKey( t *testing.T ) {
+  past := time.Now().Add( -time.Hour )
+
+  user := &User{
+    Name: "ci",
+    AccessKeys: []AccessKey{
+      {AccessKey: "KEY1", SecretKey: "secret1", Status: AccessKeyActive},
+      {AccessKey: "KEY2", SecretKey: "secret2", Status: AccessKeyInactive},
+      {AccessKey: "KEY3", SecretKey: "secret3", Status: AccessKeyActive, Expires: &past},
+    },
+  }
+
+  if u := user.withKey( "KEY1" ); u == nil || u.AccessKey != "KEY1" || u.SecretKey != "secret1" {
+    t.Errorf( "Expected KEY1 got %v", u )
+  }
+
+  for _, k := range []string{"KEY2", "KEY3", "KEY4"} {
+    if u := user.withKey( k ); u != nil {
+      t.Errorf( "Expected %s to be rejected got %v", k, u )
+    }
+  }
+
+  user.Disabled = true
+  if u := user.withKey( "KEY1" ); u != nil {
+    t.Errorf( "Expected disabled user to be rejected got %v", u )
+  }
+}
@@ -19,22 +19,42 @@ func (s *AuthService) AuthenticatorDecorator(h rest.RestHandler) rest.RestHandle
 			return err
 		}
 
-		if s.config.Auth.Debug {
+		if s.config().Auth.Debug {
 			log.Println(cred)
 		}
 
+		if cred.IsAuthenticated() && !cred.IsSession() {
+			s.keyUsed(cred.AccessKey())
+		}
+
 		r.SetAttribute(AUTH_KEY, cred)
 		return h(r)
 	}
 }
 
+// RequestCredential returns the Credential resolved by AuthenticatorDecorator
+// for a request. If none is present then a deny credential is returned.
+func RequestCredential(r *rest.Rest) *Credential {
+	if v, ok := r.GetAttribute(AUTH_KEY); ok {
+		if cred, ok := v.(*Credential); ok {
+			return cred
+		}
+	}
+	return denyCredential()
+}
+
+// RootCredential returns the Credential of the root user
+func (s *AuthService) RootCredential() *Credential {
+	return userCredential(&s.config().Root)
+}
+
 func (s *AuthService) GetCredential(r *rest.Rest) (*Credential, error) {
 
-	if s.config.Auth.AllowFullAnonymous {
+	if s.config().Auth.AllowFullAnonymous {
 		return anonymousCredential(), nil
 	}
 
-	if s.config.Auth.Debug {
+	if s.config().Auth.Debug {
 		log.Println("Request Headers:")
 		for k, v := range r.Request().Header {
 			log.Printf("   %20s %s", k, v)
@@ -43,25 +63,31 @@ func (s *AuthService) GetCredential(r *rest.Rest) (*Credential, error) {
 
 	authorization, exists := r.Request().Header["Authorization"]
 	if exists {
-		if strings.HasPrefix(authorization[0], signV4Algorithm) && !s.config.Auth.DisableV4 {
+		if strings.HasPrefix(authorization[0], signV4Algorithm) && !s.config().Auth.DisableV4 {
 			c, err := s.getAWS4CredentialHeader(authorization[0], r)
 			return c, err
 		}
 
-		if strings.HasPrefix(authorization[0], signV2Algorithm) && !s.config.Auth.DisableV2 {
+		if strings.HasPrefix(authorization[0], signV2Algorithm) && !s.config().Auth.DisableV2 {
 			c, err := s.getAWS2CredentialHeader(authorization[0], r)
 			return c, err
 		}
 
-		if s.config.Auth.Debug {
+		if s.config().Auth.Debug {
 			log.Println("Unsupported Authorization method:", authorization)
 		}
 
 		return nil, awserror.CredentialsNotSupported()
 	}
 
-	// TODO query params
-	// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+	// Presigned urls
+	if isPresignedV4(r) && !s.config().Auth.DisableV4 {
+		return s.getAWS4CredentialQuery(r)
+	}
+
+	if isPresignedV2(r) && !s.config().Auth.DisableV2 {
+		return s.getAWS2CredentialQuery(r)
+	}
 
 	// Deny access
 	return denyCredential(), nil
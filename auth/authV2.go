@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/subtle"
 	"encoding/base64"
 	"fmt"
 	"github.com/peter-mount/go-kernel/v2/rest"
@@ -11,7 +12,9 @@ import (
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Signature and API related constants.
@@ -24,15 +27,16 @@ func encodeURL2Path(req *http.Request) string {
 	return encodePath(req.URL.Path)
 }
 
-func stringToSignV2(r *rest.Rest) string {
+// stringToSignV2 returns the string to sign. date is the Date header or for
+// presigned urls the Expires query parameter
+func stringToSignV2(req *http.Request, date string) string {
 	buf := new(bytes.Buffer)
-	req := r.Request()
 
 	// Write standard headers.
 	buf.WriteString(req.Method + "\n")
 	buf.WriteString(req.Header.Get("Content-Md5") + "\n")
 	buf.WriteString(req.Header.Get("Content-Type") + "\n")
-	buf.WriteString(req.Header.Get("Date") + "\n")
+	buf.WriteString(date + "\n")
 
 	// Write canonicalized protocol headers if any.
 	writeCanonicalizedHeaders(buf, req)
@@ -84,6 +88,7 @@ func writeCanonicalizedHeaders(buf *bytes.Buffer, req *http.Request) {
 // The list should be alphabetically sorted
 var resourceList = []string{
 	"acl",
+	"cors",
 	"delete",
 	"lifecycle",
 	"location",
@@ -98,6 +103,7 @@ var resourceList = []string{
 	"response-content-language",
 	"response-content-type",
 	"response-expires",
+	"tagging",
 	"torrent",
 	"uploadId",
 	"uploads",
@@ -164,24 +170,26 @@ func writeCanonicalizedResource(buf *bytes.Buffer, req *http.Request) {
 // SignV2 sign the request before Do() (AWS Signature Version 2).
 func signV2(r *rest.Rest, accessKeyID, secretAccessKey string) (string, error) {
 
-	// Calculate HMAC for secretAccessKey.
-	stringToSign := stringToSignV2(r)
-
-	hm := hmac.New(sha1.New, []byte(secretAccessKey))
-	hm.Write([]byte(stringToSign))
-
 	// Prepare auth header.
 	authHeader := new(bytes.Buffer)
 	authHeader.WriteString(fmt.Sprintf("%s %s:", signV2Algorithm, accessKeyID))
-
-	encoder := base64.NewEncoder(base64.StdEncoding, authHeader)
-	encoder.Write(hm.Sum(nil))
-	encoder.Close()
+	authHeader.WriteString(signatureV2(r.Request(), r.Request().Header.Get("Date"), secretAccessKey))
 
 	// Authorization header.
 	return authHeader.String(), nil
 }
 
+// signatureV2 returns the base64 encoded signature of a request
+func signatureV2(req *http.Request, date, secretAccessKey string) string {
+	// Calculate HMAC for secretAccessKey.
+	stringToSign := stringToSignV2(req, date)
+
+	hm := hmac.New(sha1.New, []byte(secretAccessKey))
+	hm.Write([]byte(stringToSign))
+
+	return base64.StdEncoding.EncodeToString(hm.Sum(nil))
+}
+
 // Creates an AWS signature version 4 credential
 // https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-auth-using-authorization-header.html
 func (s *AuthService) getAWS2CredentialHeader(authorization string, r *rest.Rest) (*Credential, error) {
@@ -193,6 +201,10 @@ func (s *AuthService) getAWS2CredentialHeader(authorization string, r *rest.Rest
 		return nil, awserror.InvalidAccessKeyId()
 	}
 
+	if err := checkSessionToken(user, r.Request().Header.Get(amzSecurityToken)); err != nil {
+		return nil, err
+	}
+
 	signature, err := signV2(r, user.AccessKey, user.SecretKey)
 	if err != nil {
 		return nil, err
@@ -204,3 +216,45 @@ func (s *AuthService) getAWS2CredentialHeader(authorization string, r *rest.Rest
 
 	return userCredential(user), nil
 }
+
+// isPresignedV2 returns true if the request is a V2 presigned url
+func isPresignedV2(r *rest.Rest) bool {
+	_, exists := r.Request().URL.Query()["AWSAccessKeyId"]
+	return exists
+}
+
+// Creates an AWS signature version 2 credential from a presigned url
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/RESTAuthentication.html#RESTAuthenticationQueryStringAuth
+func (s *AuthService) getAWS2CredentialQuery(r *rest.Rest) (*Credential, error) {
+	query := r.Request().URL.Query()
+
+	accessKey, expires, signature := query.Get("AWSAccessKeyId"), query.Get("Expires"), query.Get("Signature")
+	if accessKey == "" || expires == "" || signature == "" {
+		return nil, awserror.AuthorizationQueryParametersError("Query-string authentication requires the Signature, Expires and AWSAccessKeyId parameters")
+	}
+
+	// Expires is the time in seconds since the epoch
+	e, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, awserror.AuthorizationQueryParametersError("Invalid date (should be seconds since epoch): %s", expires)
+	}
+	if time.Now().Unix() > e {
+		return nil, awserror.RequestExpired()
+	}
+
+	user := s.getUser(accessKey)
+	if user == nil {
+		return nil, awserror.InvalidAccessKeyId()
+	}
+
+	if err := checkSessionToken(user, query.Get(amzSecurityToken)); err != nil {
+		return nil, err
+	}
+
+	expected := signatureV2(r.Request(), expires, user.SecretKey)
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		return nil, awserror.AccessDenied()
+	}
+
+	return userCredential(user), nil
+}
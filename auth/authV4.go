@@ -6,6 +6,8 @@ import (
 	"github.com/peter-mount/go-kernel/v2/rest"
 	"github.com/peter-mount/objectstore/awserror"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -23,7 +25,7 @@ var ignoredHeaders = map[string]bool{
 
 // getCanonicalHeaders generate a list of request headers for
 // signature.
-func getCanonicalHeaders(r *rest.Rest, m map[string]string) string {
+func getCanonicalHeaders(req *http.Request, m map[string]string) string {
 	// Save all the headers in canonical form <header>:<value> newline
 	// separated for each header.
 	var buf bytes.Buffer
@@ -32,7 +34,7 @@ func getCanonicalHeaders(r *rest.Rest, m map[string]string) string {
 		buf.WriteByte(':')
 		switch {
 		case k == "host":
-			buf.WriteString(getHostAddr(r))
+			buf.WriteString(getHostAddr(req))
 			fallthrough
 		default:
 			buf.WriteString(m[k])
@@ -52,33 +54,45 @@ func getCanonicalHeaders(r *rest.Rest, m map[string]string) string {
 //	<CanonicalHeaders>\n
 //	<SignedHeaders>\n
 //	<HashedPayload>
-func getCanonicalRequest(r *rest.Rest, m map[string]string) string {
-	r.Request().URL.RawQuery = strings.Replace(r.Request().URL.Query().Encode(), "+", "%20", -1)
+func getCanonicalRequest(req *http.Request, m map[string]string, hashedPayload string) string {
+	req.URL.RawQuery = getCanonicalQueryString(req.URL.Query())
+	return getCanonicalRequestQuery(req, m, req.URL.RawQuery, hashedPayload)
+}
+
+// getCanonicalRequestQuery generates a canonical request with the supplied
+// canonical query string and payload hash
+func getCanonicalRequestQuery(req *http.Request, m map[string]string, query, hashedPayload string) string {
 	return strings.Join([]string{
-		r.Request().Method,
-		encodePath(r.Request().URL.Path),
-		r.Request().URL.RawQuery,
-		getCanonicalHeaders(r, m),
+		req.Method,
+		encodePath(OriginalPath(req)),
+		query,
+		getCanonicalHeaders(req, m),
 		m["signedheaders"],
-		getHashedPayload(r),
+		hashedPayload,
 	}, "\n")
 }
 
+// getCanonicalQueryString returns the query parameters sorted by name and uri encoded
+func getCanonicalQueryString(query url.Values) string {
+	return strings.Replace(query.Encode(), "+", "%20", -1)
+}
+
 // getStringToSign a string based on selected query values.
-func getStringToSignV4(t string, location, canonicalRequest string) string {
-	return strings.Join([]string{signV4Algorithm, t, getScope(location, t), hex.EncodeToString(sum256([]byte(canonicalRequest)))}, "\n")
+func getStringToSignV4(t string, location, service, canonicalRequest string) string {
+	return strings.Join([]string{signV4Algorithm, t, getScope(location, service, t), hex.EncodeToString(sum256([]byte(canonicalRequest)))}, "\n")
 }
 
 // getV4Credential extracts the content of the Authorization header.
 //
-// Returns m, accessKey, location, service, valid:
+// Returns m, accessKey, date, location, service, valid:
 //
 //	valid is false for an error, else true
 //	m map of the individual components of the authorization string
 //	accessKey of the user
+//	date of the credential scope, yyyymmdd
 //	location usually us-east-1
 //	service "s3" but could be different for other services
-func (s *AuthService) getV4Credential(authorization string, r *rest.Rest) (map[string]string, string, string, string, bool) {
+func (s *AuthService) getV4Credential(authorization string, r *rest.Rest) (map[string]string, string, string, string, string, bool) {
 	a := strings.SplitN(authorization, " ", 2)
 	if len(a) == 2 {
 
@@ -96,7 +110,7 @@ func (s *AuthService) getV4Credential(authorization string, r *rest.Rest) (map[s
 			}
 		}
 
-		if s.config.Auth.Debug {
+		if s.config().Auth.Debug {
 			log.Println("Authorization header:")
 			for k, v := range m {
 				log.Printf("   %20s %s", k, v)
@@ -107,11 +121,11 @@ func (s *AuthService) getV4Credential(authorization string, r *rest.Rest) (map[s
 			// accessKey/date/location/service/"aws4_request"
 			a = strings.Split(v, "/")
 			if len(a) == 5 {
-				return m, a[0], a[2], a[3], true
+				return m, a[0], a[1], a[2], a[3], true
 			}
 		}
 	}
-	return nil, "", "", "", false
+	return nil, "", "", "", "", false
 }
 
 // Creates an AWS signature version 4 credential
@@ -119,44 +133,70 @@ func (s *AuthService) getV4Credential(authorization string, r *rest.Rest) (map[s
 func (s *AuthService) getAWS4CredentialHeader(authorization string, r *rest.Rest) (*Credential, error) {
 
 	// The X-Amz-Date or Date header
-	t := getSigningDate(r)
+	t, date, ok := getSigningDate(r.Request().Header)
+	if !ok {
+		return nil, awserror.MissingDateHeader()
+	}
+
+	// Reject requests outside of the allowed skew so they cannot be replayed later
+	if err := s.checkClockSkew(date); err != nil {
+		return nil, err
+	}
 
-	m, accessKey, location, _, valid := s.getV4Credential(authorization, r)
+	m, accessKey, scopeDate, location, service, valid := s.getV4Credential(authorization, r)
 	if !valid {
 		return nil, awserror.InvalidArgument("Invalid Authorization: %s", authorization)
 	}
 
+	if err := s.checkScope(r, t, scopeDate, location, service); err != nil {
+		return nil, err
+	}
+
 	user := s.getUser(accessKey)
 	if user == nil {
 		return nil, awserror.InvalidAccessKeyId()
 	}
-	if s.config.Auth.Debug {
+	if s.config().Auth.Debug {
 		log.Println("User:", user.AccessKey, user.SecretKey, user.Arn)
 	}
 
+	if err := checkSessionToken(user, r.Request().Header.Get(amzSecurityToken)); err != nil {
+		return nil, err
+	}
+
+	// Only s3 sends the payload hash as a header, other services sign the body
+	hashedPayload := getHashedPayload(r)
+	if service != ServiceS3 && r.Request().Header.Get(amzContentSha256) == "" {
+		b, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+		hashedPayload = hex.EncodeToString(sum256(b))
+	}
+
 	// Get canonical request.
-	canonicalRequest := getCanonicalRequest(r, m)
-	if s.config.Auth.Debug {
+	canonicalRequest := getCanonicalRequest(r.Request(), m, hashedPayload)
+	if s.config().Auth.Debug {
 		log.Printf("canonicalRequest\n%s", canonicalRequest)
 	}
 
 	// Get string to sign from canonical request.
-	stringToSign := getStringToSignV4(t, location, canonicalRequest)
+	stringToSign := getStringToSignV4(t, location, service, canonicalRequest)
 
 	// Get hmac signing key.
-	signingKey := getSigningKey(user.SecretKey, location, t)
+	signingKey := getSigningKey(user.SecretKey, location, service, t)
 
 	// Calculate signature.
 	signature := getSignature(signingKey, stringToSign)
 
-	if s.config.Auth.Debug {
+	if s.config().Auth.Debug {
 		log.Println(m["signature"] == signature, signature)
 	}
 
 	if signature != m["signature"] {
 		// If debug include extra debugging as we've had valid requests fail due to incorrect times
-		if s.config.Auth.Debug {
-			log.Printf("host %s location %s time %v secret %s", getHostAddr(r), location, t, user.SecretKey)
+		if s.config().Auth.Debug {
+			log.Printf("host %s location %s time %v secret %s", getHostAddr(r.Request()), location, t, user.SecretKey)
 			log.Printf("canonicalRequest\n%s", canonicalRequest)
 		}
 		return nil, awserror.AccessDenied()
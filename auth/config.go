@@ -1,9 +1,11 @@
 package auth
 
 import (
+  "fmt"
   "gopkg.in/yaml.v2"
   "io/ioutil"
   "path/filepath"
+  "time"
 )
 
 type config struct {
@@ -16,6 +18,8 @@ type config struct {
     DisableV4           bool              `yaml:"disableV4"`
     // Enable debugging of authentication
     Debug               bool              `yaml:"debug"`
+    // The maximum difference between a request's time & our clock, e.g. "15m"
+    ClockSkew           string            `yaml:"clockSkew"`
   }
   // Enable debugging
   //Debug               bool              `yaml:"debug"`
@@ -23,22 +27,70 @@ type config struct {
   Root                User              `yaml:"rootUser"`
   // The individual users (other than root)
   Users               map[string]User   `yaml:"users"`
+  // Managed policies which can be attached to users & groups
+  Policies            map[string]PolicyDocument `yaml:"policies"`
+  // Groups of users
+  Groups              map[string]Group  `yaml:"groups"`
+  // Storage quotas
+  Quotas              quotas            `yaml:"quotas"`
+  // Roles which can be assumed with sts AssumeRole
+  Roles               map[string]Role   `yaml:"roles"`
+  // OpenID Connect providers whose tokens can be used with AssumeRoleWithWebIdentity
+  WebIdentityProviders  map[string]WebIdentityProvider  `yaml:"webIdentityProviders"`
+  // The parsed Auth.ClockSkew
+  clockSkew           time.Duration
+  // The name of the user of each access key in Users
+  accessKeys          map[string]string
+  // The name of the user of each canonical id in Users
+  canonicalIds        map[string]string
 }
 
+// newConfig returns the config used when there is no config file
+func newConfig() *config {
+  return &config{clockSkew: defaultClockSkew}
+}
+
+// config returns the current config. This is replaced as a whole when the
+// config file is reloaded so a config must not be modified once in use.
+func (s *AuthService) config() *config {
+  return s.current.Load()
+}
+
+// loadConfig loads the config file, replacing the current config only if the
+// new one is valid
 func (s *AuthService) loadConfig() error {
-  filename, _ := filepath.Abs( *s.configFile )
+  c, err := parseConfig( *s.configFile )
+  if err != nil {
+    return err
+  }
+
+  s.current.Store( c )
+  return nil
+}
+
+// parseConfig parses & validates a config file.
+// Unknown fields & duplicate keys are rejected.
+func parseConfig( configFile string ) (*config, error) {
+  filename, _ := filepath.Abs( configFile )
 
   yml, err := ioutil.ReadFile( filename )
   if err != nil {
-    return err
+    return nil, err
+  }
+
+  c := newConfig()
+  err = yaml.UnmarshalStrict( yml, c )
+  if err != nil {
+    return nil, fmt.Errorf( "%s: %v", filename, err )
   }
 
   // root is root
-  s.config.Root.root = true
-  // Ensure users have the correct access key
-  for k, v := range s.config.Users {
-    v.AccessKey = k
+  c.Root.root = true
+
+  err = c.validate( &configErrors{filename: filename, yml: yml} )
+  if err != nil {
+    return nil, err
   }
 
-  return yaml.Unmarshal( yml, &s.config )
+  return c, nil
 }
@@ -2,6 +2,8 @@ package auth
 
 import (
   "fmt"
+  "github.com/peter-mount/objectstore/awserror"
+  "github.com/peter-mount/objectstore/policy"
   "github.com/peter-mount/objectstore/utils"
 )
 
@@ -11,12 +13,24 @@ type Credential struct {
   anon          bool
   // true if no Authorization in the request or it failed
   deny          bool
-  // The authenticated user's accessKey
+  // The access key the user authenticated with
   accessKey     string
+  // The authenticated user's name
+  userName      string
   // The users ARN
   arn          *utils.ARN
   // true if this user is root
   root        bool
+  // The users canonical id
+  canonicalId   string
+  // The users display name
+  displayName   string
+  // The identity policies attached to the user
+  policies    []*policy.Policy
+  // true if these are temporary credentials issued by sts
+  session     bool
+  // The session policy limiting what temporary credentials can do
+  sessionPolicy *policy.Policy
 }
 
 func userCredential( user *User ) *Credential {
@@ -25,8 +39,14 @@ func userCredential( user *User ) *Credential {
   }
   return &Credential{
     accessKey: user.AccessKey,
+    userName: user.Name,
     arn: &user.Arn,
     root: user.root,
+    canonicalId: user.CanonicalId(),
+    displayName: user.DisplayName(),
+    policies: user.policies,
+    session: user.session != nil,
+    sessionPolicy: user.sessionPolicy,
   }
 }
 
@@ -46,10 +66,21 @@ func (s *Credential) IsDeny() bool {
   return s==nil || s.deny
 }
 
+// AccessKey returns the access key used to authenticate, as a user can have
+// more than one
 func (s *Credential) AccessKey() string {
   return s.accessKey
 }
 
+// UserName returns the name of the authenticated user, "" for root or
+// temporary credentials
+func (s *Credential) UserName() string {
+  if s == nil {
+    return ""
+  }
+  return s.userName
+}
+
 func (s *Credential) Arn() *utils.ARN {
   return s.arn
 }
@@ -58,11 +89,102 @@ func (s *Credential) IsRoot() bool {
   return s != nil && s.root
 }
 
+// IsAuthenticated returns true if this credential is for an authenticated user
+func (s *Credential) IsAuthenticated() bool {
+  return s != nil && !s.anon && !s.deny && s.accessKey != ""
+}
+
+// CanonicalId returns the canonical id of the user, "" if not authenticated
+func (s *Credential) CanonicalId() string {
+  if s == nil {
+    return ""
+  }
+  return s.canonicalId
+}
+
+// Principal returns the ARN used as the principal when evaluating policies.
+// Unauthenticated requests are the anonymous principal "*".
+func (s *Credential) Principal() *utils.ARN {
+  if !s.IsAuthenticated() {
+    return utils.AnonymousARN()
+  }
+  if s.arn == nil {
+    return utils.NilARN()
+  }
+  return s.arn
+}
+
+// Policies returns the identity policies attached to the user
+func (s *Credential) Policies() []*policy.Policy {
+  if s == nil {
+    return nil
+  }
+  return s.policies
+}
+
+// DefaultAllowed returns true if the credential can perform actions which no
+// ACL grants, e.g. CreateBucket, without a policy allowing them.
+// Users with identity policies are limited to what their policies allow.
+func (s *Credential) DefaultAllowed() bool {
+  return s.IsAuthenticated() && len( s.policies ) == 0
+}
+
+// Authorize returns AccessDenied if the credential cannot perform a request.
+// aclAllowed is true if the resource's ACL grants the request and
+// resourcePolicies are the resource's policies, e.g. the bucket policy.
+//
+// Root or full anonymous access is always allowed. Otherwise an explicit Deny
+// in the identity or resource policies denies the request. An Allow in any of
+// them or the ACL then allows it. Temporary credentials with a session policy
+// must also be allowed by it.
+func (s *Credential) Authorize( req *policy.Request, aclAllowed bool, resourcePolicies ...*policy.Policy ) error {
+  if s.IsRoot() || s.IsAnonymous() {
+    return nil
+  }
+
+  policies := append( append( []*policy.Policy{}, s.Policies()... ), resourcePolicies... )
+  decision, _ := policy.Evaluate( req, policies... )
+  if decision == policy.Deny || !(decision.Allowed() || aclAllowed) {
+    return awserror.AccessDenied()
+  }
+
+  // A session policy limits what temporary credentials can do
+  if p := s.SessionPolicy(); p != nil {
+    if decision, _ := p.Evaluate( req ); !decision.Allowed() {
+      return awserror.AccessDenied()
+    }
+  }
+
+  return nil
+}
+
+// IsSession returns true if the credential is temporary, issued by sts
+func (s *Credential) IsSession() bool {
+  return s != nil && s.session
+}
+
+// SessionPolicy returns the policy limiting what temporary credentials can do
+// or nil if there isn't one
+func (s *Credential) SessionPolicy() *policy.Policy {
+  if s == nil {
+    return nil
+  }
+  return s.sessionPolicy
+}
+
+// DisplayName returns the display name of the user, "" if not authenticated
+func (s *Credential) DisplayName() string {
+  if s == nil {
+    return ""
+  }
+  return s.displayName
+}
+
 func (s *Credential) String() string {
   if s == nil {
     return "nil"
   }
-  return fmt.Sprintf( "Credential[type=%s,arn=%v,key=%s]", s.Type(), s.arn, s.accessKey)
+  return fmt.Sprintf( "Credential[type=%s,user=%s,arn=%v,key=%s]", s.Type(), s.userName, s.arn, s.accessKey)
 }
 
 func (s *Credential) Type() string {
@@ -78,5 +200,8 @@ func (s *Credential) Type() string {
   if s.deny {
     return "deny"
   }
+  if s.accessKey != "" {
+    return "user"
+  }
   return "invalid"
 }
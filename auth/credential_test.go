@@ -0,0 +1,122 @@
+package auth
+
+import (
+  "github.com/peter-mount/objectstore/awserror"
+  "github.com/peter-mount/objectstore/condition"
+  "github.com/peter-mount/objectstore/policy"
+  "github.com/peter-mount/objectstore/utils"
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+// writeTestConfig writes a config file to a temporary directory
+func writeTestConfig( t *testing.T, yml string ) string {
+  dir, err := ioutil.TempDir( "", "auth" )
+  if err != nil {
+    t.Fatal( err )
+  }
+  t.Cleanup( func() {
+    os.RemoveAll( dir )
+  } )
+
+  filename := filepath.Join( dir, "config.yaml" )
+  if err := ioutil.WriteFile( filename, []byte( yml ), 0644 ); err != nil {
+    t.Fatal( err )
+  }
+  return filename
+}
+
+// testAuthService returns an AuthService using a config
+func testAuthService( t *testing.T, yml string ) *AuthService {
+  c, err := parseConfig( writeTestConfig( t, yml ) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  s := &AuthService{}
+  s.current.Store( c )
+  return s
+}
+
+const testScopedConfig = `
+rootUser:
+  accessKey: ROOTKEY
+  secretKey: rootsecret
+users:
+  ci:
+    arn: "arn:aws:iam::123456789012:user/ci"
+    accessKeys:
+      - accessKey: CIKEY
+        secretKey: cisecret
+    policies:
+      - ci-write
+  legacy:
+    accessKeys:
+      - accessKey: LEGACYKEY
+        secretKey: legacysecret
+policies:
+  ci-write:
+    Version: "2012-10-17"
+    Statement:
+      - Effect: Allow
+        Action: s3:PutObject
+        Resource: arn:aws:s3:::bucket/ci/*
+`
+
+func TestCredential_Authorize( t *testing.T ) {
+  s := testAuthService( t, testScopedConfig )
+
+  for i, test := range []struct {
+    accessKey string
+    action    string
+    resource  string
+    allowed   bool
+  }{
+    // A prefix scoped user can only do what their policy allows
+    {"CIKEY", "s3:PutObject", "bucket/ci/build.tgz", true},
+    {"CIKEY", "s3:PutObject", "bucket/other", false},
+    {"CIKEY", "s3:CreateBucket", "newbucket", false},
+    {"CIKEY", "s3:ListAllMyBuckets", "*", false},
+    // Users without identity policies can create & list buckets
+    {"LEGACYKEY", "s3:CreateBucket", "newbucket", true},
+    {"LEGACYKEY", "s3:ListAllMyBuckets", "*", true},
+    // Root can do anything
+    {"ROOTKEY", "s3:CreateBucket", "newbucket", true},
+  } {
+    cred := userCredential( s.getUser( test.accessKey ) )
+
+    req := &policy.Request{
+      Principal:   cred.Principal(),
+      CanonicalId: cred.CanonicalId(),
+      Action:      test.action,
+      Resource:    utils.NewS3ARN( "arn", "", test.resource ),
+      Context:     condition.NewContext(),
+    }
+
+    // CreateBucket & ListAllMyBuckets have no ACL
+    err := cred.Authorize( req, cred.DefaultAllowed() )
+    if (err == nil) != test.allowed {
+      t.Errorf( "%d: %s %s %s expected allowed %v got %v", i, test.accessKey, test.action, test.resource, test.allowed, err )
+    }
+  }
+}
+
+func TestCredential_Authorize_denied( t *testing.T ) {
+  s := testAuthService( t, testScopedConfig )
+  cred := userCredential( s.getUser( "CIKEY" ) )
+
+  req := &policy.Request{
+    Principal:   cred.Principal(),
+    CanonicalId: cred.CanonicalId(),
+    Action:      "s3:CreateBucket",
+    Resource:    utils.NewS3ARN( "arn", "", "newbucket" ),
+    Context:     condition.NewContext(),
+  }
+
+  err := cred.Authorize( req, cred.DefaultAllowed() )
+  if e, ok := err.(*awserror.Error); !ok || e.Code != "AccessDenied" {
+    t.Errorf( "Expected AccessDenied got %v", err )
+  }
+}
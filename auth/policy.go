@@ -0,0 +1,158 @@
+package auth
+
+import (
+  "encoding/json"
+  "fmt"
+  "github.com/peter-mount/objectstore/policy"
+)
+
+// A policy document in the config.
+// This can either be the JSON document as a string or the document written in yaml.
+type PolicyDocument struct {
+  policy *policy.Policy
+}
+
+func (a *PolicyDocument) UnmarshalYAML( f func(interface{}) error ) error {
+  b, err := unmarshalPolicy( f )
+  if err != nil {
+    return err
+  }
+  return a.parse( b )
+}
+
+// unmarshalPolicy returns the JSON of a policy in the config, written either
+// as the JSON document in a string or in yaml
+func unmarshalPolicy( f func(interface{}) error ) ([]byte, error) {
+  var s string
+  if err := f( &s ); err == nil {
+    return []byte(s), nil
+  }
+
+  var v interface{}
+  if err := f( &v ); err != nil {
+    return nil, err
+  }
+
+  return json.Marshal( yamlToJson( v ) )
+}
+
+func (a *PolicyDocument) parse( b []byte ) error {
+  p, err := policy.Parse( b )
+  if err != nil {
+    return err
+  }
+
+  // Identity policies apply to the user they are attached to
+  for _, s := range p.Statement {
+    if !s.Principal.IsNil() {
+      return fmt.Errorf( "Principal not allowed in identity policy statement %q", s.Sid )
+    }
+  }
+
+  a.policy = p
+  return nil
+}
+
+func (a *PolicyDocument) Policy() *policy.Policy {
+  if a == nil {
+    return nil
+  }
+  return a.policy
+}
+
+// A role's trust policy in the config. Unlike a PolicyDocument this must
+// have a Principal as it defines who can assume the role, and no Resource.
+type TrustPolicy struct {
+  policy *policy.Policy
+}
+
+func (a *TrustPolicy) UnmarshalYAML( f func(interface{}) error ) error {
+  b, err := unmarshalPolicy( f )
+  if err != nil {
+    return err
+  }
+
+  p, err := policy.ParseTrust( b )
+  if err != nil {
+    return err
+  }
+
+  a.policy = p
+  return nil
+}
+
+func (a *TrustPolicy) Policy() *policy.Policy {
+  if a == nil {
+    return nil
+  }
+  return a.policy
+}
+
+// yamlToJson converts the map[interface{}]interface{} yaml uses for objects
+// into map[string]interface{} so it can be marshaled as json
+func yamlToJson( v interface{} ) interface{} {
+  switch t := v.(type) {
+    case map[interface{}]interface{}:
+      m := make( map[string]interface{} )
+      for k, e := range t {
+        m[fmt.Sprint( k )] = yamlToJson( e )
+      }
+      return m
+    case []interface{}:
+      for i, e := range t {
+        t[i] = yamlToJson( e )
+      }
+      return t
+    default:
+      return v
+  }
+}
+
+// A group of users sharing the same policies
+type Group struct {
+  // Names of managed policies attached to the group
+  Policies        []string                    `yaml:"policies"`
+  // Policies embedded in the group
+  InlinePolicies  map[string]PolicyDocument   `yaml:"inlinePolicies"`
+}
+
+// resolvePolicies resolves the identity policies attached to a user, either
+// directly or via the groups the user is a member of
+func (c *config) resolvePolicies( user *User ) error {
+  owner := "user " + user.Name
+
+  policies, err := c.managedPolicies( nil, user.Policies, user.InlinePolicies, owner )
+  if err != nil {
+    return err
+  }
+
+  for _, n := range user.Groups {
+    g, exists := c.Groups[n]
+    if !exists {
+      return fmt.Errorf( "Unknown group %q for %s", n, owner )
+    }
+    policies, err = c.managedPolicies( policies, g.Policies, g.InlinePolicies, owner )
+    if err != nil {
+      return err
+    }
+  }
+
+  user.policies = policies
+  return nil
+}
+
+// managedPolicies appends the named managed policies & the inline policies to
+// policies. owner is who they are attached to, used in errors.
+func (c *config) managedPolicies( policies []*policy.Policy, names []string, inline map[string]PolicyDocument, owner string ) ([]*policy.Policy, error) {
+  for _, n := range names {
+    p, exists := c.Policies[n]
+    if !exists {
+      return nil, fmt.Errorf( "Unknown policy %q for %s", n, owner )
+    }
+    policies = append( policies, p.Policy() )
+  }
+  for _, p := range inline {
+    policies = append( policies, p.Policy() )
+  }
+  return policies, nil
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"strings"
+)
+
+// GetPostPolicyCredential returns the Credential for a browser based POST upload.
+// fields are the form fields with their names in lower case.
+// A V4 credential must be for the bucket's region & s3.
+//
+// If the form has no policy then the upload is anonymous.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-authentication-HTTPPOST.html
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/HTTPPOSTForms.html
+func (s *AuthService) GetPostPolicyCredential(r *rest.Rest, fields map[string]string) (*Credential, error) {
+
+	if s.config().Auth.AllowFullAnonymous {
+		return anonymousCredential(), nil
+	}
+
+	policy, exists := fields["policy"]
+	if !exists {
+		return denyCredential(), nil
+	}
+
+	// Signature V4
+	if algorithm, exists := fields["x-amz-algorithm"]; exists {
+		if s.config().Auth.DisableV4 {
+			return nil, awserror.CredentialsNotSupported()
+		}
+
+		if algorithm != signV4Algorithm {
+			return nil, awserror.InvalidArgument("x-amz-algorithm only supports \"%s\"", signV4Algorithm)
+		}
+
+		// accessKey/date/location/service/"aws4_request"
+		credential := strings.Split(fields["x-amz-credential"], "/")
+		if len(credential) != 5 || credential[4] != "aws4_request" {
+			return nil, awserror.InvalidArgument("Invalid x-amz-credential")
+		}
+
+		t := fields["x-amz-date"]
+		if len(t) < 8 || t[0:8] != credential[1] {
+			return nil, awserror.InvalidArgument("Invalid x-amz-date")
+		}
+
+		if err := s.checkScope(r, t, credential[1], credential[2], credential[3]); err != nil {
+			return nil, err
+		}
+
+		user := s.getUser(credential[0])
+		if user == nil {
+			return nil, awserror.InvalidAccessKeyId()
+		}
+
+		if err := checkSessionToken(user, fields["x-amz-security-token"]); err != nil {
+			return nil, err
+		}
+
+		// The string to sign is the base64 encoded policy
+		expected := getSignature(getSigningKey(user.SecretKey, credential[2], ServiceS3, t), policy)
+		if subtle.ConstantTimeCompare([]byte(fields["x-amz-signature"]), []byte(expected)) != 1 {
+			return nil, awserror.AccessDenied()
+		}
+
+		return userCredential(user), nil
+	}
+
+	// Signature V2
+	if accessKey, exists := fields["awsaccesskeyid"]; exists {
+		if s.config().Auth.DisableV2 {
+			return nil, awserror.CredentialsNotSupported()
+		}
+
+		user := s.getUser(accessKey)
+		if user == nil {
+			return nil, awserror.InvalidAccessKeyId()
+		}
+
+		if err := checkSessionToken(user, fields["x-amz-security-token"]); err != nil {
+			return nil, err
+		}
+
+		hm := hmac.New(sha1.New, []byte(user.SecretKey))
+		hm.Write([]byte(policy))
+		expected := base64.StdEncoding.EncodeToString(hm.Sum(nil))
+		if subtle.ConstantTimeCompare([]byte(fields["signature"]), []byte(expected)) != 1 {
+			return nil, awserror.AccessDenied()
+		}
+
+		return userCredential(user), nil
+	}
+
+	return nil, awserror.InvalidArgument("Bucket POST must contain a field named 'AWSAccessKeyId' or 'X-Amz-Credential'.  If it is specified, please check the order of the fields.")
+}
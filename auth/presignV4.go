@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters used by presigned urls
+const (
+	amzAlgorithm     = "X-Amz-Algorithm"
+	amzCredential    = "X-Amz-Credential"
+	amzDate          = "X-Amz-Date"
+	amzExpires       = "X-Amz-Expires"
+	amzSignedHeaders = "X-Amz-SignedHeaders"
+	amzSignature     = "X-Amz-Signature"
+	amzContentSha256 = "X-Amz-Content-Sha256"
+	amzSecurityToken = "X-Amz-Security-Token"
+)
+
+const (
+	// The maximum a presigned url can be valid for, 7 days
+	maxPresignExpires = 7 * 24 * 60 * 60
+)
+
+// isPresignedV4 returns true if the request is a V4 presigned url
+func isPresignedV4(r *rest.Rest) bool {
+	_, exists := r.Request().URL.Query()[amzAlgorithm]
+	return exists
+}
+
+// Creates an AWS signature version 4 credential from a presigned url
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html
+func (s *AuthService) getAWS4CredentialQuery(r *rest.Rest) (*Credential, error) {
+	return s.presignedV4Credential(r.Request(), s.requestRegion(r), time.Now())
+}
+
+// presignedV4Credential verifies a presigned url at a time. region is the
+// region the url must be signed for, "" for any.
+func (s *AuthService) presignedV4Credential(req *http.Request, region string, now time.Time) (*Credential, error) {
+	query := req.URL.Query()
+
+	for _, k := range []string{amzAlgorithm, amzCredential, amzDate, amzExpires, amzSignedHeaders, amzSignature} {
+		if query.Get(k) == "" {
+			return nil, awserror.AuthorizationQueryParametersError("Query-string authentication version 4 requires the X-Amz-Algorithm, X-Amz-Credential, X-Amz-Signature, X-Amz-Date, X-Amz-SignedHeaders, and X-Amz-Expires parameters.")
+		}
+	}
+
+	if query.Get(amzAlgorithm) != signV4Algorithm {
+		return nil, awserror.AuthorizationQueryParametersError("X-Amz-Algorithm only supports \"%s\"", signV4Algorithm)
+	}
+
+	// accessKey/date/location/service/"aws4_request"
+	credential := strings.Split(query.Get(amzCredential), "/")
+	if len(credential) != 5 || credential[4] != "aws4_request" {
+		return nil, awserror.AuthorizationQueryParametersError("Error parsing the X-Amz-Credential parameter; the Credential is mal-formed; expecting \"<YOUR-AKID>/YYYYMMDD/REGION/SERVICE/aws4_request\".")
+	}
+	accessKey, location := credential[0], credential[2]
+
+	t := query.Get(amzDate)
+	date, err := time.Parse(iso8601DateFormat, t)
+	if err != nil {
+		return nil, awserror.AuthorizationQueryParametersError("X-Amz-Date must be in the ISO8601 Long Format \"yyyyMMdd'T'HHmmss'Z'\"")
+	}
+	if credential[1] != t[0:8] {
+		return nil, awserror.AuthorizationQueryParametersError("Invalid credential date \"%s\". This date is not the same as X-Amz-Date: \"%s\".", credential[1], t[0:8])
+	}
+	if region != "" && location != region {
+		return nil, awserror.AuthorizationQueryParametersError("Error parsing the X-Amz-Credential parameter; the region '%s' is wrong; expecting '%s'", location, region).WithRegion(region)
+	}
+	if credential[3] != ServiceS3 {
+		return nil, awserror.AuthorizationQueryParametersError("Error parsing the X-Amz-Credential parameter; incorrect service \"%s\". This endpoint belongs to \"s3\".", credential[3])
+	}
+
+	expires, err := strconv.Atoi(query.Get(amzExpires))
+	if err != nil {
+		return nil, awserror.AuthorizationQueryParametersError("X-Amz-Expires should be a number")
+	}
+	if expires < 0 {
+		return nil, awserror.AuthorizationQueryParametersError("X-Amz-Expires must be non-negative")
+	}
+	if expires > maxPresignExpires {
+		return nil, awserror.AuthorizationQueryParametersError("X-Amz-Expires must be less than a week (in seconds) that is %d", maxPresignExpires)
+	}
+
+	if date.After(now.Add(s.config().clockSkew)) {
+		return nil, awserror.RequestNotYetValid()
+	}
+	if now.After(date.Add(time.Duration(expires) * time.Second)) {
+		return nil, awserror.RequestExpired()
+	}
+
+	user := s.getUser(accessKey)
+	if user == nil {
+		return nil, awserror.InvalidAccessKeyId()
+	}
+
+	if err := checkSessionToken(user, query.Get(amzSecurityToken)); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]string)
+	for k, v := range req.Header {
+		m[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	m["signedheaders"] = query.Get(amzSignedHeaders)
+
+	// The payload is not signed unless the hash is included in the url
+	hashedPayload := query.Get(amzContentSha256)
+	if hashedPayload == "" {
+		hashedPayload = unsignedPayload
+	}
+
+	// The signature is not part of the canonical request
+	signature := query.Get(amzSignature)
+	query.Del(amzSignature)
+
+	canonicalRequest := getCanonicalRequestQuery(req, m, getCanonicalQueryString(query), hashedPayload)
+	if s.config().Auth.Debug {
+		log.Printf("canonicalRequest\n%s", canonicalRequest)
+	}
+
+	stringToSign := getStringToSignV4(t, location, ServiceS3, canonicalRequest)
+	expected := getSignature(getSigningKey(user.SecretKey, location, ServiceS3, t), stringToSign)
+
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		if s.config().Auth.Debug {
+			log.Printf("host %s location %s time %v signature %s expected %s", getHostAddr(req), location, t, signature, expected)
+		}
+		return nil, awserror.AccessDenied()
+	}
+
+	return userCredential(user), nil
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+  "encoding/json"
+  "github.com/peter-mount/go-kernel/v2/bolt"
+)
+
+// Quota limits the storage used. A zero limit is unlimited.
+type Quota struct {
+  // Maximum number of bytes stored
+  MaxSize     int64     `json:"maxSize,omitempty" yaml:"maxSize"`
+  // Maximum number of objects stored
+  MaxObjects  int64     `json:"maxObjects,omitempty" yaml:"maxObjects"`
+}
+
+// The quotas in the config
+type quotas struct {
+  // The default quota of every user without their own quota
+  User        Quota             `yaml:"user"`
+  // The default quota of every bucket without it's own quota
+  Bucket      Quota             `yaml:"bucket"`
+  // Quotas for individual buckets
+  Buckets     map[string]Quota  `yaml:"buckets"`
+}
+
+// UserQuota returns the quota of the user with a canonical id.
+// Root and unknown users are unlimited.
+// This is called whilst an object is written so it uses that write transaction
+// & an index of canonical ids rather than searching every user.
+func (s *AuthService) UserQuota( tx *bolt.Tx, canonicalId string ) (Quota, error) {
+  c := s.config()
+  if name, exists := c.canonicalIds[ canonicalId ]; exists {
+    return c.userQuota( c.Users[ name ].Quota ), nil
+  }
+
+  // Users managed by the admin api
+  ib, err := s.canonicalIdIndex( tx )
+  if err != nil {
+    return Quota{}, err
+  }
+
+  name := ib.Get( canonicalId )
+  if name == nil {
+    return Quota{}, nil
+  }
+
+  ub := tx.Bucket( usersBucket )
+  if ub == nil {
+    return Quota{}, nil
+  }
+
+  v := ub.Get( string( name ) )
+  if v == nil {
+    return Quota{}, nil
+  }
+
+  user := &User{}
+  if err := json.Unmarshal( v, user ); err != nil {
+    return Quota{}, err
+  }
+  return c.userQuota( user.Quota ), nil
+}
+
+// userQuota returns a user's quota or the default if they don't have one
+func (c *config) userQuota( q *Quota ) Quota {
+  if q != nil {
+    return *q
+  }
+  return c.Quotas.User
+}
+
+// BucketQuota returns the quota of a bucket
+func (s *AuthService) BucketQuota( bucketName string ) Quota {
+  if q, exists := s.config().Quotas.Buckets[ bucketName ]; exists {
+    return q
+  }
+  return s.config().Quotas.Bucket
+}
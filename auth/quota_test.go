@@ -0,0 +1,76 @@
+package auth
+
+import (
+  "testing"
+)
+
+const testQuotaConfig = `
+rootUser:
+  accessKey: ROOTKEY
+  secretKey: rootsecret
+users:
+  ci:
+    accessKeys:
+      - accessKey: CIKEY
+        secretKey: cisecret
+    quota:
+      maxSize: 1000
+  legacy:
+    accessKeys:
+      - accessKey: LEGACYKEY
+        secretKey: legacysecret
+quotas:
+  user:
+    maxSize: 100
+    maxObjects: 10
+  bucket:
+    maxObjects: 50
+  buckets:
+    builds:
+      maxSize: 2000
+`
+
+func TestAuthService_BucketQuota( t *testing.T ) {
+  s := testAuthService( t, testQuotaConfig )
+
+  for _, test := range []struct {
+    bucket  string
+    want    Quota
+  }{
+    {bucket: "builds", want: Quota{MaxSize: 2000}},
+    {bucket: "other", want: Quota{MaxObjects: 50}},
+  } {
+    t.Run( test.bucket, func( t *testing.T ) {
+      if got := s.BucketQuota( test.bucket ); got != test.want {
+        t.Errorf( "got %+v want %+v", got, test.want )
+      }
+    } )
+  }
+}
+
+func TestAuthService_UserQuota( t *testing.T ) {
+  s := testAuthService( t, testQuotaConfig )
+  c := s.config()
+
+  for _, test := range []struct {
+    name  string
+    want  Quota
+  }{
+    // A user's own quota replaces the default
+    {name: "ci", want: Quota{MaxSize: 1000}},
+    {name: "legacy", want: Quota{MaxSize: 100, MaxObjects: 10}},
+  } {
+    t.Run( test.name, func( t *testing.T ) {
+      user := c.Users[ test.name ]
+
+      // Users in the config are found without a transaction
+      got, err := s.UserQuota( nil, user.CanonicalId() )
+      if err != nil {
+        t.Fatal( err )
+      }
+      if got != test.want {
+        t.Errorf( "got %+v want %+v", got, test.want )
+      }
+    } )
+  }
+}
@@ -1,8 +1,14 @@
 package auth
 
 import (
+	"errors"
 	"flag"
 	"github.com/peter-mount/go-kernel/v2"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/utils"
+	"log"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,17 +16,38 @@ import (
 // Although this is primarily for objectstore & S3 this could be reused for other
 // services.
 type AuthService struct {
-	configFile   *string
-	config       config
+	configFile  *string
+	checkConfig *bool
+	boltService *bolt.BoltService
+	// The current config, replaced when the config file is reloaded
+	current      atomic.Pointer[config]
 	timeLocation *time.Location
+	// Returns the region a request must be signed for
+	region RegionFunc
+	// Returns the service a request must be signed for
+	service ServiceFunc
+	// Stops watching the config file
+	stopWatch func()
+	// When access keys were used but not yet stored
+	keysUsed keysUsed
+	// Stops storing when access keys were used
+	stopKeysUsed func()
 }
 
+// ErrConfigChecked is returned by PostInit once -check-config has validated
+// the config file so that main can exit successfully
+var ErrConfigChecked = errors.New("config checked")
+
+// The default maximum difference between a request's time and our clock
+const defaultClockSkew = 15 * time.Minute
+
 func (s *AuthService) Name() string {
 	return "AuthService"
 }
 
 func (s *AuthService) Init(k *kernel.Kernel) error {
 	s.configFile = flag.String("config", "", "The config file to use")
+	s.checkConfig = flag.Bool("check-config", false, "Validate the config file then exit")
 
 	timeLocation, err := time.LoadLocation("GMT")
 	if err != nil {
@@ -28,15 +55,103 @@ func (s *AuthService) Init(k *kernel.Kernel) error {
 	}
 	s.timeLocation = timeLocation
 
+	s.current.Store(newConfig())
+
+	// Users managed by the admin api are stored in bbolt
+	service, err := k.AddService(&bolt.BoltService{})
+	if err != nil {
+		return err
+	}
+	s.boltService = (service).(*bolt.BoltService)
+
 	return nil
 }
 
+// RegionFunc returns the region a request must be signed for, "" for any
+type RegionFunc func(r *rest.Rest) string
+
+// SetRegion sets the function returning the region V4 signatures must be
+// signed for
+func (s *AuthService) SetRegion(f RegionFunc) {
+	s.region = f
+}
+
+// requestRegion returns the region a request must be signed for, "" for any
+func (s *AuthService) requestRegion(r *rest.Rest) string {
+	if s.region == nil {
+		return ""
+	}
+	return s.region(r)
+}
+
+// The services a V4 signature can be for
+const (
+	ServiceS3  = "s3"
+	ServiceSTS = "sts"
+)
+
+// ServiceFunc returns the service a request must be signed for
+type ServiceFunc func(r *rest.Rest) string
+
+// SetService sets the function returning the service V4 signatures must be
+// signed for. If not set then all requests are for s3.
+func (s *AuthService) SetService(f ServiceFunc) {
+	s.service = f
+}
+
+// requestService returns the service a request must be signed for
+func (s *AuthService) requestService(r *rest.Rest) string {
+	if s.service == nil {
+		return ServiceS3
+	}
+	return s.service(r)
+}
+
+// PostInit loads the config file. An invalid config stops the server from
+// starting rather than leaving it denying every request.
 func (s *AuthService) PostInit() error {
-	if *s.configFile != "" {
-		err := s.loadConfig()
-		if err != nil {
-			return nil
+	if *s.checkConfig {
+		if *s.configFile == "" {
+			return errors.New("-check-config requires -config")
 		}
+		if err := s.loadConfig(); err != nil {
+			return err
+		}
+		log.Printf("Config %s is valid", *s.configFile)
+		return ErrConfigChecked
+	}
+
+	if *s.configFile != "" {
+		return s.loadConfig()
 	}
 	return nil
 }
+
+// Start reloads the config file on SIGHUP or when it changes, and periodically
+// stores when access keys were used
+func (s *AuthService) Start() error {
+	if *s.configFile != "" {
+		s.stopWatch = utils.WatchFile(*s.configFile, utils.WatchInterval, s.reloadConfig)
+	}
+	s.stopKeysUsed = s.startKeysUsed()
+	return nil
+}
+
+func (s *AuthService) Stop() {
+	if s.stopWatch != nil {
+		s.stopWatch()
+	}
+	if s.stopKeysUsed != nil {
+		s.stopKeysUsed()
+	}
+}
+
+// reloadConfig reloads the config file. If it is invalid then the current
+// config remains in use.
+func (s *AuthService) reloadConfig() {
+	if err := s.loadConfig(); err != nil {
+		log.Printf("Rejected auth config %s: %v", *s.configFile, err)
+		return
+	}
+	log.Printf("Reloaded auth config %s", *s.configFile)
+}
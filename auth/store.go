@@ -0,0 +1,467 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/objectstore/awserror"
+	"github.com/peter-mount/objectstore/utils"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// The bbolt buckets holding users managed by the admin api.
+// These names start with \001 so they are not valid S3 bucket names.
+const (
+	// User name -> User
+	usersBucket = "\001users"
+	// Access key -> User name, for each of the user's access keys
+	accessKeysBucket = "\001accesskeys"
+	// Canonical id -> User name
+	canonicalIdsBucket = "\001canonicalids"
+)
+
+// Characters used in generated access keys
+const accessKeyChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+var (
+	ErrNoSuchUser        = errors.New("The specified user does not exist")
+	ErrUserExists        = errors.New("The specified user already exists")
+	ErrNoSuchAccessKey   = errors.New("The specified access key does not exist")
+	ErrTooManyAccessKeys = errors.New("The user already has the maximum number of access keys")
+)
+
+var userNamePattern = regexp.MustCompile("^[a-zA-Z0-9_+=,.@-]{1,64}$")
+
+// newAccessKey returns a new random access key and secret key pair
+func newAccessKey() (string, string, error) {
+	b := make([]byte, 20+30)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	accessKey := make([]byte, 20)
+	for i, c := range b[:20] {
+		accessKey[i] = accessKeyChars[int(c)%len(accessKeyChars)]
+	}
+
+	return string(accessKey), base64.StdEncoding.EncodeToString(b[20:]), nil
+}
+
+// getStoredUser returns a user stored in bbolt by their access key or nil
+func (s *AuthService) getStoredUser(accessKey string) *User {
+	var user *User
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		kb := tx.Bucket(accessKeysBucket)
+		if kb == nil {
+			return nil
+		}
+
+		name := kb.Get(accessKey)
+		if name == nil {
+			return nil
+		}
+
+		u, err := s.loadUser(tx, string(name))
+		user = u
+		return err
+	})
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// loadUser loads a stored user, returning ErrNoSuchUser if not found
+func (s *AuthService) loadUser(tx *bolt.Tx, name string) (*User, error) {
+	ub := tx.Bucket(usersBucket)
+	if ub == nil {
+		return nil, ErrNoSuchUser
+	}
+
+	v := ub.Get(name)
+	if v == nil {
+		return nil, ErrNoSuchUser
+	}
+
+	user, err := s.decodeUser(tx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	// Policies are resolved when loaded so changes to them take effect
+	if err := s.config().resolvePolicies(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// decodeUser decodes a stored user & sets when their keys were last used
+func (s *AuthService) decodeUser(tx *bolt.Tx, v []byte) (*User, error) {
+	user := &User{}
+	if err := json.Unmarshal(v, user); err != nil {
+		return nil, err
+	}
+
+	// Users stored before they could have more than one key
+	if user.AccessKey != "" && len(user.AccessKeys) == 0 {
+		user.AccessKeys = []AccessKey{{
+			AccessKey: user.AccessKey,
+			SecretKey: user.SecretKey,
+			Status:    AccessKeyActive,
+		}}
+	}
+	user.AccessKey, user.SecretKey = "", ""
+
+	s.keysLastUsed(tx, user)
+	return user, nil
+}
+
+// saveUser stores a user & indexes their access keys
+func (s *AuthService) saveUser(tx *bolt.Tx, user *User) error {
+	ub, err := tx.CreateBucketIfNotExists(usersBucket)
+	if err != nil {
+		return err
+	}
+
+	kb, err := tx.CreateBucketIfNotExists(accessKeysBucket)
+	if err != nil {
+		return err
+	}
+
+	v, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	err = ub.Put(user.Name, v)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range user.AccessKeys {
+		if err := kb.Put(k.AccessKey, []byte(user.Name)); err != nil {
+			return err
+		}
+	}
+
+	ib, err := s.canonicalIdIndex(tx)
+	if err != nil {
+		return err
+	}
+	return ib.Put(user.CanonicalId(), []byte(user.Name))
+}
+
+// canonicalIdIndex returns the index of the canonical ids of stored users.
+// Users stored before it existed are indexed the first time it is used so tx
+// must be writable.
+func (s *AuthService) canonicalIdIndex(tx *bolt.Tx) (*bolt.Bucket, error) {
+	if ib := tx.Bucket(canonicalIdsBucket); ib != nil {
+		return ib, nil
+	}
+
+	ib, err := tx.CreateBucket(canonicalIdsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	ub := tx.Bucket(usersBucket)
+	if ub == nil {
+		return ib, nil
+	}
+
+	err = ub.ForEach(func(k string, v []byte) error {
+		user := &User{}
+		if err := json.Unmarshal(v, user); err != nil {
+			return err
+		}
+		return ib.Put(user.CanonicalId(), []byte(k))
+	})
+	return ib, err
+}
+
+// ListUsers returns the users managed by the admin api, sorted by name
+func (s *AuthService) ListUsers() ([]*User, error) {
+	var users []*User
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		ub := tx.Bucket(usersBucket)
+		if ub == nil {
+			return nil
+		}
+
+		return ub.ForEach(func(k string, v []byte) error {
+			user, err := s.decodeUser(tx, v)
+			if err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+	})
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+
+	return users, err
+}
+
+// GetUser returns a user managed by the admin api
+func (s *AuthService) GetUser(name string) (*User, error) {
+	var user *User
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		u, err := s.loadUser(tx, name)
+		user = u
+		return err
+	})
+	return user, err
+}
+
+// newUserArn returns the arn of a user managed by the admin api
+func newUserArn(name string) *utils.ARN {
+	return utils.NewARN("arn", "aws", "iam", "", "", "user/"+name)
+}
+
+// CreateUser creates a new user with a new access key.
+// Only the user's name, groups, policies & quota are used from user.
+// The returned user includes the secret key of their access key.
+func (s *AuthService) CreateUser(user *User) (*User, error) {
+	if !userNamePattern.MatchString(user.Name) {
+		return nil, awserror.InvalidArgument("Invalid user name \"%s\"", user.Name)
+	}
+
+	// The arn keeps the canonical id the same when the access key is rotated.
+	// It's derived from the name so a new user cannot take the canonical id,
+	// and so the buckets & objects, of another user.
+	arn := newUserArn(user.Name)
+	if !user.Arn.IsNil() && !user.Arn.Equal(arn) {
+		return nil, awserror.InvalidArgument("The arn of user \"%s\" must be %s", user.Name, arn.String())
+	}
+
+	u := &User{
+		Name:     user.Name,
+		Arn:      *arn,
+		Groups:   user.Groups,
+		Policies: user.Policies,
+		Quota:    user.Quota,
+	}
+
+	if s.config().userInUse(u) {
+		return nil, ErrUserExists
+	}
+
+	// Reject unknown groups & policies now rather than when the user is used
+	if err := s.config().resolvePolicies(u); err != nil {
+		return nil, awserror.InvalidArgument("%s", err.Error())
+	}
+
+	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		if _, err := s.loadUser(tx, u.Name); err != ErrNoSuchUser {
+			if err == nil {
+				err = ErrUserExists
+			}
+			return err
+		}
+
+		// Users stored before the arn was derived could have any arn
+		ib, err := s.canonicalIdIndex(tx)
+		if err != nil {
+			return err
+		}
+		if ib.Get(u.CanonicalId()) != nil {
+			return ErrUserExists
+		}
+
+		_, err = s.newUserKey(tx, u, nil)
+		if err != nil {
+			return err
+		}
+
+		return s.saveUser(tx, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// newUserKey adds a new unique access key to a user
+func (s *AuthService) newUserKey(tx *bolt.Tx, user *User, expires *time.Time) (*AccessKey, error) {
+	if len(user.AccessKeys) >= maxAccessKeys {
+		return nil, ErrTooManyAccessKeys
+	}
+
+	for {
+		accessKey, secretKey, err := newAccessKey()
+		if err != nil {
+			return nil, err
+		}
+
+		// Practically impossible but the key must be unique
+		if s.accessKeyInUse(tx, accessKey) {
+			continue
+		}
+
+		now := time.Now().UTC().Truncate(time.Second)
+		user.AccessKeys = append(user.AccessKeys, AccessKey{
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+			Status:    AccessKeyActive,
+			Created:   &now,
+			Expires:   expires,
+		})
+		return &user.AccessKeys[len(user.AccessKeys)-1], nil
+	}
+}
+
+// accessKeyInUse returns true if an access key is already used by a user or
+// sts session
+func (s *AuthService) accessKeyInUse(tx *bolt.Tx, accessKey string) bool {
+	if _, exists := s.config().accessKeys[accessKey]; exists || accessKey == s.config().Root.AccessKey {
+		return true
+	}
+
+	for _, name := range []string{accessKeysBucket, sessionsBucket} {
+		if b := tx.Bucket(name); b != nil && b.Get(accessKey) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CreateAccessKey adds a new access key to a user. expires if not nil is when
+// the key stops working. A user can have two keys so they can be rotated by
+// creating a new key, changing clients to use it then deleting the old one.
+// The returned key includes the secret key.
+func (s *AuthService) CreateAccessKey(name string, expires *time.Time) (*AccessKey, error) {
+	if expires != nil && !expires.After(time.Now()) {
+		return nil, awserror.InvalidArgument("The expiry date must be in the future")
+	}
+
+	var key *AccessKey
+	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		u, err := s.loadUser(tx, name)
+		if err != nil {
+			return err
+		}
+
+		k, err := s.newUserKey(tx, u, expires)
+		if err != nil {
+			return err
+		}
+
+		key = k
+		return s.saveUser(tx, u)
+	})
+	return key, err
+}
+
+// SetAccessKeyStatus makes one of a user's access keys Active or Inactive.
+// An Inactive key cannot authenticate.
+func (s *AuthService) SetAccessKeyStatus(name, accessKey, status string) (*AccessKey, error) {
+	if status != AccessKeyActive && status != AccessKeyInactive {
+		return nil, awserror.InvalidArgument("Invalid status \"%s\"", status)
+	}
+
+	var key *AccessKey
+	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		u, err := s.loadUser(tx, name)
+		if err != nil {
+			return err
+		}
+
+		for i := range u.AccessKeys {
+			if u.AccessKeys[i].AccessKey == accessKey {
+				u.AccessKeys[i].Status = status
+				key = &u.AccessKeys[i]
+				return s.saveUser(tx, u)
+			}
+		}
+
+		return ErrNoSuchAccessKey
+	})
+	return key, err
+}
+
+// DeleteAccessKey deletes one of a user's access keys
+func (s *AuthService) DeleteAccessKey(name, accessKey string) error {
+	return s.boltService.Update(func(tx *bolt.Tx) error {
+		u, err := s.loadUser(tx, name)
+		if err != nil {
+			return err
+		}
+
+		for i, k := range u.AccessKeys {
+			if k.AccessKey == accessKey {
+				u.AccessKeys = append(u.AccessKeys[:i], u.AccessKeys[i+1:]...)
+				if err := s.deleteKeyIndex(tx, accessKey); err != nil {
+					return err
+				}
+				return s.saveUser(tx, u)
+			}
+		}
+
+		return ErrNoSuchAccessKey
+	})
+}
+
+// deleteKeyIndex removes an access key from the index & when it was last used
+func (s *AuthService) deleteKeyIndex(tx *bolt.Tx, accessKey string) error {
+	for _, name := range []string{accessKeysBucket, lastUsedBucket} {
+		if b := tx.Bucket(name); b != nil {
+			if err := b.Delete(accessKey); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SetUserDisabled disables or enables a user. A disabled user cannot authenticate.
+func (s *AuthService) SetUserDisabled(name string, disabled bool) (*User, error) {
+	var user *User
+	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		u, err := s.loadUser(tx, name)
+		if err != nil {
+			return err
+		}
+
+		u.Disabled = disabled
+		user = u
+		return s.saveUser(tx, u)
+	})
+	return user, err
+}
+
+// DeleteUser deletes a user
+func (s *AuthService) DeleteUser(name string) error {
+	return s.boltService.Update(func(tx *bolt.Tx) error {
+		u, err := s.loadUser(tx, name)
+		if err != nil {
+			return err
+		}
+
+		for _, k := range u.AccessKeys {
+			if err := s.deleteKeyIndex(tx, k.AccessKey); err != nil {
+				return err
+			}
+		}
+
+		ib, err := s.canonicalIdIndex(tx)
+		if err != nil {
+			return err
+		}
+		err = ib.Delete(u.CanonicalId())
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(usersBucket).Delete(name)
+	})
+}
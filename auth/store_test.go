@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"github.com/peter-mount/objectstore/awserror"
+	"github.com/peter-mount/objectstore/utils"
+	"testing"
+)
+
+const testUsersConfig = `
+rootUser:
+  accessKey: ROOTKEY
+  secretKey: rootsecret
+  arn: "arn:aws:iam:::user/admin"
+users:
+  bob:
+    accessKeys:
+      - accessKey: BOBKEY
+        secretKey: bobsecret
+  robert:
+    arn: "arn:aws:iam:::user/rob"
+    accessKeys:
+      - accessKey: ROBKEY
+        secretKey: robsecret
+`
+
+func TestConfig_userInUse(t *testing.T) {
+	c := testAuthService(t, testUsersConfig).config()
+
+	for _, test := range []struct {
+		name  string
+		inUse bool
+	}{
+		{name: "alice"},
+		// The name of a user in the config
+		{name: "bob", inUse: true},
+		// The canonical id of robert
+		{name: "rob", inUse: true},
+		// The canonical id of root
+		{name: "admin", inUse: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			user := &User{Name: test.name, Arn: *newUserArn(test.name)}
+			if got := c.userInUse(user); got != test.inUse {
+				t.Errorf("got %v want %v", got, test.inUse)
+			}
+		})
+	}
+}
+
+func TestAuthService_CreateUser_rejected(t *testing.T) {
+	s := testAuthService(t, testUsersConfig)
+
+	for _, test := range []struct {
+		name string
+		user *User
+		err  error
+		code string
+	}{
+		{name: "invalid name", user: &User{Name: "a/b"}, code: "InvalidArgument"},
+		{name: "another user's arn", user: &User{Name: "mallory", Arn: *utils.NewARN("arn", "aws", "iam", "", "", "user/rob")}, code: "InvalidArgument"},
+		{name: "root's arn", user: &User{Name: "mallory", Arn: *newUserArn("admin")}, code: "InvalidArgument"},
+		{name: "config user", user: &User{Name: "bob"}, err: ErrUserExists},
+		{name: "config user's canonical id", user: &User{Name: "rob"}, err: ErrUserExists},
+		{name: "root's canonical id", user: &User{Name: "admin"}, err: ErrUserExists},
+		// The derived arn can be given
+		{name: "derived arn", user: &User{Name: "bob", Arn: *newUserArn("bob")}, err: ErrUserExists},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := s.CreateUser(test.user)
+			switch {
+			case err == nil:
+				t.Errorf("expected an error")
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %v expected %s", err, test.code)
+			case test.err != nil && err != test.err:
+				t.Errorf("got %v expected %v", err, test.err)
+			}
+		})
+	}
+}
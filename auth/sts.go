@@ -0,0 +1,360 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/objectstore/awserror"
+	"github.com/peter-mount/objectstore/condition"
+	"github.com/peter-mount/objectstore/policy"
+	"github.com/peter-mount/objectstore/utils"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The bbolt bucket holding sts sessions, keyed by access key
+const sessionsBucket = "\001sessions"
+
+// Session durations
+const (
+	// The shortest session
+	minSessionDuration = 15 * time.Minute
+	// The default AssumeRole session & the default maximum of a role
+	defaultRoleSessionDuration = time.Hour
+	// The longest a role can allow
+	maxRoleSessionDuration = 12 * time.Hour
+	// The default GetSessionToken session
+	defaultSessionDuration = 12 * time.Hour
+	// The longest GetSessionToken session
+	maxSessionDuration = 36 * time.Hour
+)
+
+// The largest session policy allowed
+const maxSessionPolicySize = 2048
+
+var roleSessionNamePattern = regexp.MustCompile("^[a-zA-Z0-9_+=,.@-]{2,64}$")
+
+// rootSessionPolicy is the identity policy of a root GetSessionToken session.
+// It allows everything but unlike root it is evaluated, so explicit denies
+// still apply.
+var rootSessionPolicy = func() *policy.Policy {
+	p, err := policy.Parse([]byte(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"*","Resource":"*"}]}`))
+	if err != nil {
+		panic(err)
+	}
+	return p
+}()
+
+// A role which can be assumed with sts AssumeRole
+type Role struct {
+	// The arn of the role, e.g. arn:aws:iam::123456789012:role/batch
+	Arn utils.ARN `yaml:"arn"`
+	// Who can assume the role
+	TrustPolicy TrustPolicy `yaml:"trustPolicy"`
+	// Names of managed policies attached to the role
+	Policies []string `yaml:"policies"`
+	// Policies embedded in the role
+	InlinePolicies map[string]PolicyDocument `yaml:"inlinePolicies"`
+	// The longest session allowed, e.g. "4h". Defaults to 1h
+	MaxSessionDuration string `yaml:"maxSessionDuration"`
+	// The parsed MaxSessionDuration
+	maxSessionDuration time.Duration
+	// The resolved identity policies of the role
+	policies []*policy.Policy
+}
+
+// Session is a set of temporary credentials issued by sts
+type Session struct {
+	AccessKey    string    `json:"accessKey"`
+	SecretKey    string    `json:"secretKey"`
+	SessionToken string    `json:"sessionToken"`
+	Expiration   time.Time `json:"expiration"`
+	// The access key of the user the session was issued to, "" for
+	// AssumeRoleWithWebIdentity
+	User string `json:"user,omitempty"`
+	// The name of the web identity provider the session was issued to
+	Provider string `json:"provider,omitempty"`
+	// The provider's name used in trust policies
+	Issuer string `json:"issuer,omitempty"`
+	// The subject of the web identity token
+	Subject string `json:"subject,omitempty"`
+	// The audience of the web identity token
+	Audience string `json:"audience,omitempty"`
+	// The name of the role assumed, "" for GetSessionToken
+	Role string `json:"role,omitempty"`
+	// The arn of the assumed role session
+	AssumedRoleArn string `json:"assumedRoleArn,omitempty"`
+	// The id of the assumed role session
+	AssumedRoleId string `json:"assumedRoleId,omitempty"`
+	// The session policy passed to AssumeRole
+	Policy string `json:"policy,omitempty"`
+}
+
+// getRole returns the name & role with an arn
+func (c *config) getRole(arn string) (string, *Role) {
+	for k, v := range c.Roles {
+		if v.Arn.String() == arn {
+			return k, &v
+		}
+	}
+	return "", nil
+}
+
+// AssumeRole issues temporary credentials for a role. The role's trust policy
+// must allow the credential sts:AssumeRole.
+// ctx is the context the trust policy's conditions are evaluated against.
+// duration is the length of the session, 0 for the default.
+// sessionPolicy if not "" limits what the session can do.
+func (s *AuthService) AssumeRole(cred *Credential, ctx condition.Context, roleArn, sessionName string, duration time.Duration, sessionPolicy string) (*Session, error) {
+	if !cred.IsAuthenticated() || cred.IsSession() {
+		return nil, awserror.AccessDenied()
+	}
+
+	return s.assumeRole(
+		&policy.Request{
+			Principal:   cred.Principal(),
+			CanonicalId: cred.CanonicalId(),
+			Action:      "sts:AssumeRole",
+			Context:     ctx,
+		},
+		&Session{User: cred.AccessKey()},
+		roleArn, sessionName, duration, sessionPolicy)
+}
+
+// assumeRole creates a session for a role if it's trust policy allows req.
+// session holds who the session is being issued to.
+func (s *AuthService) assumeRole(req *policy.Request, session *Session, roleArn, sessionName string, duration time.Duration, sessionPolicy string) (*Session, error) {
+	if roleArn == "" {
+		return nil, awserror.ValidationError("RoleArn is required")
+	}
+
+	if !roleSessionNamePattern.MatchString(sessionName) {
+		return nil, awserror.ValidationError("Invalid RoleSessionName %q", sessionName)
+	}
+
+	name, role := s.config().getRole(roleArn)
+	if role == nil {
+		return nil, awserror.AccessDenied()
+	}
+
+	if duration == 0 {
+		duration = defaultRoleSessionDuration
+	}
+	if duration < minSessionDuration || duration > role.maxSessionDuration {
+		return nil, awserror.ValidationError("DurationSeconds must be between %d and %d", int(minSessionDuration.Seconds()), int(role.maxSessionDuration.Seconds()))
+	}
+
+	if sessionPolicy != "" {
+		if len(sessionPolicy) > maxSessionPolicySize {
+			return nil, awserror.ValidationError("Policy must be no longer than %d characters", maxSessionPolicySize)
+		}
+		if err := (&PolicyDocument{}).parse([]byte(sessionPolicy)); err != nil {
+			return nil, awserror.MalformedPolicyDocument("%s", err.Error())
+		}
+	}
+
+	req.Resource = &role.Arn
+	decision, _ := role.TrustPolicy.Policy().Evaluate(req)
+	if !decision.Allowed() {
+		return nil, awserror.AccessDenied()
+	}
+
+	// The role's name is the last part of it's path
+	roleName := role.Arn.Resource[strings.LastIndex(role.Arn.Resource, "/")+1:]
+	h := sha256.Sum256([]byte(role.Arn.String()))
+
+	session.Role = name
+	session.AssumedRoleArn = utils.NewARN("arn", role.Arn.Partition, "sts", "", role.Arn.Account, "assumed-role/"+roleName+"/"+sessionName).String()
+	session.AssumedRoleId = "AROA" + strings.ToUpper(hex.EncodeToString(h[:]))[:17] + ":" + sessionName
+	session.Policy = sessionPolicy
+	return s.newSession(session, duration)
+}
+
+// GetSessionToken issues temporary credentials which act as the credential's
+// user, except that a session of root is not root.
+// duration is the length of the session, 0 for the default.
+func (s *AuthService) GetSessionToken(cred *Credential, duration time.Duration) (*Session, error) {
+	if !cred.IsAuthenticated() || cred.IsSession() {
+		return nil, awserror.AccessDenied()
+	}
+
+	if duration == 0 {
+		duration = defaultSessionDuration
+	}
+	if duration < minSessionDuration || duration > maxSessionDuration {
+		return nil, awserror.ValidationError("DurationSeconds must be between %d and %d", int(minSessionDuration.Seconds()), int(maxSessionDuration.Seconds()))
+	}
+
+	return s.newSession(&Session{User: cred.AccessKey()}, duration)
+}
+
+// newSession creates new credentials for a session & stores it.
+// Expired sessions are removed at the same time.
+func (s *AuthService) newSession(session *Session, duration time.Duration) (*Session, error) {
+	token := make([]byte, 64)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	session.SessionToken = base64.StdEncoding.EncodeToString(token)
+
+	now := time.Now().UTC()
+	session.Expiration = now.Add(duration).Truncate(time.Second)
+
+	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		if err != nil {
+			return err
+		}
+
+		var expired []string
+		err = b.ForEach(func(k string, v []byte) error {
+			e := &Session{}
+			if json.Unmarshal(v, e) != nil || now.After(e.Expiration) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		for {
+			accessKey, secretKey, err := newAccessKey()
+			if err != nil {
+				return err
+			}
+
+			// Temporary access keys start with ASIA
+			accessKey = "ASIA" + accessKey[4:]
+			if s.accessKeyInUse(tx, accessKey) {
+				continue
+			}
+
+			session.AccessKey = accessKey
+			session.SecretKey = secretKey
+			break
+		}
+
+		v, err := json.Marshal(session)
+		if err != nil {
+			return err
+		}
+		return b.Put(session.AccessKey, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// getSessionUser returns the user for a session or nil if there isn't one
+func (s *AuthService) getSessionUser(accessKey string) *User {
+	session := &Session{}
+	found := false
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(sessionsBucket)
+		if b == nil {
+			return nil
+		}
+
+		v := b.Get(accessKey)
+		if v == nil {
+			return nil
+		}
+
+		found = true
+		return json.Unmarshal(v, session)
+	})
+	if err != nil || !found {
+		return nil
+	}
+
+	return s.sessionUser(session)
+}
+
+// sessionUser returns the user for a session or nil if the user or web
+// identity provider it was issued to, or the role it assumed, no longer exist.
+func (s *AuthService) sessionUser(session *Session) *User {
+	var source *User
+	if session.Provider != "" {
+		if _, exists := s.config().WebIdentityProviders[session.Provider]; !exists {
+			return nil
+		}
+	} else {
+		source = s.getUser(session.User)
+		if source == nil || source.session != nil {
+			return nil
+		}
+	}
+
+	user := &User{
+		AccessKey: session.AccessKey,
+		SecretKey: session.SecretKey,
+		session:   session,
+	}
+
+	// Temporary credentials are never root, so every request they make is
+	// evaluated against policies
+	if session.Role == "" {
+		// GetSessionToken acts as the user
+		user.Arn = source.Arn
+		user.policies = source.policies
+		user.source = source
+		if source.root {
+			user.policies = []*policy.Policy{rootSessionPolicy}
+		}
+	} else {
+		role, exists := s.config().Roles[session.Role]
+		if !exists {
+			return nil
+		}
+		// The principal is the session, arn:aws:sts::account:assumed-role/role/session
+		if user.Arn.Parse(session.AssumedRoleArn) != nil {
+			return nil
+		}
+		user.policies = role.policies
+	}
+
+	if session.Policy != "" {
+		p := &PolicyDocument{}
+		if p.parse([]byte(session.Policy)) != nil {
+			return nil
+		}
+		user.sessionPolicy = p.Policy()
+	}
+
+	return user
+}
+
+// checkSessionToken checks the session token sent with a request.
+// Temporary credentials must have their token & it must not have expired,
+// other credentials must not have one.
+func checkSessionToken(user *User, token string) error {
+	if user.session == nil {
+		if token != "" {
+			return awserror.InvalidToken()
+		}
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(user.session.SessionToken)) != 1 {
+		return awserror.InvalidToken()
+	}
+
+	if time.Now().After(user.session.Expiration) {
+		return awserror.ExpiredToken()
+	}
+
+	return nil
+}
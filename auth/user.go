@@ -1,32 +1,115 @@
 package auth
 
 import (
+  "crypto/sha256"
+  "encoding/hex"
+  "github.com/peter-mount/objectstore/policy"
   "github.com/peter-mount/objectstore/utils"
 )
 
 type User struct {
-  // The users AccessKey
-  AccessKey   string      `json:"accessKey" yaml:"accessKey"`
-  // The users SecretKey
-  SecretKey   string      `json:"secretKey" yaml:"secretKey"`
+  // The users name, the key in the config's users or set by the admin api
+  Name        string      `json:"name,omitempty" yaml:"-"`
+  // The AccessKey the user authenticated with. In the config this is root's
+  // key, or for older configs where users are keyed by access key it's the
+  // user's only key
+  AccessKey   string      `json:"accessKey,omitempty" yaml:"accessKey"`
+  // The SecretKey of AccessKey
+  SecretKey   string      `json:"secretKey,omitempty" yaml:"secretKey"`
+  // The users access keys
+  AccessKeys  []AccessKey `json:"accessKeys,omitempty" yaml:"accessKeys"`
   // The users arn
   Arn         utils.ARN   `json:"arn" yaml:"arn"`
+  // Groups the user is a member of
+  Groups          []string                    `json:"groups,omitempty" yaml:"groups"`
+  // Names of managed policies attached to the user
+  Policies        []string                    `json:"policies,omitempty" yaml:"policies"`
+  // Policies embedded in the user
+  InlinePolicies  map[string]PolicyDocument   `json:"-" yaml:"inlinePolicies"`
+  // The user's storage quota, if not set the default user quota applies
+  Quota           *Quota                      `json:"quota,omitempty" yaml:"quota"`
+  // true if the user is not allowed to authenticate
+  Disabled        bool                        `json:"disabled,omitempty" yaml:"disabled"`
   // true if this user is root
   root        bool
+  // The resolved identity policies of the user
+  policies    []*policy.Policy
+  // The sts session if these are temporary credentials
+  session     *Session
+  // The user a GetSessionToken session acts as
+  source      *User
+  // The policy passed to AssumeRole limiting what the session can do
+  sessionPolicy *policy.Policy
 }
 
 // GetUser returns a User for an accessKey
 func (s *AuthService) getUser( accessKey string ) *User {
   // Root overrides everything
-  if accessKey == s.config.Root.AccessKey {
-    return &s.config.Root
+  if accessKey == s.config().Root.AccessKey {
+    return &s.config().Root
   }
 
-  if user, exists := s.config.Users[ accessKey ]; exists {
-    return &user
+  if name, exists := s.config().accessKeys[ accessKey ]; exists {
+    user := s.config().Users[ name ]
+    return user.withKey( accessKey )
   }
 
-  return nil
+  // Users managed by the admin api
+  if user := s.getStoredUser( accessKey ); user != nil {
+    return user.withKey( accessKey )
+  }
+
+  // Temporary credentials issued by sts
+  return s.getSessionUser( accessKey )
+}
+
+// userInUse returns true if a user's name or canonical id is already used by
+// root or one of the users in the config
+func (c *config) userInUse( user *User ) bool {
+  if _, exists := c.Users[ user.Name ]; exists {
+    return true
+  }
+
+  id := user.CanonicalId()
+  _, exists := c.canonicalIds[ id ]
+  return exists || id == c.Root.CanonicalId()
+}
+
+// CanonicalId returns the canonical id of this user as used in ACL's.
+// This is derived from the users arn, or if not set their name, so it does not
+// change when their access keys do. Root without an arn uses it's accessKey.
+func (s *User) CanonicalId() string {
+  if s == nil {
+    return ""
+  }
+  if s.source != nil {
+    return s.source.CanonicalId()
+  }
+  id := s.Name
+  if !s.Arn.IsNil() {
+    id = s.Arn.String()
+  } else if id == "" {
+    id = s.AccessKey
+  }
+  h := sha256.Sum256( []byte( id ) )
+  return hex.EncodeToString( h[:] )
+}
+
+// DisplayName returns the name shown for this user in ACL's
+func (s *User) DisplayName() string {
+  if s == nil {
+    return ""
+  }
+  if s.source != nil {
+    return s.source.DisplayName()
+  }
+  if !s.Arn.IsNil() && s.Arn.Resource != "" {
+    return s.Arn.Resource
+  }
+  if s.Name != "" {
+    return s.Name
+  }
+  return s.AccessKey
 }
 
 func (s *User) IsRoot() bool {
@@ -37,5 +120,8 @@ func (s *User) String() string {
   if s == nil {
     return "nil"
   }
+  if s.Name != "" {
+    return s.Name
+  }
   return s.AccessKey
 }
@@ -1,12 +1,19 @@
 package auth
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"regexp"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -20,19 +27,61 @@ const (
 // if object matches reserved string, no need to encode them
 var reservedObjectNames = regexp.MustCompile("^[a-zA-Z0-9-_.~/]+$")
 
-func getSigningDate(r *rest.Rest) string {
-	v, ok := r.Request().Header["X-Amz-Date"]
-	if !ok {
-		v, ok = r.Request().Header["Date"]
+// getSigningDate returns the X-Amz-Date or Date header and the time it represents.
+// X-Amz-Date is in ISO8601 format, Date is in any of the http formats & is
+// returned as ISO8601 as that's how it's used in the string to sign.
+func getSigningDate(header http.Header) (string, time.Time, bool) {
+	if v := header.Get("X-Amz-Date"); v != "" {
+		t, err := time.Parse(iso8601DateFormat, v)
+		return v, t, err == nil
 	}
-	return v[0]
+
+	t, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return "", t, false
+	}
+	t = t.UTC()
+	return t.Format(iso8601DateFormat), t, true
+}
+
+// checkClockSkew returns RequestTimeTooSkewed if a request's time is too far
+// from our clock
+func (s *AuthService) checkClockSkew(t time.Time) error {
+	skew := time.Since(t)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > s.config().clockSkew {
+		return awserror.RequestTimeTooSkewed()
+	}
+	return nil
+}
+
+// checkScope checks the date, region & service of a V4 credential scope
+func (s *AuthService) checkScope(r *rest.Rest, t, date, location, service string) error {
+	return checkCredentialScope(t, date, location, service, s.requestRegion(r), s.requestService(r))
+}
+
+// checkCredentialScope checks a V4 credential scope is for the signing date t, region
+// & service. region may be "" for any region.
+func checkCredentialScope(t, date, location, service, region, expectedService string) error {
+	if date != t[0:8] {
+		return awserror.AuthorizationHeaderMalformed("Invalid credential date \"%s\". This date is not the same as X-Amz-Date: \"%s\".", date, t[0:8])
+	}
+	if region != "" && location != region {
+		return awserror.AuthorizationHeaderMalformed("the region '%s' is wrong; expecting '%s'", location, region).WithRegion(region)
+	}
+	if service != expectedService {
+		return awserror.AuthorizationHeaderMalformed("incorrect service \"%s\". This endpoint belongs to \"%s\".", service, expectedService)
+	}
+	return nil
 }
 
 // getSigningKey hmac seed to calculate final signature.
-func getSigningKey(secret, loc string, t string) []byte {
+func getSigningKey(secret, loc, svc string, t string) []byte {
 	date := sumHMAC([]byte("AWS4"+secret), []byte(t[0:8]))
 	location := sumHMAC(date, []byte(loc))
-	service := sumHMAC(location, []byte("s3"))
+	service := sumHMAC(location, []byte(svc))
 	signingKey := sumHMAC(service, []byte("aws4_request"))
 	return signingKey
 }
@@ -44,11 +93,11 @@ func getSignature(signingKey []byte, stringToSign string) string {
 
 // getScope generate a string of a specific date, an AWS region, and a
 // service.
-func getScope(location string, t string) string {
+func getScope(location, service string, t string) string {
 	scope := strings.Join([]string{
 		t[0:8],
 		location,
-		"s3",
+		service,
 		"aws4_request",
 	}, "/")
 	return scope
@@ -65,9 +114,32 @@ func getHashedPayload(r *rest.Rest) string {
 	return hashedPayload
 }
 
+// The largest request body read to calculate it's hash
+const maxSignedBodySize = 1 << 20
+
+// readBody reads the request body so it's hash can be calculated, replacing
+// it so the handler can still read it
+func readBody(r *rest.Rest) ([]byte, error) {
+	req := r.Request()
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(req.Body, maxSignedBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxSignedBodySize {
+		return nil, awserror.EntityTooLarge()
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
 // GetCredential generate a credential string.
 func getCredential(accessKeyID, location string, t string) string {
-	scope := getScope(location, t)
+	scope := getScope(location, ServiceS3, t)
 	return accessKeyID + "/" + scope
 }
 
@@ -85,9 +157,27 @@ func sumHMAC(key []byte, data []byte) []byte {
 	return hash.Sum(nil)
 }
 
+// The context key holding the path of a request before it was rewritten
+type contextKey int
+
+const originalPathKey contextKey = iota
+
+// WithOriginalPath records the path a request was sent with before it was
+// rewritten, e.g. for virtual hosted style requests
+func WithOriginalPath(req *http.Request, path string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), originalPathKey, path))
+}
+
+// OriginalPath returns the path a request was sent with
+func OriginalPath(req *http.Request) string {
+	if path, ok := req.Context().Value(originalPathKey).(string); ok {
+		return path
+	}
+	return req.URL.Path
+}
+
 // getHostAddr returns host header if available, otherwise returns host from URL
-func getHostAddr(r *rest.Rest) string {
-	req := r.Request()
+func getHostAddr(req *http.Request) string {
 	if req.Host != "" {
 		return req.Host
 	}
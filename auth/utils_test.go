@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"github.com/peter-mount/objectstore/awserror"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetSigningDate(t *testing.T) {
+	want := time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name    string
+		amzDate string
+		date    string
+		value   string
+		ok      bool
+	}{
+		{name: "X-Amz-Date", amzDate: "20130524T000000Z", value: "20130524T000000Z", ok: true},
+		{name: "X-Amz-Date preferred", amzDate: "20130524T000000Z", date: "Fri, 24 May 2013 01:00:00 GMT", value: "20130524T000000Z", ok: true},
+		{name: "invalid X-Amz-Date", amzDate: "Fri, 24 May 2013 00:00:00 GMT"},
+		{name: "RFC1123 Date", date: "Fri, 24 May 2013 00:00:00 GMT", value: "20130524T000000Z", ok: true},
+		{name: "RFC850 Date", date: "Friday, 24-May-13 00:00:00 GMT", value: "20130524T000000Z", ok: true},
+		{name: "ANSI C Date", date: "Fri May 24 00:00:00 2013", value: "20130524T000000Z", ok: true},
+		{name: "ISO8601 Date", date: "20130524T000000Z"},
+		{name: "missing"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.amzDate != "" {
+				header.Set("X-Amz-Date", test.amzDate)
+			}
+			if test.date != "" {
+				header.Set("Date", test.date)
+			}
+
+			value, date, ok := getSigningDate(header)
+			if ok != test.ok {
+				t.Fatalf("got %v want %v", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if value != test.value {
+				t.Errorf("got %q want %q", value, test.value)
+			}
+			if !date.Equal(want) {
+				t.Errorf("got %v want %v", date, want)
+			}
+		})
+	}
+}
+
+func TestAuthService_checkClockSkew(t *testing.T) {
+	s := &AuthService{}
+	s.current.Store(newConfig())
+
+	for _, test := range []struct {
+		name   string
+		offset time.Duration
+		code   string
+	}{
+		{name: "now"},
+		{name: "behind", offset: -defaultClockSkew + time.Minute},
+		{name: "ahead", offset: defaultClockSkew - time.Minute},
+		{name: "too far behind", offset: -defaultClockSkew - time.Minute, code: "RequestTimeTooSkewed"},
+		{name: "too far ahead", offset: defaultClockSkew + time.Minute, code: "RequestTimeTooSkewed"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.checkClockSkew(time.Now().Add(test.offset))
+			switch {
+			case test.code == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case test.code != "" && err == nil:
+				t.Errorf("expected %s", test.code)
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %s expected %s", awserror.ToError(err).Code, test.code)
+			}
+		})
+	}
+}
+
+func TestCheckCredentialScope(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		date     string
+		location string
+		service  string
+		region   string
+		code     string
+		want     string
+	}{
+		{name: "valid", date: "20130524", location: "eu-west-2", service: ServiceS3, region: "eu-west-2"},
+		{name: "any region", date: "20130524", location: "eu-west-2", service: ServiceS3},
+		{name: "region mismatch", date: "20130524", location: "us-east-1", service: ServiceS3, region: "eu-west-2", code: "AuthorizationHeaderMalformed", want: "eu-west-2"},
+		{name: "date mismatch", date: "20130523", location: "eu-west-2", service: ServiceS3, region: "eu-west-2", code: "AuthorizationHeaderMalformed"},
+		{name: "service mismatch", date: "20130524", location: "eu-west-2", service: ServiceSTS, region: "eu-west-2", code: "AuthorizationHeaderMalformed"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkCredentialScope("20130524T000000Z", test.date, test.location, test.service, test.region, ServiceS3)
+			switch {
+			case test.code == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case test.code != "" && err == nil:
+				t.Errorf("expected %s", test.code)
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %s expected %s", awserror.ToError(err).Code, test.code)
+			case test.code != "" && awserror.ToError(err).Region != test.want:
+				// The error tells the client which region to use
+				t.Errorf("got region %q want %q", awserror.ToError(err).Region, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package auth
+
+import (
+  "errors"
+  "fmt"
+  "sort"
+  "strings"
+  "time"
+)
+
+// configErrors collects the errors found when validating a config file
+type configErrors struct {
+  filename    string
+  yml         []byte
+  errs        []error
+}
+
+// add records an error against the yaml key at path
+func (e *configErrors) add( msg string, path ...string ) {
+  p := strings.Join( path, "." )
+  if line := yamlLine( e.yml, path... ); line > 0 {
+    e.errs = append( e.errs, fmt.Errorf( "%s:%d: %s: %s", e.filename, line, p, msg ) )
+  } else {
+    e.errs = append( e.errs, fmt.Errorf( "%s: %s: %s", e.filename, p, msg ) )
+  }
+}
+
+// err returns all of the errors or nil if there were none
+func (e *configErrors) err() error {
+  return errors.Join( e.errs... )
+}
+
+// validate checks the config is usable, returning every problem found rather
+// than just the first one
+func (c *config) validate( e *configErrors ) error {
+  if c.Auth.ClockSkew != "" {
+    d, err := time.ParseDuration( c.Auth.ClockSkew )
+    if err != nil || d <= 0 {
+      e.add( fmt.Sprintf( "invalid duration %q", c.Auth.ClockSkew ), "auth", "clockSkew" )
+    }
+    c.clockSkew = d
+  }
+
+  // Root is optional but if present both keys are required
+  if c.Root.AccessKey != "" || c.Root.SecretKey != "" {
+    if c.Root.AccessKey == "" {
+      e.add( "accessKey is required", "rootUser" )
+    }
+    if c.Root.SecretKey == "" {
+      e.add( "secretKey is required", "rootUser" )
+    }
+  }
+  c.validateArn( e, &c.Root, "rootUser" )
+
+  c.accessKeys = make( map[string]string )
+  c.canonicalIds = make( map[string]string )
+  for _, k := range sortedKeys( c.Users ) {
+    user := c.Users[k]
+    user.Name = k
+
+    // Older configs key users by their only access key
+    if user.AccessKey != "" || user.SecretKey != "" {
+      switch {
+        case len( user.AccessKeys ) > 0:
+          e.add( "use either accessKeys or secretKey", "users", k )
+        case user.AccessKey != "" && user.AccessKey != k:
+          e.add( fmt.Sprintf( "accessKey %q does not match the key in users", user.AccessKey ), "users", k, "accessKey" )
+        case user.SecretKey == "":
+          e.add( "secretKey is required", "users", k )
+        default:
+          user.AccessKeys = []AccessKey{{AccessKey: k, SecretKey: user.SecretKey}}
+      }
+      user.AccessKey, user.SecretKey = "", ""
+    }
+
+    if len( user.AccessKeys ) == 0 {
+      e.add( "accessKeys is required", "users", k )
+    }
+
+    for i := range user.AccessKeys {
+      key := &user.AccessKeys[i]
+      switch {
+        case key.AccessKey == "":
+          e.add( "empty access key", "users", k, "accessKeys" )
+        case key.AccessKey == c.Root.AccessKey:
+          e.add( fmt.Sprintf( "access key %q is used by rootUser", key.AccessKey ), "users", k, "accessKeys" )
+        case c.accessKeys[key.AccessKey] != "":
+          e.add( fmt.Sprintf( "access key %q is also used by user %s", key.AccessKey, c.accessKeys[key.AccessKey] ), "users", k, "accessKeys" )
+        default:
+          c.accessKeys[key.AccessKey] = k
+      }
+
+      if key.SecretKey == "" {
+        e.add( fmt.Sprintf( "secretKey is required for access key %q", key.AccessKey ), "users", k, "accessKeys" )
+      }
+
+      if err := key.validateStatus(); err != nil {
+        e.add( fmt.Sprintf( "access key %q has an %v", key.AccessKey, err ), "users", k, "accessKeys" )
+      }
+    }
+
+    c.validateArn( e, &user, "users", k )
+
+    if err := c.resolvePolicies( &user ); err != nil {
+      e.add( err.Error(), "users", k )
+    }
+
+    c.validateQuota( e, user.Quota, "users", k, "quota" )
+
+    c.canonicalIds[user.CanonicalId()] = k
+    c.Users[k] = user
+  }
+
+  for _, k := range sortedKeys( c.Groups ) {
+    for _, p := range c.Groups[k].Policies {
+      if _, exists := c.Policies[p]; !exists {
+        e.add( fmt.Sprintf( "Unknown policy %q", p ), "groups", k )
+      }
+    }
+  }
+
+  arns := make( map[string]string )
+  for _, k := range sortedKeys( c.Roles ) {
+    role := c.Roles[k]
+
+    a := role.Arn
+    switch {
+      case a.IsNil():
+        e.add( "arn is required", "roles", k )
+      case a.Type != "arn" || a.Service != "iam" || !strings.HasPrefix( a.Resource, "role/" ):
+        e.add( fmt.Sprintf( "invalid role arn %q", a.String() ), "roles", k, "arn" )
+      case arns[a.String()] != "":
+        e.add( fmt.Sprintf( "arn is also used by role %s", arns[a.String()] ), "roles", k, "arn" )
+      default:
+        arns[a.String()] = k
+    }
+
+    if role.TrustPolicy.Policy() == nil {
+      e.add( "trustPolicy is required", "roles", k )
+    }
+
+    role.maxSessionDuration = defaultRoleSessionDuration
+    if role.MaxSessionDuration != "" {
+      d, err := time.ParseDuration( role.MaxSessionDuration )
+      if err != nil || d < minSessionDuration || d > maxRoleSessionDuration {
+        e.add( fmt.Sprintf( "invalid duration %q, must be between %v and %v", role.MaxSessionDuration, minSessionDuration, maxRoleSessionDuration ), "roles", k, "maxSessionDuration" )
+      }
+      role.maxSessionDuration = d
+    }
+
+    policies, err := c.managedPolicies( nil, role.Policies, role.InlinePolicies, "role " + k )
+    if err != nil {
+      e.add( err.Error(), "roles", k )
+    }
+    role.policies = policies
+
+    c.Roles[k] = role
+  }
+
+  issuers := make( map[string]string )
+  for _, k := range sortedKeys( c.WebIdentityProviders ) {
+    p := c.WebIdentityProviders[k]
+
+    switch {
+      case p.Issuer == "":
+        e.add( "issuer is required", "webIdentityProviders", k )
+      case !strings.HasPrefix( p.Issuer, "https://" ) && !strings.HasPrefix( p.Issuer, "http://" ):
+        e.add( fmt.Sprintf( "invalid issuer %q", p.Issuer ), "webIdentityProviders", k, "issuer" )
+      case issuers[p.Issuer] != "":
+        e.add( fmt.Sprintf( "issuer is also used by provider %s", issuers[p.Issuer] ), "webIdentityProviders", k, "issuer" )
+      default:
+        issuers[p.Issuer] = k
+    }
+
+    // Keys from a url are fetched when first needed
+    p.keys = &jwksCache{}
+    switch {
+      case (p.JWKSFile == "") == (p.JWKSUrl == ""):
+        e.add( "one of jwksFile or jwksUrl is required", "webIdentityProviders", k )
+      case p.JWKSFile != "":
+        keys, err := p.loadKeys()
+        if err == nil && keys.Len() == 0 {
+          err = fmt.Errorf( "no signing keys" )
+        }
+        if err != nil {
+          e.add( err.Error(), "webIdentityProviders", k, "jwksFile" )
+        }
+        p.keys.keys, p.keys.loaded = keys, time.Now()
+    }
+
+    c.WebIdentityProviders[k] = p
+  }
+
+  c.validateQuota( e, &c.Quotas.User, "quotas", "user" )
+  c.validateQuota( e, &c.Quotas.Bucket, "quotas", "bucket" )
+  for _, k := range sortedKeys( c.Quotas.Buckets ) {
+    q := c.Quotas.Buckets[k]
+    c.validateQuota( e, &q, "quotas", "buckets", k )
+  }
+
+  return e.err()
+}
+
+// validateArn checks a user's arn, if set, is an iam or sts arn
+func (c *config) validateArn( e *configErrors, user *User, path ...string ) {
+  a := user.Arn
+  if a.IsNil() || a.IsUserId() {
+    return
+  }
+
+  if a.Type != "arn" || (a.Service != "iam" && a.Service != "sts") {
+    e.add( fmt.Sprintf( "invalid user arn %q", a.String() ), append( path, "arn" )... )
+  }
+}
+
+func (c *config) validateQuota( e *configErrors, q *Quota, path ...string ) {
+  if q == nil {
+    return
+  }
+  if q.MaxSize < 0 {
+    e.add( "maxSize cannot be negative", append( path, "maxSize" )... )
+  }
+  if q.MaxObjects < 0 {
+    e.add( "maxObjects cannot be negative", append( path, "maxObjects" )... )
+  }
+}
+
+// sortedKeys returns the keys of a map sorted so errors are reported in a
+// consistent order
+func sortedKeys[V any]( m map[string]V ) []string {
+  var keys []string
+  for k := range m {
+    keys = append( keys, k )
+  }
+  sort.Strings( keys )
+  return keys
+}
+
+// yamlLine returns the line number of the key at path in a yaml document or 0
+// if it cannot be found. Only block style mappings are searched.
+func yamlLine( yml []byte, path ...string ) int {
+  lines := strings.Split( string( yml ), "\n" )
+  line, indent := 0, -1
+
+  for _, key := range path {
+    found, childIndent := false, -1
+    for ; line < len( lines ) && !found; line++ {
+      t := strings.TrimLeft( lines[line], " " )
+      if t == "" || t[0] == '#' {
+        continue
+      }
+
+      i := len( lines[line] ) - len( t )
+      if i <= indent {
+        // Left the parent mapping
+        return 0
+      }
+      if childIndent < 0 {
+        childIndent = i
+      }
+
+      found = i == childIndent && yamlKey( t ) == key
+      if found {
+        indent = i
+      }
+    }
+    if !found {
+      return 0
+    }
+  }
+
+  return line
+}
+
+// yamlKey returns the key of a "key: value" line
+func yamlKey( t string ) string {
+  if t[0] == '"' || t[0] == '\'' {
+    if i := strings.IndexByte( t[1:], t[0] ); i >= 0 {
+      return t[1:i+1]
+    }
+  }
+  if i := strings.Index( t, ":" ); i >= 0 {
+    return strings.TrimSpace( t[:i] )
+  }
+  return ""
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+  "strings"
+  "testing"
+)
+
+const testYamlLineConfig = `# A comment
+rootUser:
+  accessKey: ROOTKEY
+
+users:
+  "ci":
+    accessKeys:
+      - accessKey: CIKEY
+    quota:
+      # maxSize: 1
+      maxSize: -1
+  other:
+    arn: "arn:aws:iam::123456789012:user/other"
+quotas:
+  user:
+    maxSize: 1
+`
+
+func TestYamlLine( t *testing.T ) {
+  for _, test := range []struct {
+    path  string
+    want  int
+  }{
+    {path: "rootUser", want: 2},
+    {path: "rootUser.accessKey", want: 3},
+    {path: "users", want: 5},
+    {path: "users.ci", want: 6},
+    {path: "users.ci.accessKeys", want: 7},
+    {path: "users.ci.quota.maxSize", want: 11},
+    {path: "users.other.arn", want: 13},
+    {path: "quotas.user.maxSize", want: 16},
+    // Keys must be in the mapping of their parent
+    {path: "users.ci.arn"},
+    {path: "users.other.quota"},
+    {path: "accessKey"},
+    {path: "users.nobody"},
+    {path: "roles"},
+  } {
+    t.Run( test.path, func( t *testing.T ) {
+      if got := yamlLine( []byte( testYamlLineConfig ), strings.Split( test.path, "." )... ); got != test.want {
+        t.Errorf( "got %d want %d", got, test.want )
+      }
+    } )
+  }
+}
+
+func TestParseConfig_invalid( t *testing.T ) {
+  for _, test := range []struct {
+    name  string
+    yml   string
+    // The errors expected, each prefixed by the filename
+    want  []string
+  }{
+    {
+      name: "unknown field",
+      yml: `
+users:
+  ci:
+    accesKeys:
+      - accessKey: CIKEY
+        secretKey: cisecret
+`,
+      want: []string{": yaml: unmarshal errors:\n  line 4: field accesKeys not found in type auth.User"},
+    },
+    {
+      name: "root secret",
+      yml: `
+rootUser:
+  accessKey: ROOTKEY
+`,
+      want: []string{":2: rootUser: secretKey is required"},
+    },
+    {
+      name: "clock skew",
+      yml: `
+auth:
+  clockSkew: 15
+`,
+      want: []string{`:3: auth.clockSkew: invalid duration "15"`},
+    },
+    {
+      name: "every error",
+      yml: `
+rootUser:
+  accessKey: ROOTKEY
+  secretKey: rootsecret
+users:
+  ci:
+    accessKeys:
+      - accessKey: ROOTKEY
+        secretKey: cisecret
+    quota:
+      maxSize: -1
+  other:
+    arn: "arn:aws:s3:::bucket"
+quotas:
+  bucket:
+    maxObjects: -1
+`,
+      want: []string{
+        `:7: users.ci.accessKeys: access key "ROOTKEY" is used by rootUser`,
+        ":11: users.ci.quota.maxSize: maxSize cannot be negative",
+        ":12: users.other: accessKeys is required",
+        `:13: users.other.arn: invalid user arn "arn:aws:s3:::bucket"`,
+        ":16: quotas.bucket.maxObjects: maxObjects cannot be negative",
+      },
+    },
+    {
+      name: "role",
+      yml: `
+roles:
+  reader:
+    arn: "arn:aws:iam::123456789012:user/reader"
+    maxSessionDuration: 1m
+`,
+      want: []string{
+        `:4: roles.reader.arn: invalid role arn "arn:aws:iam::123456789012:user/reader"`,
+        ":3: roles.reader: trustPolicy is required",
+        `:5: roles.reader.maxSessionDuration: invalid duration "1m"`,
+      },
+    },
+  } {
+    t.Run( test.name, func( t *testing.T ) {
+      filename := writeTestConfig( t, test.yml )
+
+      _, err := parseConfig( filename )
+      if err == nil {
+        t.Fatal( "expected an error" )
+      }
+
+      for _, want := range test.want {
+        if !strings.Contains( err.Error(), filename + want ) {
+          t.Errorf( "missing %q in\n%v", want, err )
+        }
+      }
+
+      if got, want := strings.Count( err.Error(), filename ), len( test.want ); got != want {
+        t.Errorf( "got %d errors want %d\n%v", got, want, err )
+      }
+    } )
+  }
+}
+
+func TestParseConfig_valid( t *testing.T ) {
+  c, err := parseConfig( writeTestConfig( t, testScopedConfig ) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  if !c.Root.root {
+    t.Error( "rootUser is not root" )
+  }
+  if got := c.accessKeys["CIKEY"]; got != "ci" {
+    t.Errorf( "CIKEY got user %q want ci", got )
+  }
+}
+
+func TestAuthService_PostInit_checkConfig( t *testing.T ) {
+  check := true
+  for _, test := range []struct {
+    name  string
+    yml   string
+    want  error
+  }{
+    {name: "valid", yml: testScopedConfig, want: ErrConfigChecked},
+    {name: "invalid", yml: "rootUser:\n  accessKey: ROOTKEY\n"},
+  } {
+    t.Run( test.name, func( t *testing.T ) {
+      filename := writeTestConfig( t, test.yml )
+      s := &AuthService{configFile: &filename, checkConfig: &check}
+
+      err := s.PostInit()
+      switch {
+        case test.want != nil && err != test.want:
+          t.Errorf( "got %v want %v", err, test.want )
+        case test.want == nil && (err == nil || err == ErrConfigChecked):
+          t.Errorf( "expected a validation error got %v", err )
+      }
+    } )
+  }
+}
@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"crypto"
+	"fmt"
+	"github.com/peter-mount/objectstore/awserror"
+	"github.com/peter-mount/objectstore/condition"
+	"github.com/peter-mount/objectstore/jwt"
+	"github.com/peter-mount/objectstore/policy"
+	"github.com/peter-mount/objectstore/utils"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// How long keys fetched from a url are used before being fetched again
+	jwksMaxAge = time.Hour
+	// The shortest time between fetching keys when a token uses an unknown key
+	jwksMinRefresh = time.Minute
+	// The largest JWKS accepted
+	maxJWKSSize = 1 << 20
+)
+
+// The client used to fetch keys from a url
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// An OpenID Connect identity provider whose tokens can be exchanged for
+// temporary credentials with AssumeRoleWithWebIdentity
+type WebIdentityProvider struct {
+	// The issuer of tokens, their iss claim, e.g. https://token.actions.githubusercontent.com
+	Issuer string `yaml:"issuer"`
+	// The audiences accepted, a token's aud claim must contain one of them.
+	// If not set then any audience is accepted
+	Audiences []string `yaml:"audiences"`
+	// A file containing the provider's JSON Web Key Set
+	JWKSFile string `yaml:"jwksFile"`
+	// The url of the provider's JSON Web Key Set
+	JWKSUrl string `yaml:"jwksUrl"`
+	// The provider's keys
+	keys *jwksCache
+}
+
+// The keys of a provider, shared by the copies of the provider
+type jwksCache struct {
+	mutex  sync.Mutex
+	keys   *jwt.KeySet
+	loaded time.Time
+}
+
+// Name returns the name of the provider used in trust policies, it's issuer
+// without the scheme, e.g. token.actions.githubusercontent.com
+func (p *WebIdentityProvider) Name() string {
+	n := p.Issuer
+	if i := strings.Index(n, "://"); i >= 0 {
+		n = n[i+3:]
+	}
+	return strings.TrimSuffix(n, "/")
+}
+
+// loadKeys loads the provider's keys from it's file or url
+func (p *WebIdentityProvider) loadKeys() (*jwt.KeySet, error) {
+	if p.JWKSFile != "" {
+		b, err := ioutil.ReadFile(p.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		return jwt.ParseKeySet(b)
+	}
+
+	resp, err := jwksClient.Get(p.JWKSUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", p.JWKSUrl, resp.Status)
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseKeySet(b)
+}
+
+// key returns the key a token was signed with.
+// The keys are reloaded when they are old or the key is not known, as the
+// provider may have rotated them.
+func (p *WebIdentityProvider) key(kid string) (crypto.PublicKey, error) {
+	c := p.keys
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := c.keys.Key(kid)
+	age := time.Since(c.loaded)
+	if (key == nil && age > jwksMinRefresh) || age > jwksMaxAge {
+		keys, err := p.loadKeys()
+		if err != nil {
+			log.Printf("Failed to load keys of %s: %v", p.Name(), err)
+			if key == nil {
+				return nil, awserror.IDPCommunicationError("Unable to load the keys of %s", p.Name())
+			}
+		} else {
+			c.keys, c.loaded = keys, time.Now()
+			key = keys.Key(kid)
+		}
+	}
+
+	if key == nil {
+		return nil, awserror.InvalidIdentityToken("Unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// getWebIdentityProvider returns the name & provider of an issuer
+func (c *config) getWebIdentityProvider(issuer string) (string, *WebIdentityProvider) {
+	for k, v := range c.WebIdentityProviders {
+		if v.Issuer == issuer {
+			return k, &v
+		}
+	}
+	return "", nil
+}
+
+// AssumeRoleWithWebIdentity issues temporary credentials for a role in
+// exchange for a token issued by a configured identity provider.
+//
+// The role's trust policy must allow the provider, as a Federated principal,
+// sts:AssumeRoleWithWebIdentity. The token's sub & aud claims are available
+// to the trust policy's conditions as the provider's name followed by
+// ":sub" & ":aud", e.g. "token.actions.githubusercontent.com:sub".
+func (s *AuthService) AssumeRoleWithWebIdentity(ctx condition.Context, roleArn, sessionName, token string, duration time.Duration, sessionPolicy string) (*Session, error) {
+	t, err := jwt.Parse(token)
+	if err != nil {
+		return nil, awserror.InvalidIdentityToken("%s", err.Error())
+	}
+
+	name, provider := s.config().getWebIdentityProvider(t.Claims.Issuer)
+	if provider == nil {
+		return nil, awserror.InvalidIdentityToken("Issuer %q is not trusted", t.Claims.Issuer)
+	}
+
+	key, err := provider.key(t.Header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.Verify(key); err != nil {
+		return nil, awserror.InvalidIdentityToken("%s", err.Error())
+	}
+
+	if err := t.Claims.Valid(time.Now(), s.config().clockSkew); err == jwt.ErrExpired {
+		return nil, awserror.ExpiredToken()
+	} else if err != nil {
+		return nil, awserror.InvalidIdentityToken("%s", err.Error())
+	}
+
+	if t.Claims.Subject == "" {
+		return nil, awserror.InvalidIdentityToken("Missing sub claim")
+	}
+
+	audience := ""
+	if len(provider.Audiences) == 0 {
+		if len(t.Claims.Audience) > 0 {
+			audience = t.Claims.Audience[0]
+		}
+	} else {
+		for _, a := range provider.Audiences {
+			if t.Claims.Audience.Contains(a) {
+				audience = a
+				break
+			}
+		}
+		if audience == "" {
+			return nil, awserror.InvalidIdentityToken("Incorrect token audience")
+		}
+	}
+
+	_, role := s.config().getRole(roleArn)
+	if role == nil {
+		return nil, awserror.AccessDenied()
+	}
+
+	// The provider is in the role's account
+	pn := provider.Name()
+	principal := utils.NewARN("arn", role.Arn.Partition, "iam", "", role.Arn.Account, policy.FederatedPrefix+pn)
+
+	ctx.Set(pn+":sub", t.Claims.Subject).
+		Set(pn+":aud", t.Claims.Audience...)
+
+	return s.assumeRole(
+		&policy.Request{
+			Principal: principal,
+			Action:    "sts:AssumeRoleWithWebIdentity",
+			Context:   ctx,
+		},
+		&Session{
+			Provider: name,
+			Issuer:   pn,
+			Subject:  t.Claims.Subject,
+			Audience: audience,
+		},
+		roleArn, sessionName, duration, sessionPolicy)
+}
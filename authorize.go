@@ -0,0 +1,258 @@
+package objectstore
+
+import (
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"github.com/peter-mount/objectstore/condition"
+	"github.com/peter-mount/objectstore/policy"
+	"github.com/peter-mount/objectstore/utils"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Which ACL grants an action
+const (
+	// Any authenticated user, used where there is no ACL, e.g. CreateBucket
+	acl_authenticated = iota
+	// Only the bucket owner
+	acl_bucket_owner
+	// The bucket ACL
+	acl_bucket
+	// The object ACL
+	acl_object
+)
+
+// s3Action is an S3 action performed by a route
+type s3Action struct {
+	// The action name used in policies, e.g. "s3:GetObject"
+	name string
+	// Which ACL grants the action
+	acl int
+	// The ACL permission required
+	permission string
+}
+
+// The actions we support
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/using-with-s3-actions.html
+var (
+	actionListAllMyBuckets          = &s3Action{"s3:ListAllMyBuckets", acl_authenticated, ""}
+	actionCreateBucket              = &s3Action{"s3:CreateBucket", acl_authenticated, ""}
+	actionDeleteBucket              = &s3Action{"s3:DeleteBucket", acl_bucket_owner, ""}
+	actionListBucket                = &s3Action{"s3:ListBucket", acl_bucket, PERM_READ}
+	actionGetBucketObjectLock       = &s3Action{"s3:GetBucketObjectLockConfiguration", acl_bucket_owner, ""}
+	actionGetBucketLocation         = &s3Action{"s3:GetBucketLocation", acl_bucket_owner, ""}
+	actionGetBucketAcl              = &s3Action{"s3:GetBucketAcl", acl_bucket, PERM_READ_ACP}
+	actionPutBucketAcl              = &s3Action{"s3:PutBucketAcl", acl_bucket, PERM_WRITE_ACP}
+	actionGetBucketPolicy           = &s3Action{"s3:GetBucketPolicy", acl_bucket_owner, ""}
+	actionPutBucketPolicy           = &s3Action{"s3:PutBucketPolicy", acl_bucket_owner, ""}
+	actionDeleteBucketPolicy        = &s3Action{"s3:DeleteBucketPolicy", acl_bucket_owner, ""}
+	actionGetBucketTagging          = &s3Action{"s3:GetBucketTagging", acl_bucket_owner, ""}
+	actionPutBucketTagging          = &s3Action{"s3:PutBucketTagging", acl_bucket_owner, ""}
+	actionGetBucketWebsite          = &s3Action{"s3:GetBucketWebsite", acl_bucket_owner, ""}
+	actionPutBucketWebsite          = &s3Action{"s3:PutBucketWebsite", acl_bucket_owner, ""}
+	actionDeleteBucketWebsite       = &s3Action{"s3:DeleteBucketWebsite", acl_bucket_owner, ""}
+	actionGetBucketCORS             = &s3Action{"s3:GetBucketCORS", acl_bucket_owner, ""}
+	actionPutBucketCORS             = &s3Action{"s3:PutBucketCORS", acl_bucket_owner, ""}
+	actionGetLifecycleConfiguration = &s3Action{"s3:GetLifecycleConfiguration", acl_bucket_owner, ""}
+	actionPutLifecycleConfiguration = &s3Action{"s3:PutLifecycleConfiguration", acl_bucket_owner, ""}
+	actionPutObject                 = &s3Action{"s3:PutObject", acl_bucket, PERM_WRITE}
+	actionAbortMultipartUpload      = &s3Action{"s3:AbortMultipartUpload", acl_bucket, PERM_WRITE}
+	actionDeleteObject              = &s3Action{"s3:DeleteObject", acl_bucket, PERM_WRITE}
+	actionGetObject                 = &s3Action{"s3:GetObject", acl_object, PERM_READ}
+	actionGetObjectAcl              = &s3Action{"s3:GetObjectAcl", acl_object, PERM_READ_ACP}
+	actionPutObjectAcl              = &s3Action{"s3:PutObjectAcl", acl_object, PERM_WRITE_ACP}
+	actionGetObjectTagging          = &s3Action{"s3:GetObjectTagging", acl_object, PERM_READ}
+	actionPutObjectTagging          = &s3Action{"s3:PutObjectTagging", acl_bucket, PERM_WRITE}
+	actionDeleteObjectTagging       = &s3Action{"s3:DeleteObjectTagging", acl_bucket, PERM_WRITE}
+)
+
+// authorize decorates a handler so the request is only passed to it if the
+// request's credential is authorized to perform the action.
+func (s *ObjectStore) authorize(action *s3Action, h rest.RestHandler) rest.RestHandler {
+	return func(r *rest.Rest) error {
+		err := s.authorizeRequest(r, action, requestBucketName(r), requestObjectName(r))
+		if err != nil {
+			return err
+		}
+		return h(r)
+	}
+}
+
+// authorizeRequest checks the request's credential may perform an action on a
+// bucket or object, returning AccessDenied if not.
+//
+// Root or full anonymous access is always allowed. Otherwise the bucket policy,
+// the ACL and the user's identity policies decide, see auth.Credential.Authorize.
+func (s *ObjectStore) authorizeRequest(r *rest.Rest, action *s3Action, bucketName, objectName string) error {
+	cred := auth.RequestCredential(r)
+	if cred.IsRoot() || cred.IsAnonymous() {
+		return nil
+	}
+
+	req := &policy.Request{
+		Principal:   cred.Principal(),
+		CanonicalId: cred.CanonicalId(),
+		Action:      action.name,
+		Resource:    resourceARN(bucketName, objectName),
+		Context:     s.requestContext(r, cred),
+	}
+
+	var policies []*policy.Policy
+	aclAllowed := false
+
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		if action.acl == acl_authenticated {
+			aclAllowed = cred.DefaultAllowed()
+			return nil
+		}
+
+		b, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		if len(meta.Policy) > 0 {
+			p, err := policy.Parse(meta.Policy)
+			if err != nil {
+				// Should never happen as it was validated when stored
+				log.Printf("Invalid policy for bucket %s: %v", bucketName, err)
+				return awserror.AccessDenied()
+			}
+			policies = append(policies, p)
+		}
+
+		switch action.acl {
+		case acl_bucket_owner:
+			aclAllowed = s.bucketACL(meta).isOwner(cred)
+
+		case acl_bucket:
+			aclAllowed = s.bucketACL(meta).Allows(cred, action.permission)
+
+		case acl_object:
+			obj := &Object{}
+			if obj.get(b, objectName) != nil {
+				// Only those who can list the bucket can see the object is missing
+				aclAllowed = s.bucketACL(meta).Allows(cred, PERM_READ)
+			} else {
+				aclAllowed = s.objectACL(meta, obj).Allows(cred, action.permission)
+				for k, v := range obj.Tags {
+					req.Context.Set(condition.KEY_S3_EXISTING_TAG+k, v)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return cred.Authorize(req, aclAllowed, policies...)
+}
+
+// resourceARN returns the ARN of a bucket or object
+func resourceARN(bucketName, objectName string) *utils.ARN {
+	resource := bucketName
+	if resource == "" {
+		resource = "*"
+	} else if objectName != "" {
+		resource = resource + "/" + objectName
+	}
+	return utils.NewS3ARN("arn", "", resource)
+}
+
+// requestContext returns the context policy conditions are evaluated against
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/amazon-s3-policy-keys.html
+func (s *ObjectStore) requestContext(r *rest.Rest, cred *auth.Credential) condition.Context {
+	req := r.Request()
+	now := s.timeNow()
+
+	ctx := condition.NewContext().
+		Set(condition.KEY_CURRENT_TIME, now.UTC().Format("2006-01-02T15:04:05Z")).
+		Set(condition.KEY_EPOCH_TIME, strconv.FormatInt(now.Unix(), 10)).
+		Set(condition.KEY_SECURE_TRANSPORT, strconv.FormatBool(req.TLS != nil))
+
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		ctx.Set(condition.KEY_SOURCE_IP, host)
+	}
+
+	if v := req.UserAgent(); v != "" {
+		ctx.Set(condition.KEY_USER_AGENT, v)
+	}
+
+	if v := req.Referer(); v != "" {
+		ctx.Set(condition.KEY_REFERER, v)
+	}
+
+	if cred.IsAuthenticated() {
+		ctx.Set(condition.KEY_USERNAME, cred.DisplayName()).
+			Set(condition.KEY_USERID, cred.CanonicalId())
+		if arn := cred.Arn(); !arn.IsNil() {
+			ctx.Set(condition.KEY_PRINCIPAL_ARN, arn.String())
+		}
+	}
+
+	query := req.URL.Query()
+
+	if v := req.Header.Get("Authorization"); strings.HasPrefix(v, "AWS4-HMAC-SHA256") {
+		ctx.Set(condition.KEY_S3_SIGNATURE, "AWS4-HMAC-SHA256").
+			Set(condition.KEY_S3_AUTH_TYPE, "REST-HEADER")
+	} else if strings.HasPrefix(v, "AWS ") {
+		ctx.Set(condition.KEY_S3_SIGNATURE, "AWS").
+			Set(condition.KEY_S3_AUTH_TYPE, "REST-HEADER")
+	} else if query.Get("X-Amz-Algorithm") != "" {
+		ctx.Set(condition.KEY_S3_SIGNATURE, "AWS4-HMAC-SHA256").
+			Set(condition.KEY_S3_AUTH_TYPE, "REST-QUERY-STRING")
+	} else if query.Get("AWSAccessKeyId") != "" {
+		ctx.Set(condition.KEY_S3_SIGNATURE, "AWS").
+			Set(condition.KEY_S3_AUTH_TYPE, "REST-QUERY-STRING")
+	}
+
+	for k, n := range map[string]string{
+		"prefix":    condition.KEY_S3_PREFIX,
+		"delimiter": condition.KEY_S3_DELIMITER,
+		"max-keys":  condition.KEY_S3_MAX_KEYS,
+	} {
+		if v, ok := query[k]; ok {
+			ctx.Set(n, v...)
+		}
+	}
+
+	for h, n := range map[string]string{
+		"X-Amz-Acl":         condition.KEY_S3_ACL,
+		"X-Amz-Copy-Source": condition.KEY_S3_COPY_SOURCE,
+	} {
+		if v := req.Header.Get(h); v != "" {
+			ctx.Set(n, v)
+		}
+	}
+
+	// Invalid tags are rejected by the handler so ignore them here
+	if tags, err := parseTaggingHeader(req.Header); err == nil {
+		for k, v := range tags {
+			ctx.Set(condition.KEY_S3_REQUEST_TAG+k, v)
+		}
+	}
+
+	return ctx
+}
+
+// requestBucketName returns the bucket name of a request
+func requestBucketName(r *rest.Rest) string {
+	if n := r.Var("BucketName"); n != "" {
+		return n
+	}
+	return r.Var("DestBucketName")
+}
+
+// requestObjectName returns the object name of a request
+func requestObjectName(r *rest.Rest) string {
+	if n := r.Var("ObjectName"); n != "" {
+		return n
+	}
+	return r.Var("DestObjectName")
+}
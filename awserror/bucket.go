@@ -19,3 +19,107 @@ func NoSuchBucket() *Error {
     Message:  "The specified bucket does not exist.",
   }
 }
+
+func NoSuchTagSet() *Error {
+	return &Error{
+    Status:   http.StatusNotFound,
+    Code:     "NoSuchTagSet",
+    Message:  "The TagSet does not exist.",
+  }
+}
+
+func NoSuchBucketPolicy() *Error {
+	return &Error{
+    Status:   http.StatusNotFound,
+    Code:     "NoSuchBucketPolicy",
+    Message:  "The specified bucket does not have a bucket policy.",
+  }
+}
+
+func MalformedPolicy(msg string) *Error {
+	return &Error{
+    Status:   http.StatusBadRequest,
+    Code:     "MalformedPolicy",
+    Message:  msg,
+  }
+}
+
+func NoSuchWebsiteConfiguration() *Error {
+	return &Error{
+    Status:   http.StatusNotFound,
+    Code:     "NoSuchWebsiteConfiguration",
+    Message:  "The specified bucket does not have a website configuration",
+  }
+}
+
+func NoSuchCORSConfiguration() *Error {
+	return &Error{
+    Status:   http.StatusNotFound,
+    Code:     "NoSuchCORSConfiguration",
+    Message:  "The CORS configuration does not exist",
+  }
+}
+
+func NoSuchLifecycleConfiguration() *Error {
+	return &Error{
+    Status:   http.StatusNotFound,
+    Code:     "NoSuchLifecycleConfiguration",
+    Message:  "The lifecycle configuration does not exist",
+  }
+}
+
+func ObjectLockConfigurationNotFoundError() *Error {
+	return &Error{
+    Status:   http.StatusNotFound,
+    Code:     "ObjectLockConfigurationNotFoundError",
+    Message:  "Object Lock configuration does not exist for this bucket",
+  }
+}
+
+func CORSNotEnabled() *Error {
+	return &Error{
+    Status:   http.StatusForbidden,
+    Code:     "AccessForbidden",
+    Message:  "CORSResponse: CORS is not enabled for this bucket.",
+  }
+}
+
+func CORSNotAllowed() *Error {
+	return &Error{
+    Status:   http.StatusForbidden,
+    Code:     "AccessForbidden",
+    Message:  "CORSResponse: This CORS request is not allowed. This is usually because the evalution of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.",
+  }
+}
+
+func InvalidLocationConstraint() *Error {
+	return &Error{
+    Status:   http.StatusBadRequest,
+    Code:     "InvalidLocationConstraint",
+    Message:  "The specified location-constraint is not valid",
+  }
+}
+
+func InvalidBucketName() *Error {
+	return &Error{
+    Status:   http.StatusBadRequest,
+    Code:     "InvalidBucketName",
+    Message:  "The specified bucket is not valid.",
+  }
+}
+
+func BucketNotEmpty() *Error {
+	return &Error{
+    Status:   http.StatusConflict,
+    Code:     "BucketNotEmpty",
+    Message:  "The bucket you tried to delete is not empty",
+  }
+}
+
+func BucketAlreadyOwnedByYou() *Error {
+	return &Error{
+    Status:   http.StatusConflict,
+    Code:     "BucketAlreadyOwnedByYou",
+    Message:  "Your previous request to create the named bucket succeeded and you already own it.",
+  }
+}
@@ -14,6 +14,8 @@ type Error struct {
 	Message   string   `xml:"Message"`
 	Resource  string   `xml:"Resource"`
 	RequestId string   `xml:"RequestId"`
+	// The region of the bucket when the request was for the wrong region
+	Region string `xml:"Region,omitempty"`
 }
 
 // Error enables us to use our error's as a go error
@@ -30,6 +32,10 @@ func (e *Error) Send(r *rest.Rest) *rest.Rest {
 		e.Status = http.StatusBadRequest
 	}
 
+	if e.Region != "" {
+		r.AddHeader("x-amz-bucket-region", e.Region)
+	}
+
 	r.Status(e.Status).
 		XML().
 		Value(e)
@@ -37,6 +43,12 @@ func (e *Error) Send(r *rest.Rest) *rest.Rest {
 	return r
 }
 
+// WithRegion sets the region a request should have been made to
+func (e *Error) WithRegion(region string) *Error {
+	e.Region = region
+	return e
+}
+
 func AccessDenied() *Error {
 	return &Error{
 		Status:  http.StatusForbidden,
@@ -87,3 +99,179 @@ func InternalError() *Error {
 		Message: "We encountered an internal error. Please try again.",
 	}
 }
+
+func InvalidTag(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "InvalidTag",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func MalformedXML() *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "MalformedXML",
+		Message: "The XML you provided was not well-formed or did not validate against our published schema.",
+	}
+}
+
+func InvalidRequest(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "InvalidRequest",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func MalformedACLError() *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "MalformedACLError",
+		Message: "The XML you provided was not well-formed or did not validate against our published schema.",
+	}
+}
+
+func AuthorizationQueryParametersError(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "AuthorizationQueryParametersError",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func RequestExpired() *Error {
+	return &Error{
+		Status:  http.StatusForbidden,
+		Code:    "AccessDenied",
+		Message: "Request has expired",
+	}
+}
+
+func RequestNotYetValid() *Error {
+	return &Error{
+		Status:  http.StatusForbidden,
+		Code:    "AccessDenied",
+		Message: "Request is not valid yet",
+	}
+}
+
+func InvalidPolicyDocument(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "InvalidPolicyDocument",
+		Message: "Invalid Policy: " + fmt.Sprintf(f, a...),
+	}
+}
+
+func PolicyConditionFailed(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusForbidden,
+		Code:    "AccessDenied",
+		Message: "Invalid according to Policy: " + fmt.Sprintf(f, a...),
+	}
+}
+
+func RequestTimeTooSkewed() *Error {
+	return &Error{
+		Status:  http.StatusForbidden,
+		Code:    "RequestTimeTooSkewed",
+		Message: "The difference between the request time and the current time is too large.",
+	}
+}
+
+func AuthorizationHeaderMalformed(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "AuthorizationHeaderMalformed",
+		Message: "The authorization header is malformed; " + fmt.Sprintf(f, a...),
+	}
+}
+
+func MissingDateHeader() *Error {
+	return &Error{
+		Status:  http.StatusForbidden,
+		Code:    "AccessDenied",
+		Message: "AWS authentication requires a valid Date or x-amz-date header",
+	}
+}
+
+func QuotaExceeded(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusForbidden,
+		Code:    "QuotaExceeded",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func InvalidToken() *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "InvalidToken",
+		Message: "The provided token is malformed or otherwise invalid.",
+	}
+}
+
+func ExpiredToken() *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "ExpiredToken",
+		Message: "The provided token has expired.",
+	}
+}
+
+func InvalidAction(action string) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "InvalidAction",
+		Message: fmt.Sprintf("Could not find operation %s", action),
+	}
+}
+
+func ValidationError(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "ValidationError",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func MalformedPolicyDocument(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "MalformedPolicyDocument",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func InvalidIdentityToken(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "InvalidIdentityToken",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func IDPCommunicationError(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "IDPCommunicationError",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
+
+func UnresolvableGrantByEmailAddress() *Error {
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    "UnresolvableGrantByEmailAddress",
+		Message: "The email address you provided does not match any account on record.",
+	}
+}
+
+func NotImplemented(f string, a ...interface{}) *Error {
+	return &Error{
+		Status:  http.StatusNotImplemented,
+		Code:    "NotImplemented",
+		Message: fmt.Sprintf(f, a...),
+	}
+}
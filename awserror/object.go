@@ -27,3 +27,19 @@ func NoSuchKey() *Error {
     Message:  "The specified key does not exist.",
   }
 }
+
+func EntityTooSmall() *Error {
+	return &Error{
+    Status:   http.StatusBadRequest,
+    Code:     "EntityTooSmall",
+    Message:  "Your proposed upload is smaller than the minimum allowed object size.",
+  }
+}
+
+func EntityTooLarge() *Error {
+	return &Error{
+    Status:   http.StatusBadRequest,
+    Code:     "EntityTooLarge",
+    Message:  "Your proposed upload exceeds the maximum allowed object size.",
+  }
+}
@@ -13,12 +13,7 @@ func RestErrorWrapper(h rest.RestHandler) rest.RestHandler {
 			log.Println(err)
 
 			// Map known errors to aws ones
-			switch err {
-			case bbolt.ErrBucketExists:
-				err = BucketAlreadyExists()
-			case bbolt.ErrBucketNotFound:
-				err = NoSuchBucket()
-			}
+			err = mapError(err)
 
 			log.Println(err)
 			// Aws errors send correct response
@@ -30,3 +25,24 @@ func RestErrorWrapper(h rest.RestHandler) rest.RestHandler {
 		return err
 	}
 }
+
+// mapError maps known errors to aws ones
+func mapError(err error) error {
+	switch err {
+	case bbolt.ErrBucketExists:
+		return BucketAlreadyExists()
+	case bbolt.ErrBucketNotFound:
+		return NoSuchBucket()
+	default:
+		return err
+	}
+}
+
+// ToError returns the aws Error for an error.
+// Errors which are not aws ones are an InternalError
+func ToError(err error) *Error {
+	if e, ok := mapError(err).(*Error); ok {
+		return e
+	}
+	return InternalError()
+}
@@ -3,6 +3,7 @@ package objectstore
 import (
 	"github.com/peter-mount/go-kernel/v2/bolt"
 	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
 	"github.com/peter-mount/objectstore/awserror"
 	"strings"
 	"time"
@@ -11,22 +12,44 @@ import (
 // getBucket returns a bucket or an error if the bucket is not found
 func (s *ObjectStore) getBucket(tx *bolt.Tx, bucketName string) (*bolt.Bucket, error) {
 	b := tx.Bucket(bucketName)
-	if b == nil {
+	if b == nil || isInternalBucket(bucketName) {
 		return nil, awserror.NoSuchBucket()
 	}
 	return b, nil
 }
 
-// GetBuckets returns a list of all Buckets
+// GetBuckets returns a list of the Buckets the caller owns or has been granted
+// access to. Root sees every bucket.
 func (s *ObjectStore) GetBuckets(r *rest.Rest) error {
+	cred := auth.RequestCredential(r)
+	all := cred.IsRoot() || cred.IsAnonymous()
 
 	buckets := []BucketInfo{}
 
 	now := s.timeNowRFC()
 
 	err := s.boltService.View(func(tx *bolt.Tx) error {
-		return tx.ForEach(func(name string, _ *bolt.Bucket) error {
-			buckets = append(buckets, BucketInfo{name, now})
+		return tx.ForEach(func(name string, b *bolt.Bucket) error {
+			if isInternalBucket(name) {
+				return nil
+			}
+
+			meta := &BucketMeta{}
+			if err := meta.get(b); err != nil {
+				return err
+			}
+
+			if !(all || s.bucketACL(meta).visibleTo(cred)) {
+				return nil
+			}
+
+			// Buckets created before we kept metadata have no creation date
+			created := now
+			if !meta.CreationDate.IsZero() {
+				created = meta.CreationDate.Format(time.RFC3339)
+			}
+
+			buckets = append(buckets, BucketInfo{name, created})
 			return nil
 		})
 	})
@@ -35,12 +58,14 @@ func (s *ObjectStore) GetBuckets(r *rest.Rest) error {
 		return err
 	}
 
+	owner := newOwner(cred)
+
 	r.Status(200).
 		XML().
 		Value(&Storage{
 			Xmlns:       "http://s3.amazonaws.com/doc/2006-03-01/",
-			Id:          "fe7272ea58be830e56fe1663b10fafef",
-			DisplayName: "Area51ObjectStore",
+			Id:          owner.ID,
+			DisplayName: owner.DisplayName,
 			Buckets:     buckets,
 		})
 	return nil
@@ -49,18 +74,56 @@ func (s *ObjectStore) GetBuckets(r *rest.Rest) error {
 // CreateBucket creates a new S3 bucket in the BoltDB storage.
 func (s *ObjectStore) CreateBucket(r *rest.Rest) error {
 	bucketName := r.Var("BucketName")
+	if !validBucketName(bucketName) {
+		return awserror.InvalidBucketName()
+	}
 
-	err := s.boltService.Update(func(tx *bolt.Tx) error {
-		// TODO if bbolt.ErrBucketExists then check ownership & return
-		// BucketAlreadyOwnedByYou if caller is the owner
-		_, err := tx.CreateBucket(bucketName)
+	cred := auth.RequestCredential(r)
+	owner := newOwner(cred)
+	acl, err := aclFromHeaders(r.Request().Header, owner, owner)
+	if err != nil {
+		return err
+	}
+
+	lockEnabled, err := objectLockEnabled(r.Request().Header)
+	if err != nil {
 		return err
+	}
+
+	region, err := s.readCreateBucketConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	err = s.boltService.Update(func(tx *bolt.Tx) error {
+		if _, meta, err := s.getBucketMeta(tx, bucketName); err == nil {
+			if s.bucketACL(meta).isOwner(cred) {
+				return awserror.BucketAlreadyOwnedByYou()
+			}
+			return awserror.BucketAlreadyExists()
+		}
+
+		b, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			return err
+		}
+
+		meta := &BucketMeta{
+			CreationDate:      s.timeNow(),
+			ACL:               acl,
+			Region:            region,
+			ObjectLockEnabled: lockEnabled,
+			Usage:             &Usage{},
+		}
+		return meta.put(b)
 	})
 
 	if err != nil {
 		return err
 	}
 
+	s.bucketRegions.Store(bucketName, region)
+
 	r.Status(200).
 		AddHeader("Host", r.Request().Host).
 		AddHeader("Location", "/"+bucketName)
@@ -73,6 +136,19 @@ func (s *ObjectStore) DeleteBucket(r *rest.Rest) error {
 	bucketName := r.Var("BucketName")
 
 	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		b, err := s.getBucket(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		// Only the bucket's own metadata may remain
+		c := b.Cursor()
+		for k, _ := c.First(); k != ""; k, _ = c.Next() {
+			if k != bucketmeta_key {
+				return awserror.BucketNotEmpty()
+			}
+		}
+
 		return tx.DeleteBucket(bucketName)
 	})
 
@@ -80,6 +156,8 @@ func (s *ObjectStore) DeleteBucket(r *rest.Rest) error {
 		return err
 	}
 
+	s.bucketRegions.Delete(bucketName)
+
 	r.Status(200)
 
 	return nil
@@ -89,8 +167,12 @@ func (s *ObjectStore) DeleteBucket(r *rest.Rest) error {
 func (s *ObjectStore) HeadBucket(r *rest.Rest) error {
 	bucketName := r.Var("BucketName")
 
+	region := ""
 	err := s.boltService.View(func(tx *bolt.Tx) error {
-		_, err := s.getBucket(tx, bucketName)
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err == nil {
+			region = s.bucketRegion(meta)
+		}
 		return err
 	})
 
@@ -98,7 +180,8 @@ func (s *ObjectStore) HeadBucket(r *rest.Rest) error {
 		return err
 	}
 
-	r.Status(200)
+	r.Status(200).
+		AddHeader("x-amz-bucket-region", region)
 
 	return nil
 }
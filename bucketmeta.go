@@ -0,0 +1,86 @@
+package objectstore
+
+import (
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"gopkg.in/mgo.v2/bson"
+	"time"
+)
+
+// The metadata for each bucket, stored within the bucket itself
+type BucketMeta struct {
+	// When the bucket was created
+	CreationDate time.Time
+	// Tags, maximum of 50
+	Tags map[string]string
+	// The bucket's ACL, which also holds the bucket's owner
+	ACL *ACL
+	// The bucket policy as submitted by the client
+	Policy []byte
+	// The static website configuration
+	Website *WebsiteConfiguration
+	// The CORS configuration
+	CORS *CORSConfiguration
+	// The lifecycle configuration
+	Lifecycle *LifecycleConfiguration
+	// The region the bucket was created in
+	Region string
+	// Object lock was enabled when the bucket was created
+	ObjectLockEnabled bool
+	// The storage used by the bucket, nil if not yet calculated
+	Usage *Usage
+}
+
+// get retrieves a bucket's metadata.
+// Buckets created before we stored metadata will not have any so this will
+// leave m unchanged.
+func (m *BucketMeta) get(b *bolt.Bucket) error {
+	v := b.Get(bucketmeta_key)
+	if v == nil {
+		return nil
+	}
+	return bson.Unmarshal(v, m)
+}
+
+// put stores the bucket's metadata
+func (m *BucketMeta) put(b *bolt.Bucket) error {
+	v, err := bson.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(bucketmeta_key, v)
+}
+
+// getBucketMeta returns a bucket and its metadata or an error if the bucket is not found
+func (s *ObjectStore) getBucketMeta(tx *bolt.Tx, bucketName string) (*bolt.Bucket, *BucketMeta, error) {
+	b, err := s.getBucket(tx, bucketName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	meta := &BucketMeta{}
+	err = meta.get(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, meta, nil
+}
+
+// updateBucketMeta retrieves a bucket's metadata, passes it to a function to
+// modify it then stores the result
+func (s *ObjectStore) updateBucketMeta(bucketName string, f func(*BucketMeta) error) error {
+	return s.boltService.Update(func(tx *bolt.Tx) error {
+		b, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		err = f(meta)
+		if err != nil {
+			return err
+		}
+
+		return meta.put(b)
+	})
+}
@@ -0,0 +1,41 @@
+package objectstore
+
+import (
+	"net"
+	"strings"
+)
+
+// validBucketName returns true if name follows the S3 bucket naming rules.
+// This also stops the creation of buckets using names reserved for our own use.
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/BucketRestrictions.html
+func validBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 {
+		return false
+	}
+
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '.' || c == '-':
+			// Must start & end with a letter or number
+			if i == 0 || i == len(name)-1 {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	// Labels must not be empty nor start or end with a hyphen
+	if strings.Contains(name, "..") || strings.Contains(name, ".-") || strings.Contains(name, "-.") {
+		return false
+	}
+
+	// Must not be formatted as an IP address
+	if net.ParseIP(name) != nil {
+		return false
+	}
+
+	// Reserved prefixes & suffixes
+	return !strings.HasPrefix(name, "xn--") && !strings.HasSuffix(name, "-s3alias")
+}
@@ -0,0 +1,129 @@
+package objectstore
+
+import (
+	"bytes"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"github.com/peter-mount/objectstore/policy"
+	"github.com/peter-mount/objectstore/utils"
+	"strconv"
+	"strings"
+)
+
+// Maximum size of a bucket policy
+const maxBucketPolicySize = 20 * 1024
+
+// parseBucketPolicy parses and validates a bucket policy.
+// In addition to the policy grammar a bucket policy must have a Principal in
+// every statement and every Resource must refer to the bucket or it's objects.
+func parseBucketPolicy(bucketName string, b []byte) (*policy.Policy, error) {
+	if len(b) > maxBucketPolicySize {
+		return nil, awserror.MalformedPolicy("Policies must be no more than 20 KB")
+	}
+
+	p, err := policy.Parse(b)
+	if err != nil {
+		return nil, awserror.MalformedPolicy(err.Error())
+	}
+
+	for _, stmt := range p.Statement {
+		if stmt.Principal.IsNil() {
+			return nil, awserror.MalformedPolicy("Missing required field Principal")
+		}
+
+		err = stmt.Resource.ForEach(func(_ int, arn utils.ARN) error {
+			if arn.Service != "s3" || !(arn.Resource == bucketName || strings.HasPrefix(arn.Resource, bucketName+"/")) {
+				return awserror.MalformedPolicy("Policy has invalid resource")
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// getBucketPolicy returns the bucket policy exactly as it was submitted
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketPolicy.html
+func (s *ObjectStore) getBucketPolicy(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	var p []byte
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		p = meta.Policy
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(p) == 0 {
+		return awserror.NoSuchBucketPolicy()
+	}
+
+	r.Status(200).
+		ContentType("application/json").
+		AddHeader("Content-Length", strconv.Itoa(len(p))).
+		Reader(bytes.NewReader(p))
+
+	return nil
+}
+
+// putBucketPolicy validates and stores a bucket policy
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketPolicy.html
+func (s *ObjectStore) putBucketPolicy(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	reader, err := r.BodyReader()
+	if err != nil {
+		return err
+	}
+
+	body, err := s.getBody(r.Request().Header, reader)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseBucketPolicy(bucketName, body)
+	if err != nil {
+		return err
+	}
+
+	err = s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Policy = body
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(204)
+
+	return nil
+}
+
+// deleteBucketPolicy removes the bucket policy
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketPolicy.html
+func (s *ObjectStore) deleteBucketPolicy(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	err := s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Policy = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(204)
+
+	return nil
+}
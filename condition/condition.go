@@ -3,6 +3,7 @@ package condition
 import (
   "bytes"
   "encoding/json"
+  "fmt"
 )
 
 // Condition
@@ -41,3 +42,46 @@ func (a *Condition) MarshalJSON() ( []byte, error ) {
   b.WriteString( "}")
   return b.Bytes(), nil
 }
+
+// Validate checks every operator is supported and it's values are valid
+func (a Condition) Validate() error {
+  for name, ct := range a {
+    op, err := parseOperator( name )
+    if err != nil {
+      return err
+    }
+
+    for _, values := range ct {
+      if len(values) == 0 {
+        return fmt.Errorf( "No values for %s", name )
+      }
+      for i := range values {
+        if err := op.validate( &values[i] ); err != nil {
+          return err
+        }
+      }
+    }
+  }
+  return nil
+}
+
+// Evaluate evaluates the condition against the request context.
+//
+// Every operator and every key within an operator must match, whilst a key
+// matches if any of it's values match.
+func (a Condition) Evaluate( ctx Context ) (bool, error) {
+  for name, ct := range a {
+    op, err := parseOperator( name )
+    if err != nil {
+      return false, err
+    }
+
+    for key, values := range ct {
+      ok, err := op.evaluate( ctx, key, values )
+      if err != nil || !ok {
+        return false, err
+      }
+    }
+  }
+  return true, nil
+}
@@ -1,9 +1,14 @@
 package condition
 
 import (
+  "bytes"
   "encoding/json"
+  "strconv"
 )
 
+// A single value within a condition.
+// In JSON this can be a string, number or boolean. The string form is always
+// available as the operators coerce values to the type they require.
 type ConditionValue struct {
   t int
   s string
@@ -20,24 +25,83 @@ const (
   VAL_BOOL
 )
 
+// NewConditionValue returns a string ConditionValue
+func NewConditionValue( s string ) ConditionValue {
+  return ConditionValue{t: VAL_STRING, s: s}
+}
+
+// Type returns the type of the value, one of the VAL_ constants
+func (a *ConditionValue) Type() int {
+  return a.t
+}
+
+// String returns the value as a string
+func (a *ConditionValue) String() string {
+  return a.s
+}
+
+// Float returns the value as a float64
+func (a *ConditionValue) Float() (float64, error) {
+  switch a.t {
+    case VAL_INT:
+      return float64(a.i), nil
+    case VAL_FLOAT:
+      return a.f, nil
+    default:
+      return strconv.ParseFloat( a.s, 64 )
+  }
+}
+
+// Bool returns the value as a bool
+func (a *ConditionValue) Bool() (bool, error) {
+  if a.t == VAL_BOOL {
+    return a.b, nil
+  }
+  return strconv.ParseBool( a.s )
+}
+
 func (a *ConditionValue) UnmarshalJSON( b []byte ) error {
 
-  if b == nil || len(b) < 3 {
+  b = bytes.TrimSpace( b )
+  if len(b) == 0 || bytes.Equal( b, []byte("null") ) {
     a.t = VAL_NIL
     return nil
   }
 
-  var s string
-  err := json.Unmarshal( b, &s )
-  if err != nil {
-    return err
-  }
+  switch b[0] {
+    case '"':
+      var s string
+      err := json.Unmarshal( b, &s )
+      if err != nil {
+        return err
+      }
+      a.t = VAL_STRING
+      a.s = s
 
-  // All values have the string so we can use it to unmarshal
-  a.s = s
+    case 't', 'f':
+      err := json.Unmarshal( b, &a.b )
+      if err != nil {
+        return err
+      }
+      a.t = VAL_BOOL
+      a.s = strconv.FormatBool( a.b )
 
-  // TODO process here, for now just string
-  a.t = VAL_STRING
+    default:
+      // Numeric, keep the original form as the string value
+      a.s = string(b)
+      if i, err := strconv.Atoi( a.s ); err == nil {
+        a.t = VAL_INT
+        a.i = i
+        return nil
+      }
+
+      f, err := strconv.ParseFloat( a.s, 64 )
+      if err != nil {
+        return err
+      }
+      a.t = VAL_FLOAT
+      a.f = f
+  }
 
   return nil
 }
@@ -48,6 +112,11 @@ func (a *ConditionValue) MarshalJSON() ( []byte, error ) {
     return []byte("null"), nil
   }
 
+  // Numbers & booleans are written as is
+  if a.t != VAL_STRING {
+    return []byte(a.s), nil
+  }
+
   // Marshal the string value
   return json.Marshal( &(a.s) )
 }
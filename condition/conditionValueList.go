@@ -13,14 +13,11 @@ func (a *ConditionValueList) UnmarshalJSON( b []byte ) error {
   }
 
   bl := len(b)
-  if b[0]=='"' && b[bl-1]=='"' {
-    var s ConditionValue
-    err := json.Unmarshal( b, &s )
-    if err != nil {
-      return err
-    }
-    *a = append( *a, s )
-  } else if b[0]=='[' && b[bl-1]==']' {
+  if bl == 0 {
+    return nil
+  }
+
+  if b[0]=='[' && b[bl-1]==']' {
     var s []ConditionValue
     err := json.Unmarshal( b, &s )
     if err != nil {
@@ -29,6 +26,14 @@ func (a *ConditionValueList) UnmarshalJSON( b []byte ) error {
     for _, e := range s {
       *a = append( *a, e )
     }
+  } else {
+    // A single string, number or boolean
+    var s ConditionValue
+    err := json.Unmarshal( b, &s )
+    if err != nil {
+      return err
+    }
+    *a = append( *a, s )
   }
   return nil
 }
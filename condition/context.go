@@ -0,0 +1,60 @@
+package condition
+
+import (
+  "strings"
+)
+
+// Well known condition keys
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_condition-keys.html
+const (
+  KEY_CURRENT_TIME      = "aws:CurrentTime"
+  KEY_EPOCH_TIME        = "aws:EpochTime"
+  KEY_SECURE_TRANSPORT  = "aws:SecureTransport"
+  KEY_SOURCE_IP         = "aws:SourceIp"
+  KEY_USER_AGENT        = "aws:UserAgent"
+  KEY_USERNAME          = "aws:username"
+  KEY_USERID            = "aws:userid"
+  KEY_PRINCIPAL_ARN     = "aws:PrincipalArn"
+  KEY_REFERER           = "aws:Referer"
+  KEY_S3_PREFIX         = "s3:prefix"
+  KEY_S3_DELIMITER      = "s3:delimiter"
+  KEY_S3_MAX_KEYS       = "s3:max-keys"
+  KEY_S3_ACL            = "s3:x-amz-acl"
+  KEY_S3_COPY_SOURCE    = "s3:x-amz-copy-source"
+  KEY_S3_SIGNATURE      = "s3:signatureversion"
+  KEY_S3_AUTH_TYPE      = "s3:authType"
+  // Prefix of the s3:ExistingObjectTag/<key> keys
+  KEY_S3_EXISTING_TAG   = "s3:ExistingObjectTag/"
+  // Prefix of the s3:RequestObjectTag/<key> keys
+  KEY_S3_REQUEST_TAG    = "s3:RequestObjectTag/"
+)
+
+// The request context that conditions are evaluated against.
+// Keys are case insensitive and each key may have multiple values.
+type Context map[string][]string
+
+func NewContext() Context {
+  return make( Context )
+}
+
+// Set sets the values of a key
+func (c Context) Set( k string, v ...string ) Context {
+  c[strings.ToLower(k)] = v
+  return c
+}
+
+// Add appends values to a key
+func (c Context) Add( k string, v ...string ) Context {
+  k = strings.ToLower(k)
+  c[k] = append( c[k], v... )
+  return c
+}
+
+// Get returns the values of a key and true if the key is present
+func (c Context) Get( k string ) ([]string, bool) {
+  if c == nil {
+    return nil, false
+  }
+  v, ok := c[strings.ToLower(k)]
+  return v, ok
+}
@@ -0,0 +1,337 @@
+package condition
+
+import (
+  "fmt"
+  "github.com/peter-mount/objectstore/utils"
+  "net"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Condition operators
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_elements_condition_operators.html
+
+// The type of value an operator works with
+const (
+  kindString = iota
+  kindNumeric
+  kindDate
+  kindBool
+  kindBinary
+  kindIp
+  kindArn
+  kindNull
+)
+
+// Set qualifiers for multivalued keys
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_multi-value-conditions.html
+const (
+  setNone = iota
+  setForAllValues
+  setForAnyValue
+)
+
+// testFunc tests a value from the request context against a value in the policy
+type testFunc func( ctx string, v *ConditionValue ) (bool, error)
+
+type operatorDef struct {
+  kind    int
+  test    testFunc
+  // true if this is the negated form of test, e.g. StringNotEquals
+  negate  bool
+}
+
+var operators = map[string]operatorDef{
+  "StringEquals":               {kindString, stringEquals, false},
+  "StringNotEquals":            {kindString, stringEquals, true},
+  "StringEqualsIgnoreCase":     {kindString, stringEqualsIgnoreCase, false},
+  "StringNotEqualsIgnoreCase":  {kindString, stringEqualsIgnoreCase, true},
+  "StringLike":                 {kindString, stringLike, false},
+  "StringNotLike":              {kindString, stringLike, true},
+  "NumericEquals":              {kindNumeric, numericTest( func(a, b float64) bool { return a == b } ), false},
+  "NumericNotEquals":           {kindNumeric, numericTest( func(a, b float64) bool { return a == b } ), true},
+  "NumericLessThan":            {kindNumeric, numericTest( func(a, b float64) bool { return a < b } ), false},
+  "NumericLessThanEquals":      {kindNumeric, numericTest( func(a, b float64) bool { return a <= b } ), false},
+  "NumericGreaterThan":         {kindNumeric, numericTest( func(a, b float64) bool { return a > b } ), false},
+  "NumericGreaterThanEquals":   {kindNumeric, numericTest( func(a, b float64) bool { return a >= b } ), false},
+  "DateEquals":                 {kindDate, dateTest( func(a, b time.Time) bool { return a.Equal(b) } ), false},
+  "DateNotEquals":              {kindDate, dateTest( func(a, b time.Time) bool { return a.Equal(b) } ), true},
+  "DateLessThan":               {kindDate, dateTest( func(a, b time.Time) bool { return a.Before(b) } ), false},
+  "DateLessThanEquals":         {kindDate, dateTest( func(a, b time.Time) bool { return !a.After(b) } ), false},
+  "DateGreaterThan":            {kindDate, dateTest( func(a, b time.Time) bool { return a.After(b) } ), false},
+  "DateGreaterThanEquals":      {kindDate, dateTest( func(a, b time.Time) bool { return !a.Before(b) } ), false},
+  "Bool":                       {kindBool, boolEquals, false},
+  "BinaryEquals":               {kindBinary, stringEquals, false},
+  "IpAddress":                  {kindIp, ipAddress, false},
+  "NotIpAddress":               {kindIp, ipAddress, true},
+  "ArnEquals":                  {kindArn, arnLike, false},
+  "ArnLike":                    {kindArn, arnLike, false},
+  "ArnNotEquals":               {kindArn, arnLike, true},
+  "ArnNotLike":                 {kindArn, arnLike, true},
+  "Null":                       {kindNull, nil, false},
+}
+
+// A parsed operator including any qualifiers
+type operator struct {
+  name      string
+  set       int
+  ifExists  bool
+  def       operatorDef
+}
+
+// parseOperator parses an operator name, e.g. "ForAnyValue:StringLikeIfExists"
+func parseOperator( name string ) (*operator, error) {
+  o := &operator{name: name}
+
+  n := name
+  if strings.HasPrefix( n, "ForAllValues:" ) {
+    o.set = setForAllValues
+    n = n[len("ForAllValues:"):]
+  } else if strings.HasPrefix( n, "ForAnyValue:" ) {
+    o.set = setForAnyValue
+    n = n[len("ForAnyValue:"):]
+  }
+
+  if strings.HasSuffix( n, "IfExists" ) {
+    o.ifExists = true
+    n = n[:len(n)-len("IfExists")]
+  }
+
+  def, exists := operators[n]
+  if !exists || (def.kind == kindNull && (o.ifExists || o.set != setNone)) {
+    return nil, fmt.Errorf( "Unsupported condition operator %s", name )
+  }
+  o.def = def
+
+  return o, nil
+}
+
+// validate checks a policy value is valid for this operator
+func (o *operator) validate( v *ConditionValue ) error {
+  var err error
+  switch o.def.kind {
+    case kindNumeric:
+      _, err = v.Float()
+    case kindDate:
+      _, err = parseDate( v.String() )
+    case kindBool, kindNull:
+      _, err = v.Bool()
+    case kindIp:
+      _, err = parseCIDR( v.String() )
+    case kindArn:
+      _, err = utils.ParseARN( v.String() )
+  }
+  if err != nil {
+    return fmt.Errorf( "Invalid value %q for %s", v.String(), o.name )
+  }
+  return nil
+}
+
+// evaluate tests a single key in the request context against the policy values
+func (o *operator) evaluate( ctx Context, key string, values ConditionValueList ) (bool, error) {
+  cvs, exists := ctx.Get( key )
+  exists = exists && len(cvs) > 0
+
+  if o.def.kind == kindNull {
+    if len(values) == 0 {
+      return false, fmt.Errorf( "No value for %s", o.name )
+    }
+    null, err := values[0].Bool()
+    if err != nil {
+      return false, err
+    }
+    return null != exists, nil
+  }
+
+  if !exists {
+    switch o.set {
+      case setForAllValues:
+        // Vacuously true
+        return true, nil
+      case setForAnyValue:
+        return false, nil
+      default:
+        // Negated operators match when the key is missing
+        return o.ifExists || o.def.negate, nil
+    }
+  }
+
+  if o.set == setForAllValues {
+    for _, cv := range cvs {
+      ok, err := o.matches( cv, values )
+      if err != nil || !ok {
+        return false, err
+      }
+    }
+    return true, nil
+  }
+
+  // ForAnyValue and single valued keys match if any value in the context matches
+  for _, cv := range cvs {
+    ok, err := o.matches( cv, values )
+    if err != nil || ok {
+      return ok, err
+    }
+  }
+  return false, nil
+}
+
+// matches tests a single context value against the policy values.
+// For the positive operators any policy value must match, for the negated
+// operators none of them must match.
+func (o *operator) matches( cv string, values ConditionValueList ) (bool, error) {
+  for i := range values {
+    ok, err := o.def.test( cv, &values[i] )
+    if err != nil {
+      return false, err
+    }
+    if ok {
+      return !o.def.negate, nil
+    }
+  }
+  return o.def.negate, nil
+}
+
+func stringEquals( ctx string, v *ConditionValue ) (bool, error) {
+  return ctx == v.String(), nil
+}
+
+func stringEqualsIgnoreCase( ctx string, v *ConditionValue ) (bool, error) {
+  return strings.EqualFold( ctx, v.String() ), nil
+}
+
+func stringLike( ctx string, v *ConditionValue ) (bool, error) {
+  return wildcardMatch( v.String(), ctx ), nil
+}
+
+func numericTest( f func(a, b float64) bool ) testFunc {
+  return func( ctx string, v *ConditionValue ) (bool, error) {
+    a, err := strconv.ParseFloat( ctx, 64 )
+    if err != nil {
+      // Not a number so doesn't match
+      return false, nil
+    }
+    b, err := v.Float()
+    if err != nil {
+      return false, err
+    }
+    return f( a, b ), nil
+  }
+}
+
+func dateTest( f func(a, b time.Time) bool ) testFunc {
+  return func( ctx string, v *ConditionValue ) (bool, error) {
+    a, err := parseDate( ctx )
+    if err != nil {
+      return false, nil
+    }
+    b, err := parseDate( v.String() )
+    if err != nil {
+      return false, err
+    }
+    return f( a, b ), nil
+  }
+}
+
+// parseDate parses a date in either ISO 8601 or epoch (seconds) form
+func parseDate( s string ) (time.Time, error) {
+  if i, err := strconv.ParseInt( s, 10, 64 ); err == nil {
+    return time.Unix( i, 0 ), nil
+  }
+
+  for _, l := range []string{ time.RFC3339Nano, "20060102T150405Z", "2006-01-02T15:04Z", "2006-01-02" } {
+    if t, err := time.Parse( l, s ); err == nil {
+      return t, nil
+    }
+  }
+
+  return time.Time{}, fmt.Errorf( "Invalid date %q", s )
+}
+
+func boolEquals( ctx string, v *ConditionValue ) (bool, error) {
+  a, err := strconv.ParseBool( ctx )
+  if err != nil {
+    return false, nil
+  }
+  b, err := v.Bool()
+  if err != nil {
+    return false, err
+  }
+  return a == b, nil
+}
+
+// parseCIDR parses an IP range. A single address is treated as that address only.
+func parseCIDR( s string ) (*net.IPNet, error) {
+  if !strings.Contains( s, "/" ) {
+    ip := net.ParseIP( s )
+    if ip == nil {
+      return nil, fmt.Errorf( "Invalid ip address %q", s )
+    }
+    bits := 128
+    if ip.To4() != nil {
+      ip = ip.To4()
+      bits = 32
+    }
+    return &net.IPNet{IP: ip, Mask: net.CIDRMask( bits, bits )}, nil
+  }
+
+  _, n, err := net.ParseCIDR( s )
+  return n, err
+}
+
+func ipAddress( ctx string, v *ConditionValue ) (bool, error) {
+  ip := net.ParseIP( ctx )
+  if ip == nil {
+    return false, nil
+  }
+  n, err := parseCIDR( v.String() )
+  if err != nil {
+    return false, err
+  }
+  return n.Contains( ip ), nil
+}
+
+func arnLike( ctx string, v *ConditionValue ) (bool, error) {
+  a, err := utils.ParseARN( ctx )
+  if err != nil {
+    return false, nil
+  }
+  p, err := utils.ParseARN( v.String() )
+  if err != nil {
+    return false, err
+  }
+  return p.IsAnonymous() || p.Matches( a ), nil
+}
+
+// wildcardMatch matches s against a pattern where * matches any sequence of
+// characters and ? matches any single character.
+func wildcardMatch( pattern, s string ) bool {
+  p, str := []rune(pattern), []rune(s)
+  pi, si := 0, 0
+  // Position of the last * in the pattern & the position in s it matched from
+  star, match := -1, 0
+
+  for si < len(str) {
+    switch {
+      case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+        pi++
+        si++
+      case pi < len(p) && p[pi] == '*':
+        star, match = pi, si
+        pi++
+      case star >= 0:
+        // Backtrack, let the last * consume one more character
+        pi = star + 1
+        match++
+        si = match
+      default:
+        return false
+    }
+  }
+
+  for pi < len(p) && p[pi] == '*' {
+    pi++
+  }
+  return pi == len(p)
+}
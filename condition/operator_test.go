@@ -0,0 +1,126 @@
+package condition
+
+import (
+  "encoding/json"
+  "testing"
+)
+
+func testCondition( t *testing.T, s string ) Condition {
+  var c Condition
+  if err := json.Unmarshal( []byte(s), &c ); err != nil {
+    t.Fatal( err )
+  }
+  if err := c.Validate(); err != nil {
+    t.Fatal( err )
+  }
+  return c
+}
+
+func TestCondition_Evaluate( t *testing.T ) {
+  ctx := NewContext().
+    Set( KEY_SOURCE_IP, "192.168.1.20" ).
+    Set( KEY_SECURE_TRANSPORT, "true" ).
+    Set( KEY_CURRENT_TIME, "2019-06-01T12:00:00Z" ).
+    Set( KEY_S3_PREFIX, "home/bob/" ).
+    Set( KEY_S3_MAX_KEYS, "100" ).
+    Set( KEY_PRINCIPAL_ARN, "arn:aws:iam::123456789012:user/Bob" ).
+    Set( "aws:TagKeys", "project", "owner" )
+
+  for i, test := range []struct {
+    condition string
+    expected  bool
+  }{
+    {`{"StringEquals": {"s3:prefix": "home/bob/"}}`, true},
+    {`{"StringEquals": {"s3:prefix": ["home/alice/", "home/bob/"]}}`, true},
+    {`{"StringEquals": {"s3:prefix": "home/alice/"}}`, false},
+    {`{"StringNotEquals": {"s3:prefix": "home/alice/"}}`, true},
+    {`{"StringEqualsIgnoreCase": {"s3:prefix": "HOME/BOB/"}}`, true},
+    {`{"StringLike": {"s3:prefix": "home/*"}}`, true},
+    {`{"StringLike": {"s3:prefix": "home/b?b/"}}`, true},
+    {`{"StringNotLike": {"s3:prefix": "home/*"}}`, false},
+    // Keys are case insensitive
+    {`{"StringEquals": {"S3:Prefix": "home/bob/"}}`, true},
+    {`{"NumericLessThanEquals": {"s3:max-keys": "100"}}`, true},
+    {`{"NumericLessThan": {"s3:max-keys": 100}}`, false},
+    {`{"NumericGreaterThan": {"s3:max-keys": 10}}`, true},
+    {`{"DateLessThan": {"aws:CurrentTime": "2020-01-01T00:00:00Z"}}`, true},
+    {`{"DateGreaterThan": {"aws:CurrentTime": "2020-01-01T00:00:00Z"}}`, false},
+    {`{"DateGreaterThan": {"aws:CurrentTime": 1546300800}}`, true},
+    {`{"Bool": {"aws:SecureTransport": "true"}}`, true},
+    {`{"Bool": {"aws:SecureTransport": false}}`, false},
+    {`{"IpAddress": {"aws:SourceIp": "192.168.1.0/24"}}`, true},
+    {`{"IpAddress": {"aws:SourceIp": ["10.0.0.0/8", "192.168.1.20"]}}`, true},
+    {`{"NotIpAddress": {"aws:SourceIp": "192.168.1.0/24"}}`, false},
+    {`{"ArnLike": {"aws:PrincipalArn": "arn:aws:iam::123456789012:user/*"}}`, true},
+    {`{"ArnNotEquals": {"aws:PrincipalArn": "arn:aws:iam::123456789012:user/Bob"}}`, false},
+    {`{"Null": {"aws:Referer": "true"}}`, true},
+    {`{"Null": {"aws:SourceIp": "true"}}`, false},
+    // Missing keys
+    {`{"StringEquals": {"aws:Referer": "example.com"}}`, false},
+    {`{"StringNotEquals": {"aws:Referer": "example.com"}}`, true},
+    {`{"StringEqualsIfExists": {"aws:Referer": "example.com"}}`, true},
+    {`{"StringEqualsIfExists": {"s3:prefix": "home/alice/"}}`, false},
+    // Multivalued keys
+    {`{"ForAllValues:StringEquals": {"aws:TagKeys": ["project", "owner", "cost"]}}`, true},
+    {`{"ForAllValues:StringEquals": {"aws:TagKeys": ["project"]}}`, false},
+    {`{"ForAllValues:StringEquals": {"aws:Missing": ["project"]}}`, true},
+    {`{"ForAnyValue:StringEquals": {"aws:TagKeys": ["owner"]}}`, true},
+    {`{"ForAnyValue:StringEquals": {"aws:TagKeys": ["cost"]}}`, false},
+    {`{"ForAnyValue:StringEquals": {"aws:Missing": ["cost"]}}`, false},
+    // All operators & keys must match
+    {`{"Bool": {"aws:SecureTransport": "true"}, "IpAddress": {"aws:SourceIp": "192.168.1.0/24"}}`, true},
+    {`{"Bool": {"aws:SecureTransport": "true"}, "IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}`, false},
+    {`{"StringLike": {"s3:prefix": "home/*", "aws:PrincipalArn": "*Alice"}}`, false},
+  } {
+    c := testCondition( t, test.condition )
+    got, err := c.Evaluate( ctx )
+    if err != nil {
+      t.Errorf( "%d: %s: %v", i, test.condition, err )
+    } else if got != test.expected {
+      t.Errorf( "%d: %s: expected %v got %v", i, test.condition, test.expected, got )
+    }
+  }
+}
+
+func TestCondition_Validate( t *testing.T ) {
+  for i, s := range []string{
+    `{"StringMaybe": {"s3:prefix": "home/"}}`,
+    `{"ForAllValues:Null": {"s3:prefix": "true"}}`,
+    `{"NumericEquals": {"s3:max-keys": "ten"}}`,
+    `{"DateLessThan": {"aws:CurrentTime": "tomorrow"}}`,
+    `{"Bool": {"aws:SecureTransport": "yes please"}}`,
+    `{"IpAddress": {"aws:SourceIp": "192.168.1.0/33"}}`,
+    `{"StringEquals": {"s3:prefix": []}}`,
+  } {
+    var c Condition
+    if err := json.Unmarshal( []byte(s), &c ); err != nil {
+      t.Fatal( err )
+    }
+    if err := c.Validate(); err == nil {
+      t.Errorf( "%d: %s: expected error", i, s )
+    }
+  }
+}
+
+func TestWildcardMatch( t *testing.T ) {
+  for i, test := range []struct {
+    pattern  string
+    s        string
+    expected bool
+  }{
+    {"", "", true},
+    {"*", "", true},
+    {"*", "anything", true},
+    {"a*c", "abbbc", true},
+    {"a*c", "abbbd", false},
+    {"a?c", "abc", true},
+    {"a?c", "ac", false},
+    {"*/b/*", "a/b/c", true},
+    {"*b*b", "abcbcb", true},
+    {"abc", "abcd", false},
+  } {
+    if got := wildcardMatch( test.pattern, test.s ); got != test.expected {
+      t.Errorf( "%d: %q %q expected %v", i, test.pattern, test.s, test.expected )
+    }
+  }
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"github.com/peter-mount/go-kernel/v2/bolt"
 	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
 	"github.com/peter-mount/objectstore/awserror"
 	"strings"
 	"time"
@@ -30,13 +31,28 @@ func (s *ObjectStore) copyObject(r *rest.Rest) error {
 	destBucketName := r.Var("DestBucketName")
 	destObjectName := r.Var("DestObjectName")
 
+	// Tags are copied unless the directive is REPLACE
+	replaceTags := r.GetHeader("X-Amz-Tagging-Directive") == "REPLACE"
+	tags, err := parseTaggingHeader(r.Request().Header)
+	if err != nil {
+		return err
+	}
+
+	// Caller must be able to read the source object
+	err = s.authorizeRequest(r, actionGetObject, srcBucketName, srcObjectName)
+	if err != nil {
+		return err
+	}
+
+	cred := auth.RequestCredential(r)
+
 	return s.boltService.Update(func(tx *bolt.Tx) error {
 		sb, err := s.getBucket(tx, srcBucketName)
 		if err != nil {
 			return err
 		}
 
-		db, err := s.getBucket(tx, destBucketName)
+		db, destMeta, err := s.getBucketMeta(tx, destBucketName)
 		if err != nil {
 			return err
 		}
@@ -47,6 +63,12 @@ func (s *ObjectStore) copyObject(r *rest.Rest) error {
 			return err
 		}
 
+		// The new object is owned by the caller & does not inherit the source ACL
+		acl, err := aclFromHeaders(r.Request().Header, newOwner(cred), s.bucketOwner(destMeta))
+		if err != nil {
+			return err
+		}
+
 		dstObj := &Object{
 			Name: destObjectName,
 			// FIXME: This is default of copy directive
@@ -54,6 +76,25 @@ func (s *ObjectStore) copyObject(r *rest.Rest) error {
 			LastModified: srcObj.LastModified,
 			Length:       srcObj.Length,
 			ETag:         srcObj.ETag,
+			Tags:         srcObj.Tags,
+			ACL:          acl,
+		}
+
+		if replaceTags {
+			dstObj.Tags = tags
+		}
+
+		// Check the quota before copying, replacing any existing object
+		oldSize, oldCount := objectUsage(db, destObjectName)
+		err = s.updateUsage(tx, db, destBucketName, destMeta, int64(srcObj.Length)-oldSize, 1-oldCount)
+		if err != nil {
+			return err
+		}
+
+		// Remove any existing object unless we are copying it onto itself
+		oldObj := &Object{}
+		if (srcBucketName != destBucketName || srcObjectName != destObjectName) && oldObj.get(db, destObjectName) == nil {
+			oldObj.delete(db)
 		}
 
 		for _, part := range srcObj.Parts {
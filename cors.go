@@ -0,0 +1,339 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of rules in a CORS configuration
+const maxCORSRules = 100
+
+// The methods a CORSRule may allow
+var corsMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPut:    true,
+	http.MethodHead:   true,
+	http.MethodPost:   true,
+	http.MethodDelete: true,
+}
+
+// CORSConfiguration is the request/response body used by the ?cors sub-resource
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
+type CORSConfiguration struct {
+	XMLName   xml.Name   `xml:"CORSConfiguration" bson:"-"`
+	Xmlns     string     `xml:"xmlns,attr,omitempty" bson:"-"`
+	CORSRules []CORSRule `xml:"CORSRule"`
+}
+
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// validate checks a CORS configuration is valid
+func (c *CORSConfiguration) validate() error {
+	if len(c.CORSRules) == 0 {
+		return awserror.MalformedXML()
+	}
+	if len(c.CORSRules) > maxCORSRules {
+		return awserror.InvalidRequest("The number of CORS rules should not exceed allowed limit of %d rules.", maxCORSRules)
+	}
+
+	for _, rule := range c.CORSRules {
+		if len(rule.AllowedMethods) == 0 || len(rule.AllowedOrigins) == 0 {
+			return awserror.MalformedXML()
+		}
+
+		for _, m := range rule.AllowedMethods {
+			if !corsMethods[m] {
+				return awserror.InvalidRequest("Found unsupported HTTP method in CORS config. Unsupported method is %s", m)
+			}
+		}
+
+		for _, o := range rule.AllowedOrigins {
+			if strings.Count(o, "*") > 1 {
+				return awserror.InvalidRequest("AllowedOrigin \"%s\" can not have more than one wildcard.", o)
+			}
+		}
+
+		for _, h := range rule.AllowedHeaders {
+			if strings.Count(h, "*") > 1 {
+				return awserror.InvalidRequest("AllowedHeader \"%s\" can not have more than one wildcard.", h)
+			}
+		}
+
+		if rule.MaxAgeSeconds < 0 {
+			return awserror.MalformedXML()
+		}
+	}
+
+	return nil
+}
+
+// match returns the first rule allowing a request or nil if none do.
+// headers are the headers of a preflight's Access-Control-Request-Headers.
+func (c *CORSConfiguration) match(origin, method string, headers []string) *CORSRule {
+	for i, rule := range c.CORSRules {
+		if rule.allows(origin, method, headers) {
+			return &c.CORSRules[i]
+		}
+	}
+	return nil
+}
+
+// preflight returns the rule allowing a preflight request.
+// c may be nil for a bucket without a CORS configuration.
+func (c *CORSConfiguration) preflight(origin, method string, headers []string) (*CORSRule, error) {
+	if c == nil {
+		return nil, awserror.CORSNotEnabled()
+	}
+
+	rule := c.match(origin, method, headers)
+	if rule == nil {
+		return nil, awserror.CORSNotAllowed()
+	}
+
+	return rule, nil
+}
+
+// corsRequestHeaders returns the headers listed in a preflight's
+// Access-Control-Request-Headers
+func corsRequestHeaders(header http.Header) []string {
+	var headers []string
+	for _, v := range header["Access-Control-Request-Headers"] {
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				headers = append(headers, h)
+			}
+		}
+	}
+	return headers
+}
+
+func (rule *CORSRule) allows(origin, method string, headers []string) bool {
+	if !corsContains(rule.AllowedMethods, method, false) || !corsContains(rule.AllowedOrigins, origin, false) {
+		return false
+	}
+
+	for _, h := range headers {
+		if !corsContains(rule.AllowedHeaders, h, true) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// corsContains returns true if one of patterns matches s.
+// A pattern may contain a single * wildcard.
+func corsContains(patterns []string, s string, ignoreCase bool) bool {
+	if ignoreCase {
+		s = strings.ToLower(s)
+	}
+
+	for _, p := range patterns {
+		if ignoreCase {
+			p = strings.ToLower(p)
+		}
+
+		if i := strings.Index(p, "*"); i < 0 {
+			if p == s {
+				return true
+			}
+		} else if len(s) >= len(p)-1 && strings.HasPrefix(s, p[:i]) && strings.HasSuffix(s, p[i+1:]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addHeaders adds the CORS response headers for a request from origin
+func (rule *CORSRule) addHeaders(r *rest.Rest, origin string) {
+	// Only a rule allowing any origin returns * as the origin
+	allowOrigin := origin
+	if len(rule.AllowedOrigins) == 1 && rule.AllowedOrigins[0] == "*" {
+		allowOrigin = "*"
+	}
+
+	r.AddHeader("Access-Control-Allow-Origin", allowOrigin).
+		AddHeader("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", ")).
+		AddHeader("Vary", "Origin, Access-Control-Request-Headers, Access-Control-Request-Method")
+
+	if allowOrigin != "*" {
+		r.AddHeader("Access-Control-Allow-Credentials", "true")
+	}
+
+	if len(rule.ExposeHeaders) > 0 {
+		r.AddHeader("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+
+	if rule.MaxAgeSeconds > 0 {
+		r.AddHeader("Access-Control-Max-Age", strconv.Itoa(rule.MaxAgeSeconds))
+	}
+}
+
+// getBucketCORS returns a bucket's CORS configuration or nil if it has none
+func (s *ObjectStore) getBucketCORS(bucketName string) (*CORSConfiguration, error) {
+	var c *CORSConfiguration
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		c = meta.CORS
+		return nil
+	})
+	return c, err
+}
+
+// corsDecorator adds the CORS headers to requests to a bucket with a rule
+// allowing the request's Origin and method
+func (s *ObjectStore) corsDecorator(h rest.RestHandler) rest.RestHandler {
+	return func(r *rest.Rest) error {
+		origin := r.GetHeader("Origin")
+		bucketName := requestBucketName(r)
+
+		if origin != "" && bucketName != "" {
+			c, err := s.getBucketCORS(bucketName)
+			if err != nil {
+				// A missing bucket is reported by the handler itself
+				if awserror.ToError(err).Code != "NoSuchBucket" {
+					log.Printf("Failed to get the CORS configuration of %s: %v", bucketName, err)
+				}
+			} else if c != nil {
+				if rule := c.match(origin, r.Request().Method, nil); rule != nil {
+					rule.addHeaders(r, origin)
+				}
+			}
+		}
+
+		return h(r)
+	}
+}
+
+// corsPreflight handles an OPTIONS preflight request
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTOPTIONSobject.html
+func (s *ObjectStore) corsPreflight(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	origin := r.GetHeader("Origin")
+	if origin == "" {
+		return awserror.InvalidRequest("Insufficient information. Origin request header needed.")
+	}
+
+	method := r.GetHeader("Access-Control-Request-Method")
+	if method == "" {
+		return awserror.InvalidRequest("Invalid Access-Control-Request-Method: null")
+	}
+
+	headers := corsRequestHeaders(r.Request().Header)
+
+	c, err := s.getBucketCORS(bucketName)
+	if err != nil {
+		return err
+	}
+
+	rule, err := c.preflight(origin, method, headers)
+	if err != nil {
+		return err
+	}
+
+	rule.addHeaders(r, origin)
+	if len(headers) > 0 {
+		r.AddHeader("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+
+	r.Status(200)
+
+	return nil
+}
+
+// getBucketCors returns the CORS configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketCors.html
+func (s *ObjectStore) getBucketCors(r *rest.Rest) error {
+	c, err := s.getBucketCORS(r.Var("BucketName"))
+	if err != nil {
+		return err
+	}
+
+	if c == nil {
+		return awserror.NoSuchCORSConfiguration()
+	}
+
+	c.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	r.Status(200).
+		XML().
+		Value(c)
+
+	return nil
+}
+
+// putBucketCors validates and stores the CORS configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketCors.html
+func (s *ObjectStore) putBucketCors(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	reader, err := r.BodyReader()
+	if err != nil {
+		return err
+	}
+
+	body, err := s.getBody(r.Request().Header, reader)
+	if err != nil {
+		return err
+	}
+
+	c := &CORSConfiguration{}
+	err = xml.Unmarshal(body, c)
+	if err != nil {
+		return awserror.MalformedXML()
+	}
+
+	err = c.validate()
+	if err != nil {
+		return err
+	}
+
+	err = s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.CORS = c
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200)
+
+	return nil
+}
+
+// deleteBucketCors removes the CORS configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketCors.html
+func (s *ObjectStore) deleteBucketCors(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	err := s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.CORS = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(204)
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package objectstore
+
+import (
+	"github.com/peter-mount/objectstore/awserror"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCorsContains(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		patterns   []string
+		s          string
+		ignoreCase bool
+		want       bool
+	}{
+		{name: "exact", patterns: []string{"http://www.example.com"}, s: "http://www.example.com", want: true},
+		{name: "exact differs", patterns: []string{"http://www.example.com"}, s: "http://www.example.org"},
+		{name: "second pattern", patterns: []string{"http://a.example.com", "http://b.example.com"}, s: "http://b.example.com", want: true},
+		{name: "no patterns", s: "http://www.example.com"},
+		{name: "any", patterns: []string{"*"}, s: "http://www.example.com", want: true},
+		{name: "any empty", patterns: []string{"*"}, s: "", want: true},
+		{name: "subdomain", patterns: []string{"http://*.example.com"}, s: "http://www.example.com", want: true},
+		{name: "subdomain other domain", patterns: []string{"http://*.example.com"}, s: "http://www.example.org"},
+		{name: "subdomain other scheme", patterns: []string{"http://*.example.com"}, s: "https://www.example.com"},
+		// The prefix & suffix must not overlap
+		{name: "subdomain too short", patterns: []string{"http://*.example.com"}, s: "http://.example.com", want: true},
+		{name: "overlapping", patterns: []string{"ab*ba"}, s: "aba"},
+		{name: "prefix", patterns: []string{"x-amz-*"}, s: "x-amz-date", want: true},
+		{name: "suffix", patterns: []string{"*-date"}, s: "x-amz-date", want: true},
+		{name: "case sensitive", patterns: []string{"x-amz-*"}, s: "X-Amz-Date"},
+		{name: "ignore case", patterns: []string{"x-amz-*"}, s: "X-Amz-Date", ignoreCase: true, want: true},
+		{name: "ignore case pattern", patterns: []string{"Content-Type"}, s: "content-type", ignoreCase: true, want: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := corsContains(test.patterns, test.s, test.ignoreCase); got != test.want {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func testCORSConfiguration() *CORSConfiguration {
+	return &CORSConfiguration{CORSRules: []CORSRule{
+		{
+			ID:             "write",
+			AllowedMethods: []string{"PUT", "POST", "DELETE"},
+			AllowedOrigins: []string{"http://www.example.com"},
+			AllowedHeaders: []string{"*"},
+		},
+		{
+			ID:             "subdomains",
+			AllowedMethods: []string{"PUT"},
+			AllowedOrigins: []string{"http://*.example.com"},
+			AllowedHeaders: []string{"Content-Type", "x-amz-*"},
+		},
+		{
+			ID:             "read",
+			AllowedMethods: []string{"GET", "HEAD"},
+			AllowedOrigins: []string{"*"},
+		},
+	}}
+}
+
+func TestCORSConfiguration_match(t *testing.T) {
+	c := testCORSConfiguration()
+
+	for _, test := range []struct {
+		name    string
+		origin  string
+		method  string
+		headers []string
+		want    string
+	}{
+		{name: "first rule", origin: "http://www.example.com", method: "PUT", headers: []string{"Authorization"}, want: "write"},
+		{name: "wildcard origin", origin: "http://images.example.com", method: "PUT", want: "subdomains"},
+		{name: "allowed headers", origin: "http://images.example.com", method: "PUT", headers: []string{"content-type", "X-Amz-Date"}, want: "subdomains"},
+		{name: "header not allowed", origin: "http://images.example.com", method: "PUT", headers: []string{"Authorization"}},
+		{name: "method not allowed", origin: "http://images.example.com", method: "DELETE"},
+		{name: "any origin", origin: "http://www.example.org", method: "GET", want: "read"},
+		{name: "any origin no headers", origin: "http://www.example.org", method: "GET", headers: []string{"Range"}},
+		{name: "method case", origin: "http://www.example.org", method: "get"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := c.match(test.origin, test.method, test.headers)
+			switch {
+			case test.want == "" && got != nil:
+				t.Errorf("unexpected rule %q", got.ID)
+			case test.want != "" && got == nil:
+				t.Errorf("expected rule %q", test.want)
+			case test.want != "" && got.ID != test.want:
+				t.Errorf("got rule %q want %q", got.ID, test.want)
+			}
+		})
+	}
+}
+
+func TestCORSConfiguration_preflight(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		c      *CORSConfiguration
+		origin string
+		method string
+		want   string
+		err    *awserror.Error
+	}{
+		{name: "allowed", c: testCORSConfiguration(), origin: "http://www.example.com", method: "DELETE", want: "write"},
+		{name: "not enabled", origin: "http://www.example.com", method: "DELETE", err: awserror.CORSNotEnabled()},
+		{name: "not allowed", c: testCORSConfiguration(), origin: "http://www.example.org", method: "DELETE", err: awserror.CORSNotAllowed()},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// Both errors share the AccessForbidden code so compare the messages
+			rule, err := test.c.preflight(test.origin, test.method, nil)
+			switch {
+			case test.err == nil && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case test.err != nil && err == nil:
+				t.Errorf("expected %q", test.err.Message)
+			case test.err != nil && awserror.ToError(err).Message != test.err.Message:
+				t.Errorf("got %q expected %q", awserror.ToError(err).Message, test.err.Message)
+			case test.err == nil && rule.ID != test.want:
+				t.Errorf("got rule %q want %q", rule.ID, test.want)
+			}
+		})
+	}
+}
+
+func TestCorsRequestHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Add("Access-Control-Request-Headers", "Content-Type, x-amz-date")
+	header.Add("Access-Control-Request-Headers", " ,Authorization,")
+
+	want := []string{"Content-Type", "x-amz-date", "Authorization"}
+	if got := corsRequestHeaders(header); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	if got := corsRequestHeaders(http.Header{}); got != nil {
+		t.Errorf("got %q want none", got)
+	}
+}
@@ -28,6 +28,7 @@ func (s *ObjectStore) sendObjectEvent(eventName, bucketName string, obj *Object)
 				Key:  obj.Name,
 				Size: obj.Length,
 				ETag: obj.ETag,
+				Tags: obj.Tags,
 			},
 		},
 	})
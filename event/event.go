@@ -55,6 +55,8 @@ type S3Object struct {
   ETag            string            `json:"eTag"`
   VersionId      *string            `json:"versionId"`
   Sequencer       string            `json:"sequencer"`
+  // The object's tags, used when filtering but not published
+  Tags            map[string]string `json:"-"`
 }
 
 func (evt *Event) RoutingKey() string {
@@ -14,10 +14,13 @@ type NotificationConfiguration struct {
   RabbitConfig []*RabbitConfiguration      `json:"RabbitConfig" xml:"RabbitConfig" yaml:"RabbitConfig"`
 }
 
+// loadConfig loads the config file. The brokers in use are only replaced if
+// the new config is valid.
 func (a *EventService) loadConfig() error {
-  a.mqInstances = make( map[string]*RabbitMQ )
+  instances := make( map[string]*RabbitMQ )
 
   if *a.configFile == "" {
+    a.mqInstances.Store( &instances )
     return nil
   }
 
@@ -36,11 +39,41 @@ func (a *EventService) loadConfig() error {
   }
 
   if config.RabbitConfig != nil {
-    err = a.loadRabbitConfig( config.RabbitConfig )
+    err = a.loadRabbitConfig( instances, config.RabbitConfig )
     if err != nil {
       return err
     }
   }
 
+  current := a.rabbitInstances()
+
+  // Once started new brokers must be connected before they are used
+  if a.started {
+    err = connectRabbitMQ( instances )
+    if err != nil {
+      // Close those connected for this config, keeping those in use
+      closeRabbitMQ( instances, current )
+      return err
+    }
+  }
+
+  // Publishers hold the read lock so no broker is closed whilst in use
+  a.mutex.Lock()
+  defer a.mutex.Unlock()
+
+  a.mqInstances.Store( &instances )
+
+  // Close the brokers no longer in the config
+  closeRabbitMQ( current, instances )
   return nil
 }
+
+// reloadConfig reloads the config file. If it is invalid then the current
+// config remains in use.
+func (a *EventService) reloadConfig() {
+  if err := a.loadConfig(); err != nil {
+    log.Printf( "Rejected event config %s: %v", *a.configFile, err )
+    return
+  }
+  log.Printf( "Reloaded event config %s", *a.configFile )
+}
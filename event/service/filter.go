@@ -1,6 +1,7 @@
 package service
 
 import (
+  "github.com/peter-mount/go-glob"
   "github.com/peter-mount/objectstore/event"
   "strings"
 )
@@ -8,6 +9,8 @@ import (
 // Filter config
 type Filter struct {
   S3Key         S3Key       `json:"S3Key" xml:"S3Key" yaml:"S3Key"`
+  // Our extension, filter on the object's tags
+  Tag         []FilterRule  `json:"Tag" xml:"Tag" yaml:"Tag"`
 }
 
 type S3Key struct {
@@ -32,6 +35,18 @@ func (f *Filter) Test( evt *event.Event ) bool {
     }
   }
 
+  if len( f.Tag ) > 0 {
+    if evt.S3 == nil || evt.S3.Object == nil {
+      return false
+    }
+
+    for _, fr := range f.Tag {
+      if !fr.TestTag( evt ) {
+        return false
+      }
+    }
+  }
+
   return true
 }
 
@@ -44,3 +59,13 @@ func (f *FilterRule) Test( evt *event.Event ) bool {
   }
   return false
 }
+
+// TestTag tests the event's object has a tag whose key is Name.
+// If Value is not "" then the tag's value must also match, which can be a glob.
+func (f *FilterRule) TestTag( evt *event.Event ) bool {
+  v, exists := evt.S3.Object.Tags[f.Name]
+  if !exists {
+    return false
+  }
+  return f.Value == "" || glob.Glob( f.Value, v )
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/peter-mount/go-kernel/v2/rabbitmq"
 	"github.com/peter-mount/objectstore/event"
+	"log"
 )
 
 // Our extension, a RabbitMQ connection
@@ -20,12 +21,19 @@ type RabbitConfiguration struct {
 }
 
 type RabbitMQ struct {
-	mq rabbitmq.RabbitMQ
+	// The connection, shared with the previous config when reloaded
+	mq *rabbitmq.RabbitMQ
 	// Config for this instance
 	config []*RabbitConfiguration
+	// true once connected
+	connected bool
 }
 
-func (a *EventService) loadRabbitConfig(conf []*RabbitConfiguration) error {
+// loadRabbitConfig adds the brokers in conf to instances.
+// Brokers already in use are reused so their connection is kept.
+func (a *EventService) loadRabbitConfig(instances map[string]*RabbitMQ, conf []*RabbitConfiguration) error {
+	current := a.rabbitInstances()
+
 	for _, cfg := range conf {
 		if cfg.AmqpUrl == "" {
 			return errors.New("Amqp url is mandatory")
@@ -35,15 +43,22 @@ func (a *EventService) loadRabbitConfig(conf []*RabbitConfiguration) error {
 		}
 
 		key := cfg.Exchange + ":" + cfg.AmqpUrl
-		mq, exists := a.mqInstances[key]
+		mq, exists := instances[key]
 		if !exists {
 			mq = &RabbitMQ{}
 
-			mq.mq.Url = cfg.AmqpUrl
-			mq.mq.Exchange = cfg.Exchange
-			mq.mq.ConnectionName = "ObjectStore event publisher " + cfg.Exchange
+			if old, exists := current[key]; exists {
+				mq.mq = old.mq
+				mq.connected = old.connected
+			} else {
+				mq.mq = &rabbitmq.RabbitMQ{
+					Url:            cfg.AmqpUrl,
+					Exchange:       cfg.Exchange,
+					ConnectionName: "ObjectStore event publisher " + cfg.Exchange,
+				}
+			}
 
-			a.mqInstances[key] = mq
+			instances[key] = mq
 		}
 
 		mq.config = append(mq.config, cfg)
@@ -52,23 +67,65 @@ func (a *EventService) loadRabbitConfig(conf []*RabbitConfiguration) error {
 	return nil
 }
 
+// rabbitInstances returns the brokers currently in use
+func (a *EventService) rabbitInstances() map[string]*RabbitMQ {
+	if instances := a.mqInstances.Load(); instances != nil {
+		return *instances
+	}
+	return nil
+}
+
 func (a *EventService) startRabbitMQ() error {
-	for _, mq := range a.mqInstances {
-		err := mq.mq.Connect()
-		if err != nil {
-			return err
+	return connectRabbitMQ(a.rabbitInstances())
+}
+
+// connectRabbitMQ connects to each broker not already connected
+func connectRabbitMQ(instances map[string]*RabbitMQ) error {
+	for _, mq := range instances {
+		if !mq.connected {
+			err := mq.mq.Connect()
+			if err != nil {
+				return err
+			}
+			mq.connected = true
 		}
 	}
 
 	return nil
 }
 
+// closeRabbitMQ closes the connection of each broker in instances which is not
+// also in keep
+func closeRabbitMQ(instances, keep map[string]*RabbitMQ) {
+	for key, mq := range instances {
+		if _, exists := keep[key]; !exists {
+			mq.close()
+		}
+	}
+}
+
+// close closes the broker's connection if connected
+func (mq *RabbitMQ) close() {
+	if !mq.connected {
+		return
+	}
+	mq.connected = false
+
+	if err := mq.mq.Close(); err != nil {
+		log.Printf("Failed to close %s: %v", mq.mq.Url, err)
+	}
+}
+
 func (a *EventService) publishRabbit(evt *event.Event) error {
 	var b []byte
 	var err error
 
+	// Stop the brokers being closed whilst publishing
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
 	found := false
-	for _, mq := range a.mqInstances {
+	for _, mq := range a.rabbitInstances() {
 		for _, cfg := range mq.config {
 			if event.TestEventName(cfg.Event, evt.Name) && cfg.Filter.Test(evt) {
 				// Lazy marshal only if we hit
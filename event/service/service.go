@@ -4,14 +4,24 @@ import (
 	"flag"
 	"github.com/peter-mount/go-kernel/v2"
 	"github.com/peter-mount/objectstore/event"
+	"github.com/peter-mount/objectstore/utils"
+	"sync"
+	"sync/atomic"
 )
 
 // Event service for publishers
 type EventService struct {
 	events chan *event.Event
 
-	mqInstances map[string]*RabbitMQ
+	// The brokers in use, replaced when the config file is reloaded
+	mqInstances atomic.Pointer[map[string]*RabbitMQ]
 	configFile  *string
+	// true once the brokers have been connected
+	started bool
+	// Stops watching the config file
+	stopWatch func()
+	// Held by publishers whilst using the brokers & by loadConfig when replacing them
+	mutex sync.RWMutex
 }
 
 func (a *EventService) Name() string {
@@ -37,6 +47,12 @@ func (a *EventService) Start() error {
 	if err != nil {
 		return err
 	}
+	a.started = true
+
+	// Reload the config on SIGHUP or when it changes
+	if *a.configFile != "" {
+		a.stopWatch = utils.WatchFile(*a.configFile, utils.WatchInterval, a.reloadConfig)
+	}
 
 	// Create the channel & the publisher thread
 	a.events = make(chan *event.Event, 20)
@@ -45,6 +61,12 @@ func (a *EventService) Start() error {
 	return nil
 }
 
+func (a *EventService) Stop() {
+	if a.stopWatch != nil {
+		a.stopWatch()
+	}
+}
+
 // Notify accepts Events for submission
 func (a *EventService) Notify(evt *event.Event) {
 	a.events <- evt
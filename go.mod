@@ -4,6 +4,7 @@ go 1.20
 
 require (
 	github.com/etcd-io/bbolt v1.3.3
+	github.com/gorilla/mux v1.8.1
 	github.com/peter-mount/go-build v0.0.0-20240201082537-00db099a6fa9
 	github.com/peter-mount/go-glob v0.0.0-20170128012129-256dc444b735
 	github.com/peter-mount/go-kernel/v2 v2.0.3-0.20231109105549-8a9638d5ef94
@@ -16,7 +17,6 @@ require (
 	github.com/akutz/sortfold v0.2.1 // indirect
 	github.com/felixge/httpsnoop v1.0.3 // indirect
 	github.com/gorilla/handlers v1.5.2 // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/peter-mount/go.uuid v1.2.1-0.20180103174451-36e9d2ebbde5 // indirect
 	github.com/rabbitmq/amqp091-go v1.9.0 // indirect
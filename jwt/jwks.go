@@ -0,0 +1,152 @@
+package jwt
+
+import (
+  "crypto"
+  "crypto/ecdsa"
+  "crypto/elliptic"
+  "crypto/rsa"
+  "encoding/base64"
+  "encoding/json"
+  "fmt"
+  "math/big"
+)
+
+// A JSON Web Key Set, the public keys tokens are signed with
+// https://tools.ietf.org/html/rfc7517
+type KeySet struct {
+  keys    map[string]crypto.PublicKey
+}
+
+// A JSON Web Key. Only the fields of RSA & EC public keys are used
+type jsonWebKey struct {
+  Kid     string    `json:"kid"`
+  Kty     string    `json:"kty"`
+  Use     string    `json:"use"`
+  // RSA
+  N       string    `json:"n"`
+  E       string    `json:"e"`
+  // EC
+  Crv     string    `json:"crv"`
+  X       string    `json:"x"`
+  Y       string    `json:"y"`
+}
+
+// ParseKeySet parses a JSON Web Key Set.
+// Keys which are not used for signatures or of an unsupported type are ignored.
+func ParseKeySet( b []byte ) (*KeySet, error) {
+  var set struct {
+    Keys  []jsonWebKey  `json:"keys"`
+  }
+  if err := json.Unmarshal( b, &set ); err != nil {
+    return nil, err
+  }
+
+  ks := &KeySet{keys: make( map[string]crypto.PublicKey )}
+  for _, k := range set.Keys {
+    if k.Use != "" && k.Use != "sig" {
+      continue
+    }
+
+    var key crypto.PublicKey
+    var err error
+    switch k.Kty {
+      case "RSA":
+        key, err = k.rsaKey()
+      case "EC":
+        key, err = k.ecKey()
+      default:
+        continue
+    }
+    if err != nil {
+      return nil, fmt.Errorf( "Invalid key %q: %v", k.Kid, err )
+    }
+
+    ks.keys[k.Kid] = key
+  }
+
+  return ks, nil
+}
+
+func (k *jsonWebKey) rsaKey() (crypto.PublicKey, error) {
+  n, err := decodeInt( k.N )
+  if err != nil {
+    return nil, err
+  }
+
+  e, err := decodeInt( k.E )
+  if err != nil {
+    return nil, err
+  }
+  if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31 {
+    return nil, fmt.Errorf( "invalid exponent" )
+  }
+
+  return &rsa.PublicKey{N: n, E: int( e.Int64() )}, nil
+}
+
+func (k *jsonWebKey) ecKey() (crypto.PublicKey, error) {
+  var curve elliptic.Curve
+  switch k.Crv {
+    case "P-256":
+      curve = elliptic.P256()
+    case "P-384":
+      curve = elliptic.P384()
+    case "P-521":
+      curve = elliptic.P521()
+    default:
+      return nil, fmt.Errorf( "unsupported curve %q", k.Crv )
+  }
+
+  x, err := decodeInt( k.X )
+  if err != nil {
+    return nil, err
+  }
+
+  y, err := decodeInt( k.Y )
+  if err != nil {
+    return nil, err
+  }
+
+  if !curve.IsOnCurve( x, y ) {
+    return nil, fmt.Errorf( "point not on curve" )
+  }
+
+  return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// decodeInt decodes a base64url encoded big endian integer
+func decodeInt( s string ) (*big.Int, error) {
+  b, err := base64.RawURLEncoding.DecodeString( s )
+  if err != nil {
+    return nil, err
+  }
+  if len( b ) == 0 {
+    return nil, fmt.Errorf( "missing value" )
+  }
+  return new( big.Int ).SetBytes( b ), nil
+}
+
+// Key returns the key with an id or nil if not present.
+// If kid is "" and there is only one key then that key is returned.
+func (ks *KeySet) Key( kid string ) crypto.PublicKey {
+  if ks == nil {
+    return nil
+  }
+  if key, exists := ks.keys[kid]; exists {
+    return key
+  }
+  if kid == "" && len( ks.keys ) == 1 {
+    for _, key := range ks.keys {
+      return key
+    }
+  }
+  return nil
+}
+
+// Len returns the number of keys in the set
+func (ks *KeySet) Len() int {
+  if ks == nil {
+    return 0
+  }
+  return len( ks.keys )
+}
@@ -0,0 +1,191 @@
+package jwt
+
+import (
+  "crypto"
+  "crypto/ecdsa"
+  "crypto/rsa"
+  _ "crypto/sha256"
+  _ "crypto/sha512"
+  "encoding/base64"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "math/big"
+  "strings"
+  "time"
+)
+
+var (
+  ErrMalformed  = errors.New( "Malformed token" )
+  ErrSignature  = errors.New( "Invalid token signature" )
+  ErrExpired    = errors.New( "Token has expired" )
+)
+
+// A JSON Web Token. Only signed tokens in the compact serialization are supported.
+// https://tools.ietf.org/html/rfc7519
+type Token struct {
+  Header        Header
+  Claims        Claims
+  // The header & payload, which is what was signed
+  signingInput  string
+  signature     []byte
+}
+
+// The JOSE header of a token
+type Header struct {
+  // The signing algorithm, e.g. "RS256"
+  Alg     string    `json:"alg"`
+  // The id of the key used to sign the token
+  Kid     string    `json:"kid"`
+  Typ     string    `json:"typ"`
+}
+
+// The claims of a token
+type Claims struct {
+  Issuer    string      `json:"iss"`
+  Subject   string      `json:"sub"`
+  Audience  Audience    `json:"aud"`
+  // Expiry time, seconds since the epoch
+  Expires   int64       `json:"exp"`
+  // Not valid before, seconds since the epoch
+  NotBefore int64       `json:"nbf"`
+  // Issued at, seconds since the epoch
+  IssuedAt  int64       `json:"iat"`
+  // Every claim in the token including those above
+  All       map[string]interface{}  `json:"-"`
+}
+
+// The aud claim, either a single string or an array
+type Audience []string
+
+func (a *Audience) UnmarshalJSON( b []byte ) error {
+  var s string
+  if err := json.Unmarshal( b, &s ); err == nil {
+    *a = Audience{s}
+    return nil
+  }
+
+  var v []string
+  if err := json.Unmarshal( b, &v ); err != nil {
+    return err
+  }
+  *a = v
+  return nil
+}
+
+// Contains returns true if an audience is present
+func (a Audience) Contains( aud string ) bool {
+  for _, v := range a {
+    if v == aud {
+      return true
+    }
+  }
+  return false
+}
+
+// Parse decodes a token without verifying it
+func Parse( token string ) (*Token, error) {
+  parts := strings.Split( token, "." )
+  if len( parts ) != 3 {
+    return nil, ErrMalformed
+  }
+
+  t := &Token{signingInput: parts[0] + "." + parts[1]}
+
+  if err := decodeSegment( parts[0], &t.Header ); err != nil {
+    return nil, ErrMalformed
+  }
+  if err := decodeSegment( parts[1], &t.Claims ); err != nil {
+    return nil, ErrMalformed
+  }
+  if err := decodeSegment( parts[1], &t.Claims.All ); err != nil {
+    return nil, ErrMalformed
+  }
+
+  sig, err := base64.RawURLEncoding.DecodeString( parts[2] )
+  if err != nil {
+    return nil, ErrMalformed
+  }
+  t.signature = sig
+
+  return t, nil
+}
+
+func decodeSegment( s string, v interface{} ) error {
+  b, err := base64.RawURLEncoding.DecodeString( s )
+  if err != nil {
+    return err
+  }
+  return json.Unmarshal( b, v )
+}
+
+// Verify checks the token was signed by key.
+// The key must be of the type required by the token's algorithm.
+func (t *Token) Verify( key crypto.PublicKey ) error {
+  // e.g. RS256 or ES256. "none" is never accepted
+  alg := t.Header.Alg
+  if len( alg ) != 5 {
+    return fmt.Errorf( "Unsupported algorithm %q", alg )
+  }
+
+  var hash crypto.Hash
+  switch alg[2:] {
+    case "256":
+      hash = crypto.SHA256
+    case "384":
+      hash = crypto.SHA384
+    case "512":
+      hash = crypto.SHA512
+  }
+  if hash == 0 {
+    return fmt.Errorf( "Unsupported algorithm %q", alg )
+  }
+
+  h := hash.New()
+  h.Write( []byte( t.signingInput ) )
+  digest := h.Sum( nil )
+
+  switch alg[:2] {
+    case "RS":
+      k, ok := key.(*rsa.PublicKey)
+      if !ok || rsa.VerifyPKCS1v15( k, hash, digest, t.signature ) != nil {
+        return ErrSignature
+      }
+      return nil
+
+    case "ES":
+      k, ok := key.(*ecdsa.PublicKey)
+      if !ok {
+        return ErrSignature
+      }
+      // The signature is r & s, each the size of the curve
+      size := (k.Curve.Params().BitSize + 7) / 8
+      if len( t.signature ) != 2 * size {
+        return ErrSignature
+      }
+      r := new( big.Int ).SetBytes( t.signature[:size] )
+      s := new( big.Int ).SetBytes( t.signature[size:] )
+      if !ecdsa.Verify( k, digest, r, s ) {
+        return ErrSignature
+      }
+      return nil
+
+    default:
+      return fmt.Errorf( "Unsupported algorithm %q", alg )
+  }
+}
+
+// Valid checks the token is valid at a time.
+// leeway is the allowed difference between our clock and the issuer's.
+func (c *Claims) Valid( now time.Time, leeway time.Duration ) error {
+  if c.Expires == 0 {
+    return fmt.Errorf( "Missing exp claim" )
+  }
+  if now.Add( -leeway ).After( time.Unix( c.Expires, 0 ) ) {
+    return ErrExpired
+  }
+  if c.NotBefore != 0 && now.Add( leeway ).Before( time.Unix( c.NotBefore, 0 ) ) {
+    return fmt.Errorf( "Token is not valid yet" )
+  }
+  return nil
+}
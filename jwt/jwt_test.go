@@ -0,0 +1,246 @@
+package jwt
+
+import (
+  "crypto"
+  "crypto/ecdsa"
+  "crypto/elliptic"
+  "crypto/rand"
+  "crypto/rsa"
+  "crypto/sha256"
+  "encoding/base64"
+  "encoding/json"
+  "io/ioutil"
+  "math/big"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// testKeys generates an RSA & an EC key pair and writes their public keys to
+// a JWKS file
+func testKeys( t *testing.T ) (*rsa.PrivateKey, *ecdsa.PrivateKey, *KeySet) {
+  rsaKey, err := rsa.GenerateKey( rand.Reader, 2048 )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  ecKey, err := ecdsa.GenerateKey( elliptic.P256(), rand.Reader )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  jwks := map[string]interface{}{
+    "keys": []map[string]string{
+      {
+        "kid": "rsa1",
+        "kty": "RSA",
+        "use": "sig",
+        "n":   b64.EncodeToString( rsaKey.N.Bytes() ),
+        "e":   b64.EncodeToString( big.NewInt( int64( rsaKey.E ) ).Bytes() ),
+      },
+      {
+        "kid": "ec1",
+        "kty": "EC",
+        "crv": "P-256",
+        "x":   b64.EncodeToString( ecKey.X.FillBytes( make( []byte, 32 ) ) ),
+        "y":   b64.EncodeToString( ecKey.Y.FillBytes( make( []byte, 32 ) ) ),
+      },
+      {
+        // Encryption keys are ignored
+        "kid": "enc1",
+        "kty": "RSA",
+        "use": "enc",
+        "n":   "AQAB",
+        "e":   "AQAB",
+      },
+    },
+  }
+
+  dir, err := ioutil.TempDir( "", "jwks" )
+  if err != nil {
+    t.Fatal( err )
+  }
+  t.Cleanup( func() {
+    os.RemoveAll( dir )
+  } )
+
+  b, err := json.Marshal( jwks )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  filename := filepath.Join( dir, "jwks.json" )
+  if err := ioutil.WriteFile( filename, b, 0644 ); err != nil {
+    t.Fatal( err )
+  }
+
+  b, err = ioutil.ReadFile( filename )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  ks, err := ParseKeySet( b )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  return rsaKey, ecKey, ks
+}
+
+// sign returns a signed token
+func sign( t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{} ) string {
+  h, _ := json.Marshal( map[string]string{"alg": alg, "kid": kid, "typ": "JWT"} )
+  c, _ := json.Marshal( claims )
+  input := b64.EncodeToString( h ) + "." + b64.EncodeToString( c )
+
+  digest := sha256.Sum256( []byte( input ) )
+
+  var sig []byte
+  switch k := key.(type) {
+    case *rsa.PrivateKey:
+      s, err := rsa.SignPKCS1v15( rand.Reader, k, crypto.SHA256, digest[:] )
+      if err != nil {
+        t.Fatal( err )
+      }
+      sig = s
+    case *ecdsa.PrivateKey:
+      r, s, err := ecdsa.Sign( rand.Reader, k, digest[:] )
+      if err != nil {
+        t.Fatal( err )
+      }
+      sig = append( r.FillBytes( make( []byte, 32 ) ), s.FillBytes( make( []byte, 32 ) )... )
+  }
+
+  return input + "." + b64.EncodeToString( sig )
+}
+
+func testClaims() map[string]interface{} {
+  return map[string]interface{}{
+    "iss": "https://issuer.example.com",
+    "sub": "repo:example/project:ref:refs/heads/main",
+    "aud": "sts.amazonaws.com",
+    "exp": time.Now().Add( time.Hour ).Unix(),
+    "iat": time.Now().Unix(),
+  }
+}
+
+func TestKeySet( t *testing.T ) {
+  _, _, ks := testKeys( t )
+
+  if ks.Len() != 2 {
+    t.Errorf( "Expected 2 keys got %d", ks.Len() )
+  }
+  if _, ok := ks.Key( "rsa1" ).(*rsa.PublicKey); !ok {
+    t.Errorf( "Expected rsa key" )
+  }
+  if _, ok := ks.Key( "ec1" ).(*ecdsa.PublicKey); !ok {
+    t.Errorf( "Expected ec key" )
+  }
+  if ks.Key( "enc1" ) != nil || ks.Key( "" ) != nil {
+    t.Errorf( "Expected no key" )
+  }
+}
+
+func TestToken_Verify( t *testing.T ) {
+  rsaKey, ecKey, ks := testKeys( t )
+
+  for _, test := range []struct {
+    name  string
+    alg   string
+    kid   string
+    key   crypto.Signer
+  }{
+    {"RS256", "RS256", "rsa1", rsaKey},
+    {"ES256", "ES256", "ec1", ecKey},
+  } {
+    token, err := Parse( sign( t, test.alg, test.kid, test.key, testClaims() ) )
+    if err != nil {
+      t.Fatalf( "%s: %v", test.name, err )
+    }
+
+    if err := token.Verify( ks.Key( token.Header.Kid ) ); err != nil {
+      t.Errorf( "%s: %v", test.name, err )
+    }
+
+    if err := token.Claims.Valid( time.Now(), time.Minute ); err != nil {
+      t.Errorf( "%s: %v", test.name, err )
+    }
+
+    if token.Claims.Subject != "repo:example/project:ref:refs/heads/main" || !token.Claims.Audience.Contains( "sts.amazonaws.com" ) {
+      t.Errorf( "%s: Invalid claims %v", test.name, token.Claims )
+    }
+  }
+}
+
+func TestToken_Verify_invalid( t *testing.T ) {
+  rsaKey, ecKey, ks := testKeys( t )
+
+  otherKey, err := rsa.GenerateKey( rand.Reader, 2048 )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  // A valid token with the claims replaced
+  claims := testClaims()
+  claims["sub"] = "someone-else"
+  c, _ := json.Marshal( claims )
+  parts := strings.Split( sign( t, "RS256", "rsa1", rsaKey, testClaims() ), "." )
+  tampered := parts[0] + "." + b64.EncodeToString( c ) + "." + parts[2]
+
+  for name, src := range map[string]string{
+    "wrong key":      sign( t, "RS256", "rsa1", otherKey, testClaims() ),
+    "key type":       sign( t, "ES256", "rsa1", ecKey, testClaims() ),
+    "tampered":       tampered,
+    "none":           b64.EncodeToString( []byte( `{"alg":"none"}` ) ) + "." + b64.EncodeToString( c ) + ".",
+    "hmac":           b64.EncodeToString( []byte( `{"alg":"HS256","kid":"rsa1"}` ) ) + "." + b64.EncodeToString( c ) + ".c2ln",
+  } {
+    token, err := Parse( src )
+    if err != nil {
+      t.Errorf( "%s: %v", name, err )
+      continue
+    }
+    if token.Verify( ks.Key( token.Header.Kid ) ) == nil {
+      t.Errorf( "%s: Expected verification to fail", name )
+    }
+  }
+
+  for _, src := range []string{ "", "a.b", "a.b.c.d", "!!.e30.", } {
+    if _, err := Parse( src ); err == nil {
+      t.Errorf( "Expected %q to be malformed", src )
+    }
+  }
+}
+
+func TestClaims_Valid( t *testing.T ) {
+  now := time.Now()
+
+  for name, test := range map[string]struct {
+    claims  Claims
+    valid   bool
+  }{
+    "valid":          {Claims{Expires: now.Add( time.Minute ).Unix()}, true},
+    "no exp":         {Claims{}, false},
+    "expired":        {Claims{Expires: now.Add( -time.Hour ).Unix()}, false},
+    "within leeway":  {Claims{Expires: now.Add( -30 * time.Second ).Unix()}, true},
+    "not yet valid":  {Claims{Expires: now.Add( time.Hour ).Unix(), NotBefore: now.Add( time.Hour ).Unix()}, false},
+  } {
+    err := test.claims.Valid( now, time.Minute )
+    if test.valid != (err == nil) {
+      t.Errorf( "%s: valid %v got %v", name, test.valid, err )
+    }
+  }
+}
+
+func TestAudience_Unmarshal( t *testing.T ) {
+  var c Claims
+  if err := json.Unmarshal( []byte( `{"aud":["a","b"]}` ), &c ); err != nil {
+    t.Fatal( err )
+  }
+  if !c.Audience.Contains( "b" ) || c.Audience.Contains( "c" ) {
+    t.Errorf( "Invalid audience %v", c.Audience )
+  }
+}
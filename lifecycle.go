@@ -0,0 +1,476 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// Maximum number of rules in a lifecycle configuration
+	maxLifecycleRules = 1000
+	// Maximum length of a rule's ID
+	maxLifecycleRuleIDLength = 255
+	// How often objects are checked against the lifecycle rules
+	lifecycleInterval = time.Hour
+	// Maximum number of expired objects deleted in one transaction
+	lifecycleBatchSize = 100
+)
+
+// LifecycleConfiguration is the request/response body used by the ?lifecycle sub-resource.
+// Only the Expiration action is supported as objects are not versioned and
+// there are no other storage classes.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration" bson:"-"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty" bson:"-"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+type LifecycleRule struct {
+	ID     string `xml:"ID,omitempty"`
+	Status string `xml:"Status"`
+	// The objects the rule applies to
+	Filter *LifecycleFilter `xml:"Filter,omitempty"`
+	// Deprecated, the prefix of the objects the rule applies to if there's no Filter
+	Prefix     *string              `xml:"Prefix,omitempty"`
+	Expiration *LifecycleExpiration `xml:"Expiration,omitempty"`
+	// Actions we don't support, e.g. Transition
+	Unsupported []lifecycleElement `xml:",any" bson:"-"`
+}
+
+// LifecycleFilter selects objects by prefix, a tag or both with And.
+// An empty filter selects every object in the bucket.
+type LifecycleFilter struct {
+	Prefix string              `xml:"Prefix,omitempty"`
+	Tag    *Tag                `xml:"Tag,omitempty"`
+	And    *LifecycleFilterAnd `xml:"And,omitempty"`
+}
+
+type LifecycleFilterAnd struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag"`
+}
+
+// LifecycleExpiration is when objects are deleted, either a number of days
+// after they were last modified or on a date
+type LifecycleExpiration struct {
+	Days int        `xml:"Days,omitempty"`
+	Date *time.Time `xml:"Date,omitempty"`
+}
+
+type lifecycleElement struct {
+	XMLName xml.Name
+}
+
+// validate checks a lifecycle configuration is valid
+func (c *LifecycleConfiguration) validate() error {
+	if len(c.Rules) == 0 {
+		return awserror.MalformedXML()
+	}
+	if len(c.Rules) > maxLifecycleRules {
+		return awserror.InvalidRequest("The number of lifecycle rules should not exceed allowed limit of %d rules.", maxLifecycleRules)
+	}
+
+	ids := make(map[string]bool)
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+
+		if rule.ID != "" {
+			if len(rule.ID) > maxLifecycleRuleIDLength {
+				return awserror.InvalidArgument("ID length should not exceed allowed limit of %d", maxLifecycleRuleIDLength)
+			}
+			if ids[rule.ID] {
+				return awserror.InvalidArgument("Rule ID must be unique. Found same ID for more than one rule")
+			}
+			ids[rule.ID] = true
+		}
+
+		err := rule.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rule *LifecycleRule) validate() error {
+	if rule.Status != "Enabled" && rule.Status != "Disabled" {
+		return awserror.MalformedXML()
+	}
+
+	// A rule has either a filter or the older prefix
+	if (rule.Filter == nil) == (rule.Prefix == nil) {
+		return awserror.MalformedXML()
+	}
+
+	if rule.Filter != nil {
+		err := rule.Filter.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(rule.Unsupported) > 0 {
+		return awserror.NotImplemented("The %s lifecycle action is not supported", rule.Unsupported[0].XMLName.Local)
+	}
+
+	e := rule.Expiration
+	if e == nil {
+		return awserror.InvalidRequest("At least one action needs to be specified in a rule")
+	}
+
+	if (e.Days == 0) == (e.Date == nil) {
+		return awserror.MalformedXML()
+	}
+
+	if e.Days < 0 {
+		return awserror.InvalidArgument("'Days' for Expiration action must be a positive integer")
+	}
+
+	if e.Date != nil && !e.Date.Equal(e.Date.Truncate(24*time.Hour)) {
+		return awserror.InvalidArgument("'Date' must be at midnight GMT")
+	}
+
+	return nil
+}
+
+func (f *LifecycleFilter) validate() error {
+	n := 0
+	if f.Prefix != "" {
+		n++
+	}
+	if f.Tag != nil {
+		n++
+	}
+	if f.And != nil {
+		n++
+	}
+	if n > 1 {
+		return awserror.MalformedXML()
+	}
+
+	if f.Tag != nil {
+		return validateTag(f.Tag.Key, f.Tag.Value)
+	}
+
+	if f.And != nil {
+		keys := make(map[string]bool)
+		for _, t := range f.And.Tags {
+			err := validateTag(t.Key, t.Value)
+			if err != nil {
+				return err
+			}
+			if keys[t.Key] {
+				return awserror.InvalidRequest("Duplicate Tag Keys are not allowed.")
+			}
+			keys[t.Key] = true
+		}
+	}
+
+	return nil
+}
+
+// matches returns true if the rule is enabled and applies to an object.
+// Tags must match exactly.
+func (rule *LifecycleRule) matches(obj *Object) bool {
+	if rule.Status != "Enabled" {
+		return false
+	}
+
+	prefix := ""
+	var tags []Tag
+
+	switch f := rule.Filter; {
+	case f == nil:
+		prefix = *rule.Prefix
+	case f.Tag != nil:
+		tags = []Tag{*f.Tag}
+	case f.And != nil:
+		prefix, tags = f.And.Prefix, f.And.Tags
+	default:
+		prefix = f.Prefix
+	}
+
+	if !strings.HasPrefix(obj.Name, prefix) {
+		return false
+	}
+
+	for _, t := range tags {
+		if v, exists := obj.Tags[t.Key]; !exists || v != t.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// expires returns when an object last modified at a time expires.
+// Like S3 expiry after a number of days is rounded up to the next midnight UTC.
+func (e *LifecycleExpiration) expires(lastModified time.Time) time.Time {
+	if e.Date != nil {
+		return *e.Date
+	}
+
+	t := lastModified.UTC().AddDate(0, 0, e.Days)
+	if d := t.Truncate(24 * time.Hour); !d.Equal(t) {
+		t = d.Add(24 * time.Hour)
+	}
+	return t
+}
+
+// expired returns true if an enabled rule says an object should be deleted
+func (c *LifecycleConfiguration) expired(obj *Object, now time.Time) bool {
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.matches(obj) && !now.Before(rule.Expiration.expires(obj.LastModified)) {
+			return true
+		}
+	}
+	return false
+}
+
+// startLifecycle deletes expired objects now and then every lifecycleInterval.
+// The returned function stops it.
+func (s *ObjectStore) startLifecycle() func() {
+	stop := make(chan struct{})
+	ticker := time.NewTicker(lifecycleInterval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			s.expireObjects(time.Now())
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// expireObjects deletes the expired objects in every bucket with a lifecycle
+// configuration
+func (s *ObjectStore) expireObjects(now time.Time) {
+	var buckets []string
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name string, b *bolt.Bucket) error {
+			if isInternalBucket(name) {
+				return nil
+			}
+
+			meta := &BucketMeta{}
+			if err := meta.get(b); err != nil {
+				log.Printf("Failed to read the metadata of %s: %v", name, err)
+			} else if meta.Lifecycle != nil {
+				buckets = append(buckets, name)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to list buckets for lifecycle rules: %v", err)
+		return
+	}
+
+	for _, bucketName := range buckets {
+		if err := s.expireBucketObjects(bucketName, now); err != nil {
+			log.Printf("Failed to delete expired objects in %s: %v", bucketName, err)
+		}
+	}
+}
+
+// expireBucketObjects deletes the expired objects in a bucket.
+// The bucket is scanned in a read transaction and the objects found are deleted
+// lifecycleBatchSize at a time so writers are not blocked for the whole scan.
+func (s *ObjectStore) expireBucketObjects(bucketName string, now time.Time) error {
+	var names []string
+
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		b, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		// The configuration may have been removed since the buckets were listed
+		if meta.Lifecycle == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek(meta_prefix); k != "" && strings.HasPrefix(k, meta_prefix); k, v = c.Next() {
+			obj := &Object{}
+			if err := obj.getBytes(v); err != nil {
+				return err
+			}
+
+			if s.lifecycleExpired(meta, obj, now) {
+				names = append(names, obj.Name)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for len(names) > 0 {
+		n := len(names)
+		if n > lifecycleBatchSize {
+			n = lifecycleBatchSize
+		}
+
+		err = s.expireBatch(bucketName, names[:n], now)
+		if err != nil {
+			return err
+		}
+
+		names = names[n:]
+	}
+
+	return nil
+}
+
+// lifecycleExpired returns true if an object should be deleted by the bucket's lifecycle rules.
+func (s *ObjectStore) lifecycleExpired(meta *BucketMeta, obj *Object, now time.Time) bool {
+	return meta.Lifecycle != nil && meta.Lifecycle.expired(obj, now)
+}
+
+// expireBatch deletes some of the objects found by expireBucketObjects.
+// Each object is checked again as it could have been replaced or the rules
+// changed since the bucket was scanned.
+func (s *ObjectStore) expireBatch(bucketName string, names []string, now time.Time) error {
+	var expired []*Object
+
+	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		b, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			obj := &Object{}
+			if obj.get(b, name) != nil || !s.lifecycleExpired(meta, obj, now) {
+				continue
+			}
+
+			err = s.updateUsage(tx, b, bucketName, meta, -int64(obj.Length), -1)
+			if err != nil {
+				return err
+			}
+
+			obj.delete(b)
+			expired = append(expired, obj)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range expired {
+		s.sendObjectEvent("LifecycleExpiration:Delete", bucketName, obj)
+	}
+
+	return nil
+}
+
+// getBucketLifecycle returns the lifecycle configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLifecycleConfiguration.html
+func (s *ObjectStore) getBucketLifecycle(r *rest.Rest) error {
+	var c *LifecycleConfiguration
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, r.Var("BucketName"))
+		if err != nil {
+			return err
+		}
+
+		c = meta.Lifecycle
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if c == nil {
+		return awserror.NoSuchLifecycleConfiguration()
+	}
+
+	c.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	r.Status(200).
+		XML().
+		Value(c)
+
+	return nil
+}
+
+// putBucketLifecycle validates and stores the lifecycle configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketLifecycleConfiguration.html
+func (s *ObjectStore) putBucketLifecycle(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	reader, err := r.BodyReader()
+	if err != nil {
+		return err
+	}
+
+	body, err := s.getBody(r.Request().Header, reader)
+	if err != nil {
+		return err
+	}
+
+	c := &LifecycleConfiguration{}
+	err = xml.Unmarshal(body, c)
+	if err != nil {
+		return awserror.MalformedXML()
+	}
+
+	err = c.validate()
+	if err != nil {
+		return err
+	}
+
+	err = s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Lifecycle = c
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200)
+
+	return nil
+}
+
+// deleteBucketLifecycle removes the lifecycle configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketLifecycle.html
+func (s *ObjectStore) deleteBucketLifecycle(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	err := s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Lifecycle = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(204)
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/objectstore/awserror"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testLifecycleRule returns a rule with a filter & 30 day expiry
+func testLifecycleRule(filter string) string {
+	return `<Rule><Status>Enabled</Status>` + filter + `<Expiration><Days>30</Days></Expiration></Rule>`
+}
+
+func TestLifecycleConfiguration_validate(t *testing.T) {
+	tooMany := strings.Repeat(testLifecycleRule(`<Filter/>`), maxLifecycleRules+1)
+
+	for _, test := range []struct {
+		name  string
+		rules string
+		code  string
+	}{
+		{name: "empty filter", rules: testLifecycleRule(`<Filter/>`)},
+		{name: "prefix filter", rules: testLifecycleRule(`<Filter><Prefix>logs/</Prefix></Filter>`)},
+		{name: "tag filter", rules: testLifecycleRule(`<Filter><Tag><Key>retention</Key><Value>short</Value></Tag></Filter>`)},
+		{name: "and filter", rules: testLifecycleRule(`<Filter><And><Prefix>logs/</Prefix><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>b</Key><Value>2</Value></Tag></And></Filter>`)},
+		{name: "deprecated prefix", rules: testLifecycleRule(`<Prefix>logs/</Prefix>`)},
+		{name: "date", rules: `<Rule><Status>Enabled</Status><Filter/><Expiration><Date>2020-01-01T00:00:00Z</Date></Expiration></Rule>`},
+		{name: "disabled", rules: `<Rule><Status>Disabled</Status><Filter/><Expiration><Days>1</Days></Expiration></Rule>`},
+		{name: "unique ids", rules: `<Rule><ID>a</ID><Status>Enabled</Status><Filter/><Expiration><Days>1</Days></Expiration></Rule><Rule><ID>b</ID><Status>Enabled</Status><Filter/><Expiration><Days>1</Days></Expiration></Rule>`},
+
+		{name: "no rules", code: "MalformedXML"},
+		{name: "too many rules", rules: tooMany, code: "InvalidRequest"},
+		{name: "duplicate ids", rules: `<Rule><ID>a</ID><Status>Enabled</Status><Filter/><Expiration><Days>1</Days></Expiration></Rule><Rule><ID>a</ID><Status>Enabled</Status><Filter/><Expiration><Days>1</Days></Expiration></Rule>`, code: "InvalidArgument"},
+		{name: "long id", rules: `<Rule><ID>` + strings.Repeat("a", maxLifecycleRuleIDLength+1) + `</ID><Status>Enabled</Status><Filter/><Expiration><Days>1</Days></Expiration></Rule>`, code: "InvalidArgument"},
+		{name: "bad status", rules: `<Rule><Status>enabled</Status><Filter/><Expiration><Days>1</Days></Expiration></Rule>`, code: "MalformedXML"},
+		{name: "no filter or prefix", rules: testLifecycleRule(``), code: "MalformedXML"},
+		{name: "filter and prefix", rules: testLifecycleRule(`<Filter/><Prefix>logs/</Prefix>`), code: "MalformedXML"},
+		{name: "prefix and tag", rules: testLifecycleRule(`<Filter><Prefix>logs/</Prefix><Tag><Key>a</Key><Value>1</Value></Tag></Filter>`), code: "MalformedXML"},
+		{name: "aws tag", rules: testLifecycleRule(`<Filter><Tag><Key>aws:a</Key><Value>1</Value></Tag></Filter>`), code: "InvalidTag"},
+		{name: "duplicate and tags", rules: testLifecycleRule(`<Filter><And><Tag><Key>a</Key><Value>1</Value></Tag><Tag><Key>a</Key><Value>2</Value></Tag></And></Filter>`), code: "InvalidRequest"},
+		{name: "no action", rules: `<Rule><Status>Enabled</Status><Filter/></Rule>`, code: "InvalidRequest"},
+		{name: "transition", rules: `<Rule><Status>Enabled</Status><Filter/><Transition><Days>1</Days><StorageClass>GLACIER</StorageClass></Transition></Rule>`, code: "NotImplemented"},
+		{name: "days and date", rules: `<Rule><Status>Enabled</Status><Filter/><Expiration><Days>1</Days><Date>2020-01-01T00:00:00Z</Date></Expiration></Rule>`, code: "MalformedXML"},
+		{name: "no days or date", rules: `<Rule><Status>Enabled</Status><Filter/><Expiration/></Rule>`, code: "MalformedXML"},
+		{name: "negative days", rules: `<Rule><Status>Enabled</Status><Filter/><Expiration><Days>-1</Days></Expiration></Rule>`, code: "InvalidArgument"},
+		{name: "date not midnight", rules: `<Rule><Status>Enabled</Status><Filter/><Expiration><Date>2020-01-01T12:00:00Z</Date></Expiration></Rule>`, code: "InvalidArgument"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := &LifecycleConfiguration{}
+			err := xml.Unmarshal([]byte(`<LifecycleConfiguration>`+test.rules+`</LifecycleConfiguration>`), c)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = c.validate()
+			switch {
+			case test.code == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case test.code != "" && err == nil:
+				t.Errorf("expected %s", test.code)
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %s expected %s", awserror.ToError(err).Code, test.code)
+			}
+		})
+	}
+}
+
+func TestLifecycleRule_matches(t *testing.T) {
+	prefix := "logs/"
+
+	obj := &Object{
+		Name: "logs/2020/01.log",
+		Tags: map[string]string{"retention": "short", "team": "ops"},
+	}
+
+	for _, test := range []struct {
+		name string
+		rule LifecycleRule
+		want bool
+	}{
+		{name: "empty filter", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{}}, want: true},
+		{name: "disabled", rule: LifecycleRule{Status: "Disabled", Filter: &LifecycleFilter{}}},
+		{name: "prefix", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{Prefix: "logs/"}}, want: true},
+		{name: "other prefix", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{Prefix: "data/"}}},
+		{name: "deprecated prefix", rule: LifecycleRule{Status: "Enabled", Prefix: &prefix}, want: true},
+		{name: "tag", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{Tag: &Tag{Key: "retention", Value: "short"}}}, want: true},
+		{name: "tag value differs", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{Tag: &Tag{Key: "retention", Value: "Short"}}}},
+		{name: "tag missing", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{Tag: &Tag{Key: "owner", Value: ""}}}},
+		{name: "and", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{And: &LifecycleFilterAnd{
+			Prefix: "logs/",
+			Tags:   []Tag{{Key: "retention", Value: "short"}, {Key: "team", Value: "ops"}},
+		}}}, want: true},
+		{name: "and other prefix", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{And: &LifecycleFilterAnd{
+			Prefix: "data/",
+			Tags:   []Tag{{Key: "retention", Value: "short"}},
+		}}}},
+		{name: "and one tag differs", rule: LifecycleRule{Status: "Enabled", Filter: &LifecycleFilter{And: &LifecycleFilterAnd{
+			Tags: []Tag{{Key: "retention", Value: "short"}, {Key: "team", Value: "dev"}},
+		}}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.rule.matches(obj); got != test.want {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLifecycleExpiration_expires(t *testing.T) {
+	date := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name         string
+		expiration   LifecycleExpiration
+		lastModified time.Time
+		want         time.Time
+	}{
+		{
+			name:         "rounded up to midnight",
+			expiration:   LifecycleExpiration{Days: 1},
+			lastModified: time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC),
+			want:         time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "already midnight",
+			expiration:   LifecycleExpiration{Days: 30},
+			lastModified: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:         time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "midnight UTC not local",
+			expiration:   LifecycleExpiration{Days: 1},
+			lastModified: time.Date(2020, 1, 1, 23, 30, 0, 0, time.FixedZone("", -2*60*60)),
+			want:         time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "date",
+			expiration:   LifecycleExpiration{Date: &date},
+			lastModified: time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC),
+			want:         date,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.expiration.expires(test.lastModified); !got.Equal(test.want) {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLifecycleConfiguration_expired(t *testing.T) {
+	c := &LifecycleConfiguration{Rules: []LifecycleRule{
+		{Status: "Enabled", Filter: &LifecycleFilter{Prefix: "logs/"}, Expiration: &LifecycleExpiration{Days: 1}},
+		{Status: "Enabled", Filter: &LifecycleFilter{Tag: &Tag{Key: "retention", Value: "long"}}, Expiration: &LifecycleExpiration{Days: 30}},
+	}}
+
+	lastModified := time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)
+	now := time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)
+
+	for _, test := range []struct {
+		name string
+		obj  *Object
+		now  time.Time
+		want bool
+	}{
+		{name: "expired", obj: &Object{Name: "logs/a"}, now: now, want: true},
+		{name: "not yet", obj: &Object{Name: "logs/a"}, now: now.Add(-time.Second)},
+		{name: "no rule", obj: &Object{Name: "data/a"}, now: now},
+		{name: "longer rule", obj: &Object{Name: "data/a", Tags: map[string]string{"retention": "long"}}, now: now},
+		// The shortest matching rule wins
+		{name: "both rules", obj: &Object{Name: "logs/a", Tags: map[string]string{"retention": "long"}}, now: now, want: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.obj.LastModified = lastModified
+			if got := c.expired(test.obj, test.now); got != test.want {
+				t.Errorf("got %v want %v", got, test.want)
+			}
+		})
+	}
+}
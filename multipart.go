@@ -7,9 +7,9 @@ import (
 	"fmt"
 	"github.com/peter-mount/go-kernel/v2/bolt"
 	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
 	"github.com/peter-mount/objectstore/awserror"
 	"gopkg.in/mgo.v2/bson"
-	"strings"
 	"time"
 )
 
@@ -25,6 +25,10 @@ type MultipartUpload struct {
 	Time time.Time
 	// Metadata
 	Meta map[string]string
+	// Tags to apply to the final object
+	Tags map[string]string
+	// ACL to apply to the final object
+	ACL *ACL
 }
 
 func (u *MultipartUpload) get(b *bolt.Bucket, uploadId string) error {
@@ -91,26 +95,39 @@ func (s *ObjectStore) initiateMultipart(r *rest.Rest) error {
 	hash := md5.Sum([]byte(fullName))
 	uploadId := hex.EncodeToString(hash[:])
 
+	tags, err := parseTaggingHeader(r.Request().Header)
+	if err != nil {
+		return err
+	}
+
 	upload := &MultipartUpload{
 		objectName,
 		uploadId,
 		make(map[string]string),
 		startTime,
 		make(map[string]string),
+		tags,
+		nil,
 	}
 
 	// Extract the headers for the meta-data
 	for hk, hv := range r.Request().Header {
-		if strings.HasPrefix(hk, "X-Amz-") || hk == "Content-Type" {
+		if isMetadataHeader(hk) {
 			upload.Meta[hk] = hv[0]
 		}
 	}
 
-	err := s.boltService.Update(func(tx *bolt.Tx) error {
-		b, err := s.getBucket(tx, bucketName)
+	err = s.boltService.Update(func(tx *bolt.Tx) error {
+		b, bucketMeta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		upload.ACL, err = aclFromHeaders(r.Request().Header, newOwner(auth.RequestCredential(r)), s.bucketOwner(bucketMeta))
 		if err != nil {
 			return err
 		}
+
 		return upload.put(b)
 	})
 	if err != nil {
@@ -146,7 +163,7 @@ func (s *ObjectStore) uploadPart(r *rest.Rest) error {
 	}
 
 	err = s.boltService.Update(func(tx *bolt.Tx) error {
-		b, err := s.getBucket(tx, bucketName)
+		b, bucketMeta, err := s.getBucketMeta(tx, bucketName)
 		if err != nil {
 			return err
 		}
@@ -157,6 +174,20 @@ func (s *ObjectStore) uploadPart(r *rest.Rest) error {
 			return err
 		}
 
+		// Check the quota now rather than only on completion. The parts already
+		// uploaded count towards it, less any existing object they would replace.
+		size := int64(len(body))
+		for n, k := range upload.Parts {
+			if n != partNumber {
+				size += int64(len(b.Get(k)))
+			}
+		}
+		oldSize, _ := objectUsage(b, upload.ObjectName)
+		err = s.checkUsage(tx, b, bucketName, bucketMeta, size-oldSize)
+		if err != nil {
+			return err
+		}
+
 		partKey := partmeta_prefix + uploadId + partmeta_suffix + partNumber
 		upload.Parts[partNumber] = partKey
 
@@ -207,7 +238,7 @@ func (s *ObjectStore) completeMultipart(r *rest.Rest) error {
 	}
 
 	return s.boltService.Update(func(tx *bolt.Tx) error {
-		b, err := s.getBucket(tx, bucketName)
+		b, bucketMeta, err := s.getBucketMeta(tx, bucketName)
 		if err != nil {
 			return err
 		}
@@ -218,6 +249,22 @@ func (s *ObjectStore) completeMultipart(r *rest.Rest) error {
 			return err
 		}
 
+		// Check the quota before storing, replacing any existing object
+		var size int64
+		for _, p := range req.Parts {
+			size += int64(len(b.Get(upload.Parts[p.PartNumber])))
+		}
+		oldSize, oldCount := objectUsage(b, upload.ObjectName)
+		err = s.updateUsage(tx, b, bucketName, bucketMeta, size-oldSize, 1-oldCount)
+		if err != nil {
+			return err
+		}
+
+		// Remove any existing object
+		if oldObj := (&Object{}); oldObj.get(b, upload.ObjectName) == nil {
+			oldObj.delete(b)
+		}
+
 		obj := &Object{
 			upload.ObjectName,
 			upload.Meta,
@@ -225,6 +272,8 @@ func (s *ObjectStore) completeMultipart(r *rest.Rest) error {
 			0,
 			"",
 			nil,
+			upload.Tags,
+			upload.ACL,
 		}
 
 		// Delete the upload on exit
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/peter-mount/go-kernel/v2/bolt"
 	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -24,28 +26,6 @@ func (s *ObjectStore) uploadObject(r *rest.Rest) error {
 	return s.createObject(r, "Put", bucketName, objectName, r.Request().Header, reader)
 }
 
-// CreateObjectBrowserUpload creates a new S3 object using a MultipartForm
-func (s *ObjectStore) CreateObjectBrowserUpload(r *rest.Rest) error {
-
-	err := r.Request().ParseMultipartForm(size_24K)
-	if err != nil {
-		return err
-	}
-
-	bucketName := r.Var("BucketName")
-	form := r.Request().MultipartForm
-	key := form.Value["key"][0]
-
-	fileHeader := form.File["file"][0]
-	infile, err := fileHeader.Open()
-	if err != nil {
-		return err
-	}
-	defer infile.Close()
-
-	return s.createObject(r, "POST", bucketName, key, form.Value, infile)
-}
-
 func (s *ObjectStore) getBody(headers map[string][]string, reader io.Reader) ([]byte, error) {
 	// Read the raw body
 	body, err := ioutil.ReadAll(reader)
@@ -65,28 +45,63 @@ func (s *ObjectStore) getBody(headers map[string][]string, reader io.Reader) ([]
 }
 
 func (s *ObjectStore) createObject(r *rest.Rest, method, bucketName, objectName string, headers map[string][]string, reader io.Reader) error {
+	obj, err := s.storeObject(r, method, bucketName, objectName, headers, reader)
+	if err != nil {
+		return err
+	}
+
+	r.Status(200).
+		Etag(obj.ETag)
+
+	return nil
+}
+
+// storeObject stores a new object replacing any existing one
+func (s *ObjectStore) storeObject(r *rest.Rest, method, bucketName, objectName string, headers map[string][]string, reader io.Reader) (*Object, error) {
 
 	body, err := s.getBody(headers, reader)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	tags, err := parseTaggingHeader(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	if h, ok := headers["X-Amz-Website-Redirect-Location"]; ok && len(h) > 0 && !validRedirectLocation(h[0]) {
+		return nil, awserror.InvalidArgument("The website redirect location must have a prefix of 'http://' or 'https://' or '/'.")
 	}
 
 	// Extract the headers for the meta-data
 	meta := make(map[string]string)
 	for hk, hv := range headers {
-		if strings.Contains(hk, "X-Amz-") || hk == "Content-Type" {
+		if isMetadataHeader(hk) {
 			meta[hk] = hv[0]
 		}
 	}
 
-	return s.boltService.Update(func(tx *bolt.Tx) error {
-		b, err := s.getBucket(tx, bucketName)
+	var obj *Object
+	err = s.boltService.Update(func(tx *bolt.Tx) error {
+		b, bucketMeta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		acl, err := aclFromHeaders(headers, newOwner(auth.RequestCredential(r)), s.bucketOwner(bucketMeta))
+		if err != nil {
+			return err
+		}
+
+		// Check the quota before storing, replacing any existing object
+		oldSize, oldCount := objectUsage(b, objectName)
+		err = s.updateUsage(tx, b, bucketName, bucketMeta, int64(len(body))-oldSize, 1-oldCount)
 		if err != nil {
 			return err
 		}
 
 		// Remove any existing object
-		obj := &Object{}
+		obj = &Object{}
 		if err = obj.get(b, objectName); err == nil {
 			obj.delete(b)
 		}
@@ -99,6 +114,8 @@ func (s *ObjectStore) createObject(r *rest.Rest, method, bucketName, objectName
 			0,
 			etag(body),
 			nil,
+			tags,
+			acl,
 		}
 
 		// Add the sole part
@@ -114,11 +131,13 @@ func (s *ObjectStore) createObject(r *rest.Rest, method, bucketName, objectName
 
 		s.sendObjectEvent("ObjectCreated:"+method, bucketName, obj)
 
-		r.Status(200).
-			Etag(obj.ETag)
-
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return obj, nil
 }
 
 func (t *Object) addHeaders(r *rest.Rest) {
@@ -129,13 +148,19 @@ func (t *Object) addHeaders(r *rest.Rest) {
 			r.AddHeader(mk, mv)
 		}
 	}
+
+	if len(t.Tags) > 0 {
+		r.AddHeader("x-amz-tagging-count", strconv.Itoa(len(t.Tags)))
+	}
 }
 
 // HeadObject retrieves only meta information of an object and not the whole.
 func (s *ObjectStore) HeadObject(r *rest.Rest) error {
-	bucketName := r.Var("BucketName")
-	objectName := r.Var("ObjectName")
+	return s.headObject(r, r.Var("BucketName"), r.Var("ObjectName"), 200)
+}
 
+// headObject sends an object's headers
+func (s *ObjectStore) headObject(r *rest.Rest, bucketName, objectName string, status int) error {
 	t := Object{}
 	err := s.boltService.View(func(tx *bolt.Tx) error {
 		b, err := s.getBucket(tx, bucketName)
@@ -150,7 +175,7 @@ func (s *ObjectStore) HeadObject(r *rest.Rest) error {
 		return err
 	}
 
-	r.Status(200).
+	r.Status(status).
 		CacheControl(-1).
 		AddHeader("Accept-Ranges", "bytes")
 
@@ -165,13 +190,12 @@ func (s *ObjectStore) HeadObject(r *rest.Rest) error {
 
 // GetObject retrievs a bucket object.
 func (s *ObjectStore) GetObject(r *rest.Rest) error {
-	bucketName := r.Var("BucketName")
-	objectName := r.Var("ObjectName")
-
-	if *s.website && (objectName == "" || objectName[len(objectName)-1] == '/') {
-		objectName = objectName + "index.html"
-	}
+	return s.getObject(r, r.Var("BucketName"), r.Var("ObjectName"), 200)
+}
 
+// getObject sends an object. status is the response status when the entire
+// object is returned
+func (s *ObjectStore) getObject(r *rest.Rest, bucketName, objectName string, status int) error {
 	return s.boltService.View(func(tx *bolt.Tx) error {
 		b, err := s.getBucket(tx, bucketName)
 		if err != nil {
@@ -211,8 +235,8 @@ func (s *ObjectStore) GetObject(r *rest.Rest) error {
 				AddHeader("Content-Length", fmt.Sprintf("%v", en-st+1)).
 				Reader(t.getPartialReader(s, bucketName, st, en))
 		} else {
-			// No range requested so status 200 & return the entire object
-			r.Status(200).
+			// No range requested so return the entire object
+			r.Status(status).
 				AddHeader("Content-Length", fmt.Sprintf("%v", t.Length)).
 				Reader(t.getReader(s, bucketName))
 		}
@@ -236,7 +260,7 @@ func (s *ObjectStore) DeleteObject(r *rest.Rest) error {
 	obj := &Object{}
 
 	err := s.boltService.Update(func(tx *bolt.Tx) error {
-		b, err := s.getBucket(tx, bucketName)
+		b, meta, err := s.getBucketMeta(tx, bucketName)
 		if err != nil {
 			return err
 		}
@@ -246,6 +270,11 @@ func (s *ObjectStore) DeleteObject(r *rest.Rest) error {
 			return err
 		}
 
+		err = s.updateUsage(tx, b, bucketName, meta, -int64(obj.Length), -1)
+		if err != nil {
+			return err
+		}
+
 		obj.delete(b)
 		return nil
 	})
@@ -0,0 +1,66 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"strconv"
+)
+
+// The header used by CreateBucket to enable object lock.
+// Retention & legal holds are not enforced, the flag is only stored & reported.
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/object-lock-managing.html
+const objectLockEnabledHeader = "X-Amz-Bucket-Object-Lock-Enabled"
+
+// ObjectLockConfiguration is the response of the ?object-lock sub-resource
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name `xml:"ObjectLockConfiguration"`
+	Xmlns             string   `xml:"xmlns,attr"`
+	ObjectLockEnabled string   `xml:"ObjectLockEnabled"`
+}
+
+// objectLockEnabled returns true if CreateBucket requested object lock
+func objectLockEnabled(headers map[string][]string) (bool, error) {
+	h, ok := headers[objectLockEnabledHeader]
+	if !ok || len(h) == 0 {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(h[0])
+	if err != nil {
+		return false, awserror.InvalidArgument("x-amz-bucket-object-lock-enabled must be true or false")
+	}
+	return enabled, nil
+}
+
+// getObjectLockConfiguration reports if object lock was enabled when the bucket was created
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectLockConfiguration.html
+func (s *ObjectStore) getObjectLockConfiguration(r *rest.Rest) error {
+	enabled := false
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, r.Var("BucketName"))
+		if err != nil {
+			return err
+		}
+
+		enabled = meta.ObjectLockEnabled
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		return awserror.ObjectLockConfigurationNotFoundError()
+	}
+
+	r.Status(200).
+		XML().
+		Value(&ObjectLockConfiguration{
+			Xmlns:             "http://s3.amazonaws.com/doc/2006-03-01/",
+			ObjectLockEnabled: "Enabled",
+		})
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package objectstore
+
+import (
+	"github.com/peter-mount/objectstore/awserror"
+	"testing"
+)
+
+func TestObjectLockEnabled(t *testing.T) {
+	for _, test := range []struct {
+		value   string
+		enabled bool
+		code    string
+	}{
+		{value: "", enabled: false},
+		{value: "true", enabled: true},
+		{value: "True", enabled: true},
+		{value: "false", enabled: false},
+		{value: "yes", code: "InvalidArgument"},
+	} {
+		t.Run(test.value, func(t *testing.T) {
+			headers := map[string][]string{}
+			if test.value != "" {
+				headers[objectLockEnabledHeader] = []string{test.value}
+			}
+
+			enabled, err := objectLockEnabled(headers)
+			switch {
+			case test.code != "" && err == nil:
+				t.Errorf("expected %s", test.code)
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %s expected %s", awserror.ToError(err).Code, test.code)
+			case test.code == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case enabled != test.enabled:
+				t.Errorf("got %v expected %v", enabled, test.enabled)
+			}
+		})
+	}
+}
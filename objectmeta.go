@@ -7,6 +7,7 @@ import (
 	"github.com/peter-mount/go-kernel/v2/bolt"
 	"github.com/peter-mount/objectstore/awserror"
 	"gopkg.in/mgo.v2/bson"
+	"strings"
 	"time"
 )
 
@@ -24,6 +25,10 @@ type Object struct {
 	ETag string
 	// The parts
 	Parts []ObjectPart
+	// Tags, maximum of 10
+	Tags map[string]string
+	// The object's ACL
+	ACL *ACL
 }
 
 type ObjectPart struct {
@@ -35,6 +40,19 @@ type ObjectPart struct {
 	Length int
 }
 
+// isMetadataHeader returns true if a request header should be stored in an
+// object's metadata
+func isMetadataHeader(hk string) bool {
+	if hk == "Content-Type" {
+		return true
+	}
+	// Headers used to set the tags or ACL are not metadata
+	if hk == "X-Amz-Tagging" || hk == "X-Amz-Acl" || strings.HasPrefix(hk, "X-Amz-Grant-") {
+		return false
+	}
+	return strings.HasPrefix(hk, "X-Amz-")
+}
+
 // etag calculates the object's etag
 func etag(d []byte) string {
 	hash := md5.Sum(d)
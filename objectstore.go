@@ -6,6 +6,7 @@ import (
 	"github.com/peter-mount/go-kernel/v2/rest"
 	"github.com/peter-mount/objectstore/auth"
 	eventservice "github.com/peter-mount/objectstore/event/service"
+	"sync"
 	"time"
 )
 
@@ -18,6 +19,17 @@ type ObjectStore struct {
 
 	region  *string
 	website *bool
+	// Other regions buckets may be created in
+	otherRegions *string
+	regions      []string
+	// The region of each bucket, so authenticating a request doesn't need to
+	// read the bucket's metadata
+	bucketRegions sync.Map
+	// Base domains for virtual hosted style buckets
+	domain  *string
+	domains []string
+	// Stops deleting objects expired by lifecycle rules
+	stopLifecycle func()
 }
 
 type Storage struct {
@@ -59,6 +71,8 @@ const (
 	partmeta_prefix = "upload\001"
 	// Part suffix, used for multipart upload parts, prefix is the UploadId
 	partmeta_suffix = "\002"
+	// Key of the bucket's own metadata
+	bucketmeta_key = "bucket\001"
 	// The block size used when reading MultipartForm
 	size_24K = (1 << 20) * 24
 )
@@ -0,0 +1,254 @@
+package policy
+
+import (
+  "github.com/peter-mount/go-glob"
+  "github.com/peter-mount/objectstore/condition"
+  "github.com/peter-mount/objectstore/utils"
+  "strings"
+)
+
+// The outcome of evaluating a request against one or more policies.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_evaluation-logic.html
+type Decision int
+
+const (
+  // No statement applied to the request so it's implicitly denied
+  NotApplicable Decision = iota
+  // A statement explicitly allowed the request
+  Allow
+  // A statement explicitly denied the request
+  Deny
+)
+
+func (d Decision) String() string {
+  switch d {
+    case Allow:
+      return "Allow"
+    case Deny:
+      return "Deny"
+    default:
+      return "NotApplicable"
+  }
+}
+
+// Allowed returns true only if the decision is an explicit Allow
+func (d Decision) Allowed() bool {
+  return d == Allow
+}
+
+// A request to evaluate against a policy
+type Request struct {
+  // The principal making the request. Use utils.AnonymousARN() for an
+  // unauthenticated request.
+  Principal    *utils.ARN
+  // The principal's canonical id, matched against CanonicalUser principals
+  CanonicalId   string
+  // The action being performed, e.g. "s3:GetObject"
+  Action        string
+  // The resource the action is performed on, e.g. "arn:aws:s3:::bucket/key"
+  Resource     *utils.ARN
+  // The request context the statement's Condition is evaluated against
+  Context       condition.Context
+}
+
+// Evaluate evaluates a request against this policy.
+// Returns the decision and the Sid of the statement that decided it.
+func (p *Policy) Evaluate( req *Request ) (Decision, string) {
+  if p == nil {
+    return NotApplicable, ""
+  }
+  return Evaluate( req, p )
+}
+
+// Evaluate evaluates a request against a set of policies.
+//
+// An explicit Deny in any statement wins, otherwise an Allow in any statement
+// allows the request, otherwise the request is implicitly denied.
+//
+// Returns the decision and the Sid of the statement that decided it, "" if
+// NotApplicable.
+func Evaluate( req *Request, policies ...*Policy ) (Decision, string) {
+  decision, sid := NotApplicable, ""
+
+  for _, p := range policies {
+    if p == nil {
+      continue
+    }
+
+    for i := range p.Statement {
+      stmt := &p.Statement[i]
+      if !stmt.Matches( req ) {
+        continue
+      }
+
+      if stmt.Effect.Denied() {
+        // Explicit deny always wins so we can stop here
+        return Deny, stmt.Sid
+      }
+
+      if decision == NotApplicable {
+        decision, sid = Allow, stmt.Sid
+      }
+    }
+  }
+
+  return decision, sid
+}
+
+// Matches returns true if this statement applies to the request
+func (a *Statement) Matches( req *Request ) bool {
+  return a.Principal.Matches( req ) &&
+    a.Action.Matches( req.Action ) &&
+    a.Resource.Matches( req.Resource ) &&
+    a.conditionMatches( req )
+}
+
+// conditionMatches returns true if the statement's Condition applies.
+//
+// If the Condition cannot be evaluated we fail safe: a Deny always applies
+// whilst an Allow never does.
+func (a *Statement) conditionMatches( req *Request ) bool {
+  if len( a.Condition ) == 0 {
+    return true
+  }
+
+  ok, err := a.Condition.Evaluate( req.Context )
+  if err != nil {
+    return a.Effect.Denied()
+  }
+  return ok
+}
+
+// Matches returns true if the principal applies to the request.
+//
+// An empty Principal, as used in identity policies, applies to everyone as
+// the policy is attached to the principal.
+func (a *Principal) Matches( req *Request ) bool {
+  if a.IsNil() {
+    return true
+  }
+
+  found := false
+  for k, v := range a.principal {
+    for i := range v {
+      if principalMatches( k, &v[i], req ) {
+        found = true
+      }
+    }
+  }
+
+  return found != a.negate
+}
+
+func principalMatches( k string, p *utils.ARN, req *Request ) bool {
+  switch k {
+    case "AWS":
+      // "*" is everyone including anonymous
+      if p.IsAnonymous() {
+        return true
+      }
+
+      if req.Principal.IsNil() || req.Principal.IsAnonymous() || IsFederated( req.Principal ) {
+        return false
+      }
+
+      // Just the account id or the account's root user then match everyone in that account
+      if p.IsUserId() || (p.Service == "iam" && p.Resource == "root") {
+        return p.Account == req.Principal.Account
+      }
+
+      return p.Matches( req.Principal ) || isRoleSession( p, req.Principal )
+
+    case "CanonicalUser":
+      return req.CanonicalId != "" && p.String() == req.CanonicalId
+
+    case "Federated":
+      if !IsFederated( req.Principal ) {
+        return false
+      }
+
+      // Just the provider's name, e.g. "token.actions.githubusercontent.com"
+      if p.IsUserId() {
+        return req.Principal.Resource == FederatedPrefix + p.Account
+      }
+
+      return p.Matches( req.Principal )
+
+    default:
+      return false
+  }
+}
+
+// isRoleSession returns true if principal is a session of a role, e.g.
+// arn:aws:sts::123456789012:assumed-role/batch/job is a session of
+// arn:aws:iam::123456789012:role/batch
+func isRoleSession( role, principal *utils.ARN ) bool {
+  if role.Service != "iam" || !strings.HasPrefix( role.Resource, "role/" ) || principal.Service != "sts" {
+    return false
+  }
+
+  // Sessions only include the role's name, not it's path
+  s := strings.Split( principal.Resource, "/" )
+  return len( s ) == 3 && s[0] == "assumed-role" &&
+    s[1] == role.Resource[strings.LastIndex( role.Resource, "/" )+1:] &&
+    role.Partition == principal.Partition &&
+    role.Account == principal.Account
+}
+
+// The resource prefix of the arn of an OpenID Connect identity provider
+const FederatedPrefix = "oidc-provider/"
+
+// IsFederated returns true if a principal is an identity provider, e.g.
+// "arn:aws:iam::123456789012:oidc-provider/token.actions.githubusercontent.com"
+func IsFederated( a *utils.ARN ) bool {
+  return !a.IsNil() && a.Service == "iam" && strings.HasPrefix( a.Resource, FederatedPrefix )
+}
+
+// Matches returns true if the action applies to the requested action.
+// Actions are case insensitive and may contain the * wildcard.
+func (a *Action) Matches( action string ) bool {
+  if a.IsNil() {
+    return false
+  }
+
+  action = strings.ToLower( action )
+
+  found := false
+  for _, e := range a.actions {
+    if actionMatches( strings.ToLower( e ), action ) {
+      found = true
+      break
+    }
+  }
+
+  return found != a.negate
+}
+
+func actionMatches( pattern, action string ) bool {
+  if pattern == "*" {
+    return true
+  }
+  if strings.Contains( pattern, "*" ) {
+    return glob.Glob( pattern, action )
+  }
+  return pattern == action
+}
+
+// Matches returns true if the resource applies to the requested resource
+func (a *Resource) Matches( resource *utils.ARN ) bool {
+  if a.IsNil() {
+    return false
+  }
+
+  found := false
+  for i := range a.resources {
+    r := &a.resources[i]
+    // "*" is every resource
+    if r.IsAnonymous() || r.Matches( resource ) {
+      found = true
+      break
+    }
+  }
+
+  return found != a.negate
+}
@@ -0,0 +1,306 @@
+package policy
+
+import (
+  "github.com/peter-mount/objectstore/condition"
+  "github.com/peter-mount/objectstore/utils"
+  "testing"
+)
+
+const testEvaluatePolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "PublicRead",
+      "Effect": "Allow",
+      "Principal": "*",
+      "Action": ["s3:GetObject"],
+      "Resource": "arn:aws:s3:::examplebucket/public/*"
+    },
+    {
+      "Sid": "BobWrite",
+      "Effect": "Allow",
+      "Principal": {"AWS": "arn:aws:iam::123456789012:user/Bob"},
+      "Action": "s3:Put*",
+      "Resource": "arn:aws:s3:::examplebucket/*"
+    },
+    {
+      "Sid": "DenySecret",
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": "arn:aws:s3:::examplebucket/public/secret/*"
+    },
+    {
+      "Sid": "AccountList",
+      "Effect": "Allow",
+      "Principal": {"AWS": "123456789012"},
+      "Action": "s3:ListBucket",
+      "Resource": "arn:aws:s3:::examplebucket"
+    },
+    {
+      "Sid": "NotBob",
+      "Effect": "Deny",
+      "NotPrincipal": {"AWS": "arn:aws:iam::123456789012:user/Bob"},
+      "NotAction": ["s3:Get*", "s3:List*"],
+      "Resource": "arn:aws:s3:::examplebucket/*"
+    }
+  ]
+}`
+
+func testArn( t *testing.T, s string ) *utils.ARN {
+  if s == "*" {
+    return utils.AnonymousARN()
+  }
+  a, err := utils.ParseARN( s )
+  if err != nil {
+    t.Fatal( err )
+  }
+  return a
+}
+
+func TestPolicy_Evaluate( t *testing.T ) {
+  p, err := Parse( []byte(testEvaluatePolicy) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  for i, test := range []struct {
+    principal string
+    action    string
+    resource  string
+    decision  Decision
+    sid       string
+  }{
+    // Anonymous read of public objects
+    {"*", "s3:GetObject", "arn:aws:s3:::examplebucket/public/index.html", Allow, "PublicRead"},
+    // Action names are case insensitive
+    {"*", "S3:getobject", "arn:aws:s3:::examplebucket/public/index.html", Allow, "PublicRead"},
+    // Anonymous read outside of public is implicitly denied
+    {"*", "s3:GetObject", "arn:aws:s3:::examplebucket/private/index.html", NotApplicable, ""},
+    // Explicit deny beats the public allow
+    {"*", "s3:GetObject", "arn:aws:s3:::examplebucket/public/secret/key", Deny, "DenySecret"},
+    // Bob can write
+    {"arn:aws:iam::123456789012:user/Bob", "s3:PutObject", "arn:aws:s3:::examplebucket/a/b", Allow, "BobWrite"},
+    // but not within the denied prefix
+    {"arn:aws:iam::123456789012:user/Bob", "s3:PutObject", "arn:aws:s3:::examplebucket/public/secret/a", Deny, "DenySecret"},
+    // Alice is denied writing by NotPrincipal/NotAction
+    {"arn:aws:iam::123456789012:user/Alice", "s3:PutObject", "arn:aws:s3:::examplebucket/a/b", Deny, "NotBob"},
+    // Account id principal
+    {"arn:aws:iam::123456789012:user/Alice", "s3:ListBucket", "arn:aws:s3:::examplebucket", Allow, "AccountList"},
+    // Another account
+    {"arn:aws:iam::999999999999:user/Alice", "s3:ListBucket", "arn:aws:s3:::examplebucket", NotApplicable, ""},
+    // Another bucket
+    {"arn:aws:iam::123456789012:user/Bob", "s3:PutObject", "arn:aws:s3:::otherbucket/a", NotApplicable, ""},
+  } {
+    req := &Request{
+      Principal: testArn( t, test.principal ),
+      Action:    test.action,
+      Resource:  testArn( t, test.resource ),
+    }
+
+    decision, sid := p.Evaluate( req )
+    if decision != test.decision || sid != test.sid {
+      t.Errorf( "%d: Expected %s %q got %s %q", i, test.decision, test.sid, decision, sid )
+    }
+  }
+}
+
+func TestEvaluate_multiplePolicies( t *testing.T ) {
+  allow, err := Parse( []byte( "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Sid\": \"A\",\"Effect\": \"Allow\",\"Action\": \"s3:*\",\"Resource\": \"*\"}]}" ) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  deny, err := Parse( []byte( "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Sid\": \"D\",\"Effect\": \"Deny\",\"Action\": \"s3:DeleteObject\",\"Resource\": \"*\"}]}" ) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  req := &Request{
+    Principal: testArn( t, "arn:aws:iam::123456789012:user/Bob" ),
+    Action:    "s3:GetObject",
+    Resource:  testArn( t, "arn:aws:s3:::examplebucket/key" ),
+  }
+
+  if d, sid := Evaluate( req, allow, deny ); d != Allow || sid != "A" {
+    t.Errorf( "Expected Allow A got %s %s", d, sid )
+  }
+
+  req.Action = "s3:DeleteObject"
+  if d, sid := Evaluate( req, allow, deny ); d != Deny || sid != "D" {
+    t.Errorf( "Expected Deny D got %s %s", d, sid )
+  }
+
+  if d, sid := Evaluate( req ); d != NotApplicable || sid != "" {
+    t.Errorf( "Expected NotApplicable got %s %s", d, sid )
+  }
+}
+
+func TestPolicy_Evaluate_condition( t *testing.T ) {
+  p, err := Parse( []byte( `{
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Sid": "LocalRead",
+        "Effect": "Allow",
+        "Principal": "*",
+        "Action": "s3:GetObject",
+        "Resource": "arn:aws:s3:::examplebucket/*",
+        "Condition": {"IpAddress": {"aws:SourceIp": "192.168.0.0/16"}}
+      },
+      {
+        "Sid": "DenyInsecure",
+        "Effect": "Deny",
+        "Principal": "*",
+        "Action": "s3:*",
+        "Resource": "arn:aws:s3:::examplebucket/*",
+        "Condition": {"Bool": {"aws:SecureTransport": "false"}}
+      }
+    ]
+  }` ) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  for i, test := range []struct {
+    ip        string
+    secure    string
+    decision  Decision
+    sid       string
+  }{
+    {"192.168.1.20", "true", Allow, "LocalRead"},
+    {"10.0.0.1", "true", NotApplicable, ""},
+    {"192.168.1.20", "false", Deny, "DenyInsecure"},
+  } {
+    req := &Request{
+      Principal: utils.AnonymousARN(),
+      Action:    "s3:GetObject",
+      Resource:  testArn( t, "arn:aws:s3:::examplebucket/key" ),
+      Context:   condition.NewContext().
+        Set( condition.KEY_SOURCE_IP, test.ip ).
+        Set( condition.KEY_SECURE_TRANSPORT, test.secure ),
+    }
+
+    decision, sid := p.Evaluate( req )
+    if decision != test.decision || sid != test.sid {
+      t.Errorf( "%d: Expected %s %q got %s %q", i, test.decision, test.sid, decision, sid )
+    }
+  }
+}
+
+func TestPolicy_Evaluate_federated( t *testing.T ) {
+  p, err := ParseTrust( []byte( `{
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Sid": "Main",
+        "Effect": "Allow",
+        "Principal": {"Federated": "arn:aws:iam::123456789012:oidc-provider/token.example.com"},
+        "Action": "sts:AssumeRoleWithWebIdentity",
+        "Condition": {
+          "StringEquals": {"token.example.com:aud": "sts.amazonaws.com"},
+          "StringLike": {"token.example.com:sub": "repo:example/*:ref:refs/heads/main"}
+        }
+      },
+      {
+        "Sid": "Other",
+        "Effect": "Allow",
+        "Principal": {"Federated": "other.example.com"},
+        "Action": "sts:AssumeRoleWithWebIdentity"
+      },
+      {
+        "Sid": "Account",
+        "Effect": "Allow",
+        "Principal": {"AWS": "123456789012"},
+        "Action": "sts:AssumeRole*"
+      }
+    ]
+  }` ) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  for i, test := range []struct {
+    principal string
+    sub       string
+    decision  Decision
+    sid       string
+  }{
+    {"arn:aws:iam::123456789012:oidc-provider/token.example.com", "repo:example/project:ref:refs/heads/main", Allow, "Main"},
+    // Condition on sub
+    {"arn:aws:iam::123456789012:oidc-provider/token.example.com", "repo:example/project:ref:refs/heads/dev", NotApplicable, ""},
+    // Provider by name
+    {"arn:aws:iam::123456789012:oidc-provider/other.example.com", "anyone", Allow, "Other"},
+    // Unknown provider, the account principal does not match providers
+    {"arn:aws:iam::123456789012:oidc-provider/unknown.example.com", "anyone", NotApplicable, ""},
+    // Users are not federated
+    {"arn:aws:iam::123456789012:user/other.example.com", "", Allow, "Account"},
+  } {
+    req := &Request{
+      Principal: testArn( t, test.principal ),
+      Action:    "sts:AssumeRoleWithWebIdentity",
+      Resource:  testArn( t, "arn:aws:iam::123456789012:role/ci" ),
+      Context:   condition.NewContext().
+        Set( "token.example.com:aud", "sts.amazonaws.com" ).
+        Set( "token.example.com:sub", test.sub ),
+    }
+
+    decision, sid := p.Evaluate( req )
+    if decision != test.decision || sid != test.sid {
+      t.Errorf( "%d: Expected %s %q got %s %q", i, test.decision, test.sid, decision, sid )
+    }
+  }
+}
+
+func TestPolicy_Evaluate_roleSession( t *testing.T ) {
+  p, err := Parse( []byte( `{
+    "Version": "2012-10-17",
+    "Statement": [
+      {
+        "Sid": "Batch",
+        "Effect": "Allow",
+        "Principal": {"AWS": "arn:aws:iam::123456789012:role/jobs/batch"},
+        "Action": "s3:GetObject",
+        "Resource": "arn:aws:s3:::examplebucket/*"
+      },
+      {
+        "Sid": "Session",
+        "Effect": "Allow",
+        "Principal": {"AWS": "arn:aws:sts::123456789012:assumed-role/ci/release"},
+        "Action": "s3:PutObject",
+        "Resource": "arn:aws:s3:::examplebucket/*"
+      }
+    ]
+  }` ) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  for i, test := range []struct {
+    principal string
+    action    string
+    decision  Decision
+    sid       string
+  }{
+    // A role matches any of it's sessions, which only include the role's name
+    {"arn:aws:sts::123456789012:assumed-role/batch/job-1", "s3:GetObject", Allow, "Batch"},
+    {"arn:aws:iam::123456789012:role/jobs/batch", "s3:GetObject", Allow, "Batch"},
+    {"arn:aws:sts::123456789012:assumed-role/other/job-1", "s3:GetObject", NotApplicable, ""},
+    {"arn:aws:sts::999999999999:assumed-role/batch/job-1", "s3:GetObject", NotApplicable, ""},
+    {"arn:aws:iam::123456789012:user/batch", "s3:GetObject", NotApplicable, ""},
+    // A session principal matches just that session
+    {"arn:aws:sts::123456789012:assumed-role/ci/release", "s3:PutObject", Allow, "Session"},
+    {"arn:aws:sts::123456789012:assumed-role/ci/other", "s3:PutObject", NotApplicable, ""},
+  } {
+    req := &Request{
+      Principal: testArn( t, test.principal ),
+      Action:    test.action,
+      Resource:  testArn( t, "arn:aws:s3:::examplebucket/key" ),
+    }
+
+    decision, sid := p.Evaluate( req )
+    if decision != test.decision || sid != test.sid {
+      t.Errorf( "%d: Expected %s %q got %s %q", i, test.decision, test.sid, decision, sid )
+    }
+  }
+}
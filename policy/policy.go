@@ -1,5 +1,11 @@
 package policy
 
+import (
+  "encoding/json"
+  "fmt"
+  "github.com/peter-mount/objectstore/utils"
+)
+
 const (
   // Current policy version
   // https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_policies_elements_version.html
@@ -17,3 +23,77 @@ type Policy struct {
   Id          string      `json:"Id,omitempty" xml:"Id,omitempty" yaml:"Id"`
   Statement []Statement   `json:"Statement" xml:"Statement" yaml:"Statement"`
 }
+
+// Parse unmarshals a policy document and validates it against the policy grammar
+func Parse( b []byte ) (*Policy, error) {
+  p := &Policy{}
+  err := json.Unmarshal( b, p )
+  if err != nil {
+    return nil, err
+  }
+
+  err = p.Validate()
+  if err != nil {
+    return nil, err
+  }
+  return p, nil
+}
+
+// ParseTrust unmarshals a role's trust policy and validates it.
+// A trust policy has no Resource as it applies to the role it's attached to
+// but each statement must have a Principal.
+func ParseTrust( b []byte ) (*Policy, error) {
+  p := &Policy{}
+  err := json.Unmarshal( b, p )
+  if err != nil {
+    return nil, err
+  }
+
+  for i := range p.Statement {
+    stmt := &p.Statement[i]
+    if stmt.Principal.IsNil() {
+      return nil, fmt.Errorf( "Missing Principal in statement %q", stmt.Sid )
+    }
+    if !stmt.Resource.IsNil() {
+      return nil, fmt.Errorf( "Resource not allowed in trust policy statement %q", stmt.Sid )
+    }
+    // The resource is the role
+    stmt.Resource = *NewResource( *utils.AnonymousARN() )
+  }
+
+  err = p.Validate()
+  if err != nil {
+    return nil, err
+  }
+  return p, nil
+}
+
+// Validate checks the policy against the policy grammar
+func (p *Policy) Validate() error {
+  if p.Version != VERSION_CURRENT && p.Version != VERSION_OLDER {
+    return fmt.Errorf( "Invalid Version %q", p.Version )
+  }
+
+  if len( p.Statement ) == 0 {
+    return fmt.Errorf( "Missing Statement" )
+  }
+
+  sids := make( map[string]bool )
+  for i := range p.Statement {
+    stmt := &p.Statement[i]
+
+    if stmt.Sid != "" {
+      if sids[stmt.Sid] {
+        return fmt.Errorf( "Duplicate Sid %q", stmt.Sid )
+      }
+      sids[stmt.Sid] = true
+    }
+
+    err := stmt.Validate()
+    if err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
@@ -2,6 +2,7 @@ package policy
 
 import (
   "encoding/json"
+  "github.com/peter-mount/objectstore/utils"
   "strings"
   "testing"
 )
@@ -115,3 +116,84 @@ func TestPolicyUnmarshal_4( t *testing.T ) {
     }
   }
 }
+
+func TestPolicy_Parse_valid( t *testing.T ) {
+  const src = "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Sid\": \"Test\",\"Effect\": \"Allow\",\"Principal\": \"*\",\"Action\": \"s3:GetObject\",\"Resource\": \"arn:aws:s3:::examplebucket/*\"}]}"
+
+  p, err := Parse( []byte(src) )
+  if err != nil {
+    t.Fatal( err )
+  }
+  if len( p.Statement ) != 1 {
+    t.Errorf( "Expected 1 statement got %d", len( p.Statement ) )
+  }
+}
+
+func TestPolicy_Parse_invalid( t *testing.T ) {
+  for i, src := range []string{
+    // Invalid version
+    "{\"Version\": \"2019-01-01\",\"Statement\": [{\"Effect\": \"Allow\",\"Action\": \"s3:*\",\"Resource\": \"*\"}]}",
+    // No statements
+    "{\"Version\": \"2012-10-17\",\"Statement\": []}",
+    // Missing Effect
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Action\": \"s3:*\",\"Resource\": \"*\"}]}",
+    // Missing Action
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Resource\": \"*\"}]}",
+    // Missing Resource
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Action\": \"s3:*\"}]}",
+    // Both Action & NotAction
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Action\": \"s3:*\",\"NotAction\": \"s3:*\",\"Resource\": \"*\"}]}",
+    // Unknown element
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Action\": \"s3:*\",\"Resource\": \"*\",\"Wibble\": \"*\"}]}",
+    // Duplicate Sid
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Sid\": \"A\",\"Effect\": \"Allow\",\"Action\": \"s3:*\",\"Resource\": \"*\"},{\"Sid\": \"A\",\"Effect\": \"Deny\",\"Action\": \"s3:*\",\"Resource\": \"*\"}]}",
+    // Invalid Effect
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Wibble\",\"Action\": \"s3:*\",\"Resource\": \"*\"}]}",
+    // Unknown condition operator
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Action\": \"s3:*\",\"Resource\": \"*\",\"Condition\": {\"Wibble\": {\"s3:prefix\": \"a\"}}}]}",
+  } {
+    _, err := Parse( []byte(src) )
+    if err == nil {
+      t.Errorf( "%d: Expected error for %s", i, src )
+    }
+  }
+}
+
+func TestPolicy_ParseTrust( t *testing.T ) {
+  const src = "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Principal\": {\"AWS\": \"arn:aws:iam::123456789012:user/ci\"},\"Action\": \"sts:AssumeRole\"}]}"
+
+  p, err := ParseTrust( []byte(src) )
+  if err != nil {
+    t.Fatal( err )
+  }
+
+  req := &Request{
+    Principal: utils.NewARN( "arn", "aws", "iam", "", "123456789012", "user/ci" ),
+    Action:    "sts:AssumeRole",
+    Resource:  utils.NewARN( "arn", "aws", "iam", "", "123456789012", "role/batch" ),
+  }
+  if d, _ := p.Evaluate( req ); d != Allow {
+    t.Errorf( "Expected Allow got %s", d )
+  }
+
+  req.Principal = utils.NewARN( "arn", "aws", "iam", "", "123456789012", "user/other" )
+  if d, _ := p.Evaluate( req ); d != NotApplicable {
+    t.Errorf( "Expected NotApplicable got %s", d )
+  }
+}
+
+func TestPolicy_ParseTrust_invalid( t *testing.T ) {
+  for i, src := range []string{
+    // Missing Principal
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Action\": \"sts:AssumeRole\"}]}",
+    // Resource not allowed
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Principal\": \"*\",\"Action\": \"sts:AssumeRole\",\"Resource\": \"*\"}]}",
+    // Missing Action
+    "{\"Version\": \"2012-10-17\",\"Statement\": [{\"Effect\": \"Allow\",\"Principal\": \"*\"}]}",
+  } {
+    _, err := ParseTrust( []byte(src) )
+    if err == nil {
+      t.Errorf( "%d: Expected error for %s", i, src )
+    }
+  }
+}
@@ -30,6 +30,11 @@ func (a *Principal) ForEach( f func(string,PrincipalMap) error ) error {
   return nil
 }
 
+// IsNil returns true if the Principal is empty
+func (a *Principal) IsNil() bool {
+  return a == nil || len( a.principal ) == 0
+}
+
 // IsNegate returns true if this is a NotAction rather than Action block
 func (a *Principal) IsNegate() bool {
   return a!=nil && a.negate
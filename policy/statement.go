@@ -20,6 +20,8 @@ type Statement struct {
   Resource      Resource
   // Condition optional
   Condition     condition.Condition
+  // true if Effect was present when unmarshalled
+  hasEffect     bool
 }
 
 func (a *Statement) UnmarshalJSON( b []byte ) error {
@@ -35,6 +37,15 @@ func (a *Statement) UnmarshalJSON( b []byte ) error {
     return err
   }
 
+  // Reject both forms of the mutually exclusive elements
+  for _, k := range []string{ "Principal", "Action", "Resource" } {
+    _, e1 := m[k]
+    _, e2 := m["Not" + k]
+    if e1 && e2 {
+      return fmt.Errorf( "%s and Not%s are mutually exclusive", k, k )
+    }
+  }
+
   // Now run through the keys & unmarshal into each one
   for k, v := range m {
     switch k {
@@ -42,6 +53,7 @@ func (a *Statement) UnmarshalJSON( b []byte ) error {
         err = json.Unmarshal( v, &a.Sid)
       case "Effect":
         err = json.Unmarshal( v, &a.Effect)
+        a.hasEffect = true
 
       // Mutually exclusive
       case "Principal":
@@ -66,6 +78,9 @@ func (a *Statement) UnmarshalJSON( b []byte ) error {
 
       case "Condition":
         err = json.Unmarshal( v, &a.Condition)
+
+      default:
+        return fmt.Errorf( "Unsupported element %s", k )
     }
     if err != nil {
       return fmt.Errorf( "Failed to unmarshal %s: %v", k, err )
@@ -75,6 +90,23 @@ func (a *Statement) UnmarshalJSON( b []byte ) error {
   return nil
 }
 
+// Validate checks the statement contains the required elements
+func (a *Statement) Validate() error {
+  if !a.hasEffect {
+    return fmt.Errorf( "Missing Effect in statement %q", a.Sid )
+  }
+  if a.Action.IsNil() {
+    return fmt.Errorf( "Missing Action in statement %q", a.Sid )
+  }
+  if a.Resource.IsNil() {
+    return fmt.Errorf( "Missing Resource in statement %q", a.Sid )
+  }
+  if err := a.Condition.Validate(); err != nil {
+    return fmt.Errorf( "%s in statement %q", err, a.Sid )
+  }
+  return nil
+}
+
 func (a *Statement) MarshalJSON() ( []byte, error ) {
   if a == nil {
     return []byte("null"), nil
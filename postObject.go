@@ -0,0 +1,327 @@
+package objectstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A browser based upload POST policy
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+type postPolicy struct {
+	Expiration time.Time     `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// The response to a POST upload when success_action_status is 201
+type PostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// Form fields which do not need to be in the policy conditions
+var postPolicyExemptFields = map[string]bool{
+	"policy":               true,
+	"file":                 true,
+	"x-amz-signature":      true,
+	"signature":            true,
+	"awsaccesskeyid":       true,
+	"x-amz-security-token": true,
+}
+
+// Form fields which are stored as the objects headers
+var postObjectHeaders = map[string]bool{
+	"cache-control":       true,
+	"content-type":        true,
+	"content-disposition": true,
+	"content-encoding":    true,
+	"expires":             true,
+}
+
+// parsePostPolicy decodes the base64 encoded policy from a POST upload
+func parsePostPolicy(s string) (*postPolicy, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, awserror.InvalidPolicyDocument("Policy could not be decoded")
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+
+	p := &postPolicy{}
+	if err = d.Decode(p); err != nil {
+		return nil, awserror.InvalidPolicyDocument("%s", err.Error())
+	}
+
+	if p.Expiration.IsZero() {
+		return nil, awserror.InvalidPolicyDocument("Policy missing expiration.")
+	}
+
+	return p, nil
+}
+
+// check checks the policy has not expired & the form meets the policy's conditions.
+// Every field in the form, other than those which are exempt, must have a condition.
+func (p *postPolicy) check(bucketName string, fields map[string]string, length int64, now time.Time) error {
+	if now.After(p.Expiration) {
+		return awserror.PolicyConditionFailed("Policy expired.")
+	}
+
+	value := func(name string) string {
+		if name == "bucket" {
+			return bucketName
+		}
+		return fields[name]
+	}
+
+	matched := make(map[string]bool)
+
+	for _, c := range p.Conditions {
+		switch c := c.(type) {
+		// {"field": "value"} is an exact match
+		case map[string]interface{}:
+			for k, v := range c {
+				s, ok := v.(string)
+				if !ok {
+					return awserror.InvalidPolicyDocument("Condition for %s must be a string", k)
+				}
+				name := strings.ToLower(k)
+				if value(name) != s {
+					return awserror.PolicyConditionFailed("Policy Condition failed: [\"eq\", \"$%s\", \"%s\"]", k, s)
+				}
+				matched[name] = true
+			}
+
+		case []interface{}:
+			if len(c) != 3 {
+				return awserror.InvalidPolicyDocument("Wrong number of arguments in condition")
+			}
+			op, _ := c[0].(string)
+
+			switch strings.ToLower(op) {
+			case "eq", "starts-with":
+				k, ok1 := c[1].(string)
+				s, ok2 := c[2].(string)
+				if !ok1 || !ok2 || !strings.HasPrefix(k, "$") {
+					return awserror.InvalidPolicyDocument("Invalid %s condition", op)
+				}
+				name := strings.ToLower(k[1:])
+				v := value(name)
+				if (op == "eq" && v != s) || (op != "eq" && !strings.HasPrefix(v, s)) {
+					return awserror.PolicyConditionFailed("Policy Condition failed: [\"%s\", \"%s\", \"%s\"]", op, k, s)
+				}
+				matched[name] = true
+
+			case "content-length-range":
+				min, err1 := jsonInt(c[1])
+				max, err2 := jsonInt(c[2])
+				if err1 != nil || err2 != nil {
+					return awserror.InvalidPolicyDocument("Invalid content-length-range condition")
+				}
+				if length < min {
+					return awserror.EntityTooSmall()
+				}
+				if length > max {
+					return awserror.EntityTooLarge()
+				}
+
+			default:
+				return awserror.InvalidPolicyDocument("Invalid condition: %s", op)
+			}
+
+		default:
+			return awserror.InvalidPolicyDocument("Invalid condition")
+		}
+	}
+
+	for name := range fields {
+		if !(matched[name] || postPolicyExemptFields[name] || strings.HasPrefix(name, "x-ignore-")) {
+			return awserror.PolicyConditionFailed("Extra input fields: %s", name)
+		}
+	}
+
+	return nil
+}
+
+func jsonInt(v interface{}) (int64, error) {
+	if n, ok := v.(json.Number); ok {
+		return n.Int64()
+	}
+	return 0, awserror.InvalidPolicyDocument("Not a number")
+}
+
+// postHeaders returns the form fields as the headers we would get for a PUT
+func postHeaders(fields map[string]string) (map[string][]string, error) {
+	headers := make(map[string][]string)
+
+	for name, v := range fields {
+		if postObjectHeaders[name] || strings.HasPrefix(name, "x-amz-meta-") {
+			headers[http.CanonicalHeaderKey(name)] = []string{v}
+		}
+	}
+
+	if v, exists := fields["acl"]; exists {
+		headers["X-Amz-Acl"] = []string{v}
+	}
+
+	// Tags are in the same xml as PutObjectTagging
+	if v, exists := fields["tagging"]; exists {
+		t := &Tagging{}
+		if err := xml.Unmarshal([]byte(v), t); err != nil {
+			return nil, awserror.MalformedXML()
+		}
+
+		tags, err := t.tags(maxObjectTags)
+		if err != nil {
+			return nil, err
+		}
+
+		q := url.Values{}
+		for k, v := range tags {
+			q.Set(k, v)
+		}
+		headers["X-Amz-Tagging"] = []string{q.Encode()}
+	}
+
+	return headers, nil
+}
+
+// CreateObjectBrowserUpload creates a new S3 object using a MultipartForm
+// https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectPOST.html
+func (s *ObjectStore) CreateObjectBrowserUpload(r *rest.Rest) error {
+
+	err := r.Request().ParseMultipartForm(size_24K)
+	if err != nil {
+		return awserror.InvalidArgument("POST requires a multipart/form-data body")
+	}
+
+	bucketName := r.Var("BucketName")
+	form := r.Request().MultipartForm
+
+	// Field names are case insensitive
+	fields := make(map[string]string)
+	for k, v := range form.Value {
+		if len(v) > 0 {
+			fields[strings.ToLower(k)] = v[0]
+		}
+	}
+
+	key := fields["key"]
+	if key == "" {
+		return awserror.InvalidArgument("Bucket POST must contain a field named 'key'.  If it is specified, please check the order of the fields.")
+	}
+
+	files := form.File["file"]
+	if len(files) != 1 {
+		return awserror.InvalidArgument("POST requires exactly one file upload per request.")
+	}
+
+	infile, err := files[0].Open()
+	if err != nil {
+		return err
+	}
+	defer infile.Close()
+
+	body, err := ioutil.ReadAll(infile)
+	if err != nil {
+		return err
+	}
+
+	// The credential comes from the form not the Authorization header
+	cred, err := s.authService.GetPostPolicyCredential(r, fields)
+	if err != nil {
+		return err
+	}
+	r.SetAttribute(auth.AUTH_KEY, cred)
+
+	if v, exists := fields["policy"]; exists {
+		policy, err := parsePostPolicy(v)
+		if err != nil {
+			return err
+		}
+
+		err = policy.check(bucketName, fields, int64(len(body)), s.timeNow())
+		if err != nil {
+			return err
+		}
+	}
+
+	// ${filename} is replaced with the name of the uploaded file
+	key = strings.Replace(key, "${filename}", files[0].Filename, -1)
+
+	err = s.authorizeRequest(r, actionPutObject, bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	headers, err := postHeaders(fields)
+	if err != nil {
+		return err
+	}
+
+	obj, err := s.storeObject(r, "Post", bucketName, key, headers, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	location := "/" + bucketName + "/" + key
+	if host := r.Request().Host; host != "" {
+		scheme := "http"
+		if r.Request().TLS != nil {
+			scheme = "https"
+		}
+		location = scheme + "://" + host + location
+	}
+
+	redirect := fields["success_action_redirect"]
+	if redirect == "" {
+		redirect = fields["redirect"]
+	}
+	if redirect != "" {
+		u, err := url.Parse(redirect)
+		if err == nil {
+			q := u.Query()
+			q.Set("bucket", bucketName)
+			q.Set("key", key)
+			q.Set("etag", "\""+obj.ETag+"\"")
+			u.RawQuery = q.Encode()
+
+			r.Status(http.StatusSeeOther).
+				AddHeader("Location", u.String())
+			return nil
+		}
+	}
+
+	switch fields["success_action_status"] {
+	case "200":
+		r.Status(http.StatusOK)
+	case "201":
+		r.Status(http.StatusCreated).
+			XML().
+			Value(&PostResponse{
+				Location: location,
+				Bucket:   bucketName,
+				Key:      key,
+				ETag:     "\"" + obj.ETag + "\"",
+			})
+	default:
+		r.Status(http.StatusNoContent)
+	}
+
+	r.Etag(obj.ETag).
+		AddHeader("Location", location)
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package objectstore
+
+import (
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"gopkg.in/mgo.v2/bson"
+	"strings"
+)
+
+const (
+	// Prefix of bbolt buckets used internally, this is not a valid bucket name
+	internal_prefix = "\001"
+	// Bucket holding the storage used by each user, keyed by canonical id
+	usage_bucket = internal_prefix + "usage"
+)
+
+// Usage is the storage used by a bucket or user
+type Usage struct {
+	// Bytes stored
+	Size int64
+	// Number of objects
+	Objects int64
+}
+
+// isInternalBucket returns true if a bbolt bucket is used internally and not
+// a user's bucket
+func isInternalBucket(name string) bool {
+	return strings.HasPrefix(name, internal_prefix)
+}
+
+// add adjusts the usage, returning an error if it would then exceed the quota.
+// Reducing the usage is always allowed.
+func (u *Usage) add(size, objects int64, quota auth.Quota, owner string) error {
+	if (size > 0 && quota.MaxSize > 0 && u.Size+size > quota.MaxSize) ||
+		(objects > 0 && quota.MaxObjects > 0 && u.Objects+objects > quota.MaxObjects) {
+		return awserror.QuotaExceeded("%s quota exceeded", owner)
+	}
+
+	u.Size += size
+	u.Objects += objects
+
+	// Usage recorded before objects were counted could go negative
+	if u.Size < 0 {
+		u.Size = 0
+	}
+	if u.Objects < 0 {
+		u.Objects = 0
+	}
+
+	return nil
+}
+
+// bucketUsage returns the usage of a bucket.
+// Buckets created before we kept usage are counted once & the result kept.
+func (s *ObjectStore) bucketUsage(b *bolt.Bucket, meta *BucketMeta) (*Usage, error) {
+	if meta.Usage != nil {
+		return meta.Usage, nil
+	}
+
+	u := &Usage{}
+	c := b.Cursor()
+	for k, v := c.Seek(meta_prefix); k != "" && strings.HasPrefix(k, meta_prefix); k, v = c.Next() {
+		obj := &Object{}
+		if err := obj.getBytes(v); err != nil {
+			return nil, err
+		}
+		u.Size += int64(obj.Length)
+		u.Objects++
+	}
+
+	meta.Usage = u
+	return u, nil
+}
+
+// userUsage returns the usage of all buckets owned by a user.
+// The first time this is called for a user it is calculated from their buckets.
+func (s *ObjectStore) userUsage(tx *bolt.Tx, owner string) (*Usage, error) {
+	ub, err := tx.CreateBucketIfNotExists(usage_bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &Usage{}
+	if v := ub.Get(owner); v != nil {
+		return u, bson.Unmarshal(v, u)
+	}
+
+	err = tx.ForEach(func(name string, b *bolt.Bucket) error {
+		if isInternalBucket(name) {
+			return nil
+		}
+
+		meta := &BucketMeta{}
+		if err := meta.get(b); err != nil {
+			return err
+		}
+
+		if s.bucketOwner(meta).ID == owner {
+			bu, err := s.bucketUsage(b, meta)
+			if err != nil {
+				return err
+			}
+			u.Size += bu.Size
+			u.Objects += bu.Objects
+		}
+		return nil
+	})
+	return u, err
+}
+
+// updateUsage adjusts the usage of a bucket & it's owner, returning an error
+// if either of their quotas would be exceeded.
+// The bucket's metadata is stored with the new usage.
+func (s *ObjectStore) updateUsage(tx *bolt.Tx, b *bolt.Bucket, bucketName string, meta *BucketMeta, size, objects int64) error {
+	bu, err := s.bucketUsage(b, meta)
+	if err != nil {
+		return err
+	}
+
+	err = bu.add(size, objects, s.authService.BucketQuota(bucketName), "Bucket")
+	if err != nil {
+		return err
+	}
+
+	owner := s.bucketOwner(meta).ID
+	uu, err := s.userUsage(tx, owner)
+	if err != nil {
+		return err
+	}
+
+	quota, err := s.authService.UserQuota(tx, owner)
+	if err != nil {
+		return err
+	}
+
+	err = uu.add(size, objects, quota, "User")
+	if err != nil {
+		return err
+	}
+
+	v, err := bson.Marshal(uu)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket(usage_bucket).Put(owner, v)
+	if err != nil {
+		return err
+	}
+
+	return meta.put(b)
+}
+
+// checkUsage returns an error if storing size more bytes in a bucket would
+// exceed the quota of the bucket or it's owner. Unlike updateUsage the usage is
+// not changed.
+func (s *ObjectStore) checkUsage(tx *bolt.Tx, b *bolt.Bucket, bucketName string, meta *BucketMeta, size int64) error {
+	bu, err := s.bucketUsage(b, meta)
+	if err != nil {
+		return err
+	}
+
+	// A copy as bu is the bucket's usage in meta
+	u := *bu
+	err = u.add(size, 0, s.authService.BucketQuota(bucketName), "Bucket")
+	if err != nil {
+		return err
+	}
+
+	owner := s.bucketOwner(meta).ID
+	uu, err := s.userUsage(tx, owner)
+	if err != nil {
+		return err
+	}
+
+	quota, err := s.authService.UserQuota(tx, owner)
+	if err != nil {
+		return err
+	}
+
+	return uu.add(size, 0, quota, "User")
+}
+
+// objectUsage returns the size of an existing object & 1, or 0,0 if it does
+// not exist. This is the usage removed when the object is replaced.
+func objectUsage(b *bolt.Bucket, objectName string) (int64, int64) {
+	obj := &Object{}
+	if obj.get(b, objectName) != nil {
+		return 0, 0
+	}
+	return int64(obj.Length), 1
+}
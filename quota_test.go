@@ -0,0 +1,48 @@
+package objectstore
+
+import (
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"testing"
+)
+
+func TestUsage_add(t *testing.T) {
+	quota := auth.Quota{MaxSize: 100, MaxObjects: 2}
+
+	for _, test := range []struct {
+		name    string
+		usage   Usage
+		quota   auth.Quota
+		size    int64
+		objects int64
+		want    Usage
+		code    string
+	}{
+		{name: "unlimited", usage: Usage{1000, 10}, size: 1000, objects: 1, want: Usage{2000, 11}},
+		{name: "within quota", usage: Usage{50, 1}, quota: quota, size: 50, objects: 1, want: Usage{100, 2}},
+		{name: "size exceeded", usage: Usage{50, 1}, quota: quota, size: 51, objects: 0, want: Usage{50, 1}, code: "QuotaExceeded"},
+		{name: "objects exceeded", usage: Usage{50, 2}, quota: quota, size: 1, objects: 1, want: Usage{50, 2}, code: "QuotaExceeded"},
+		// Replacing an object with a larger one only changes the size
+		{name: "replace", usage: Usage{50, 2}, quota: quota, size: 10, want: Usage{60, 2}},
+		// Reducing is allowed even when over quota
+		{name: "reduce over quota", usage: Usage{200, 3}, quota: quota, size: -10, objects: -1, want: Usage{190, 2}},
+		{name: "never negative", usage: Usage{10, 0}, size: -20, objects: -1, want: Usage{0, 0}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			u := test.usage
+			err := u.add(test.size, test.objects, test.quota, "Bucket")
+			switch {
+			case test.code == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case test.code != "" && err == nil:
+				t.Errorf("expected %s", test.code)
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %s expected %s", awserror.ToError(err).Code, test.code)
+			}
+
+			if u != test.want {
+				t.Errorf("usage %+v want %+v", u, test.want)
+			}
+		})
+	}
+}
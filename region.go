@@ -0,0 +1,153 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"net/http"
+	"regexp"
+)
+
+// The region GetBucketLocation reports as an empty LocationConstraint
+const regionUSEast1 = "us-east-1"
+
+var regionPattern = regexp.MustCompile("^[a-zA-Z0-9]+(-[a-zA-Z0-9]+)*$")
+
+// CreateBucketConfiguration is the optional request body of CreateBucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_CreateBucket.html
+type CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+// LocationConstraint is the response of GetBucketLocation
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLocation.html
+type LocationConstraint struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Xmlns    string   `xml:"xmlns,attr,omitempty"`
+	Location string   `xml:",chardata"`
+}
+
+// bucketRegion returns the region of a bucket. Buckets created before we
+// stored the region are in our default region.
+func (s *ObjectStore) bucketRegion(meta *BucketMeta) string {
+	if meta.Region != "" {
+		return meta.Region
+	}
+	return *s.region
+}
+
+// requestRegion returns the region a request must be signed for, which is the
+// region of the bucket being accessed.
+func (s *ObjectStore) requestRegion(r *rest.Rest) string {
+	bucketName := requestBucketName(r)
+	if bucketName == "" {
+		return *s.region
+	}
+
+	if region, exists := s.bucketRegions.Load(bucketName); exists {
+		return region.(string)
+	}
+
+	region := ""
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err == nil {
+			region = s.bucketRegion(meta)
+		}
+		return err
+	})
+	if err == nil {
+		s.bucketRegions.Store(bucketName, region)
+		return region
+	}
+
+	// A new bucket may be created in any of our regions
+	req := r.Request()
+	if req.Method == http.MethodPut && requestObjectName(r) == "" && req.URL.RawQuery == "" {
+		return ""
+	}
+
+	return *s.region
+}
+
+// readCreateBucketConfiguration returns the region requested by CreateBucket
+func (s *ObjectStore) readCreateBucketConfiguration(r *rest.Rest) (string, error) {
+	reader, err := r.BodyReader()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := s.getBody(r.Request().Header, reader)
+	if err != nil {
+		return "", err
+	}
+
+	return s.createBucketRegion(body)
+}
+
+// createBucketRegion returns the region requested by a CreateBucket body.
+// If no region was requested then the bucket is in our default region.
+func (s *ObjectStore) createBucketRegion(body []byte) (string, error) {
+	if len(body) == 0 {
+		return *s.region, nil
+	}
+
+	c := &CreateBucketConfiguration{}
+	err := xml.Unmarshal(body, c)
+	if err != nil {
+		return "", awserror.MalformedXML()
+	}
+
+	if c.LocationConstraint == "" {
+		return *s.region, nil
+	}
+
+	for _, region := range s.regions {
+		if c.LocationConstraint == region {
+			return region, nil
+		}
+	}
+
+	return "", awserror.InvalidLocationConstraint()
+}
+
+// getBucketLocation returns the region of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketLocation.html
+func (s *ObjectStore) getBucketLocation(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	region := ""
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		region = s.bucketRegion(meta)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200).
+		XML().
+		Value(newLocationConstraint(region))
+
+	return nil
+}
+
+// newLocationConstraint returns the GetBucketLocation response for a region
+func newLocationConstraint(region string) *LocationConstraint {
+	// us-east-1 is reported as no constraint
+	if region == regionUSEast1 {
+		region = ""
+	}
+
+	return &LocationConstraint{
+		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
+		Location: region,
+	}
+}
@@ -0,0 +1,78 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/objectstore/awserror"
+	"testing"
+)
+
+func testRegionStore(region string, regions ...string) *ObjectStore {
+	return &ObjectStore{region: &region, regions: append([]string{region}, regions...)}
+}
+
+func TestObjectStore_createBucketRegion(t *testing.T) {
+	s := testRegionStore("eu-west-2", "us-east-1")
+
+	for _, test := range []struct {
+		name string
+		body string
+		want string
+		code string
+	}{
+		{name: "no body", want: "eu-west-2"},
+		{name: "no constraint", body: `<CreateBucketConfiguration/>`, want: "eu-west-2"},
+		{name: "default region", body: `<CreateBucketConfiguration><LocationConstraint>eu-west-2</LocationConstraint></CreateBucketConfiguration>`, want: "eu-west-2"},
+		{name: "other region", body: `<CreateBucketConfiguration><LocationConstraint>us-east-1</LocationConstraint></CreateBucketConfiguration>`, want: "us-east-1"},
+		{name: "unknown region", body: `<CreateBucketConfiguration><LocationConstraint>eu-west-1</LocationConstraint></CreateBucketConfiguration>`, code: "InvalidLocationConstraint"},
+		{name: "case", body: `<CreateBucketConfiguration><LocationConstraint>EU-WEST-2</LocationConstraint></CreateBucketConfiguration>`, code: "InvalidLocationConstraint"},
+		{name: "malformed", body: `<CreateBucketConfiguration>`, code: "MalformedXML"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := s.createBucketRegion([]byte(test.body))
+			switch {
+			case test.code == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case test.code != "" && err == nil:
+				t.Errorf("expected %s", test.code)
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %s expected %s", awserror.ToError(err).Code, test.code)
+			case got != test.want:
+				t.Errorf("got %q want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestObjectStore_bucketRegion(t *testing.T) {
+	s := testRegionStore("eu-west-2")
+
+	if got := s.bucketRegion(&BucketMeta{Region: "us-east-1"}); got != "us-east-1" {
+		t.Errorf("got %q want us-east-1", got)
+	}
+
+	// Buckets created before regions were stored are in the default region
+	if got := s.bucketRegion(&BucketMeta{}); got != "eu-west-2" {
+		t.Errorf("got %q want eu-west-2", got)
+	}
+}
+
+func TestNewLocationConstraint(t *testing.T) {
+	for _, test := range []struct {
+		region string
+		want   string
+	}{
+		{region: "eu-west-2", want: `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">eu-west-2</LocationConstraint>`},
+		// us-east-1 is reported as no constraint
+		{region: "us-east-1", want: `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></LocationConstraint>`},
+	} {
+		t.Run(test.region, func(t *testing.T) {
+			b, err := xml.Marshal(newLocationConstraint(test.region))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(b); got != test.want {
+				t.Errorf("got %s want %s", got, test.want)
+			}
+		})
+	}
+}
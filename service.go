@@ -2,6 +2,7 @@ package objectstore
 
 import (
 	"flag"
+	"fmt"
 	"github.com/peter-mount/go-kernel/v2"
 	"github.com/peter-mount/go-kernel/v2/bolt"
 	"github.com/peter-mount/go-kernel/v2/rest"
@@ -9,6 +10,7 @@ import (
 	"github.com/peter-mount/objectstore/awserror"
 	eventservice "github.com/peter-mount/objectstore/event/service"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -19,7 +21,9 @@ func (s *ObjectStore) Name() string {
 func (s *ObjectStore) Init(k *kernel.Kernel) error {
 
 	s.region = flag.String("region", "", "Region")
-	s.website = flag.Bool("website", false, "Website mode")
+	s.otherRegions = flag.String("regions", "", "Comma separated regions, other than -region, buckets may be created in")
+	s.website = flag.Bool("website", false, "Serve every bucket as a website")
+	s.domain = flag.String("domain", "", "Comma separated base domains for virtual hosted style buckets")
 
 	timeLocation, err := time.LoadLocation("GMT")
 	if err != nil {
@@ -63,9 +67,48 @@ func (s *ObjectStore) PostInit() error {
 		*s.region = "us-east-1"
 	}
 
+	if *s.otherRegions == "" {
+		*s.otherRegions = os.Getenv("REGIONS")
+	}
+	s.regions = []string{*s.region}
+	for _, region := range strings.Split(*s.otherRegions, ",") {
+		if region = strings.TrimSpace(region); region != "" && region != *s.region {
+			s.regions = append(s.regions, region)
+		}
+	}
+	for _, region := range s.regions {
+		if !regionPattern.MatchString(region) {
+			return fmt.Errorf("invalid region %q", region)
+		}
+	}
+
+	// V4 signatures must be for the bucket's region
+	s.authService.SetRegion(s.requestRegion)
+
+	// and for sts when calling the sts api
+	s.authService.SetService(s.requestService)
+
+	if *s.domain == "" {
+		*s.domain = os.Getenv("DOMAIN")
+	}
+	for _, d := range strings.Split(*s.domain, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			s.domains = append(s.domains, d)
+		}
+	}
+
 	// Add a request id to responses
 	s.restService.Use(rest.RequestID(rest.DefaultIDGenerator))
 
+	// Rewrite virtual hosted style requests so they match our routes.
+	// This wraps the router as middleware only runs once a route has matched.
+	if len(s.domains) > 0 {
+		s.restService.Wrap(s.virtualHost)
+	}
+
+	// The admin api, this must be before the S3 routes
+	s.adminRoutes()
+
 	// Note: trailing / required by minio client whilst s3 client doesn't use that
 	// List all buckets
 	builder := s.restService.RestBuilder().
@@ -73,44 +116,173 @@ func (s *ObjectStore) PostInit() error {
 		Decorate(s.authService.AuthenticatorDecorator).
 		Decorate(awserror.RestErrorWrapper).
 		Decorate((&rest.AddHeadersDecorator{
-			"Access-Control-Allow-Origin":  "*",
-			"Access-Control-Allow-Headers": "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Amz-User-Agent, X-Amz-Date, x-amz-meta-from, x-amz-meta-to, x-amz-meta-filename, x-amz-meta-private",
-			"X-Clacks-Overhead":            "GNU Terry Pratchett",
-			"Server":                       "Area51ObjectStore",
-		}).Decorator)
+			"X-Clacks-Overhead": "GNU Terry Pratchett",
+			"Server":            "Area51ObjectStore",
+		}).Decorator).
+		// CORS headers from the bucket's configuration
+		Decorate(s.corsDecorator)
+
+	// CORS preflight requests
+	builder.
+		Method("OPTIONS").
+		Path("/{BucketName}", "/{BucketName}/", "/{BucketName}/{ObjectName:.{1,}}").
+		Handler(s.corsPreflight).
+		Build()
+
+	// The sts api
+	builder.
+		Method("POST").
+		Path("/").
+		Handler(s.sts).
+		Build()
 
 	// Bucket operations
 	builder.
 		// List all buckets
 		Method("GET").
 		Path("/").
-		Handler(s.GetBuckets).
+		Handler(s.authorize(actionListAllMyBuckets, s.GetBuckets)).
+		Build().
+		// Get bucket location
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("location", "").
+		Handler(s.authorize(actionGetBucketLocation, s.getBucketLocation)).
+		Build().
+		// Get bucket object lock configuration
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("object-lock", "").
+		Handler(s.authorize(actionGetBucketObjectLock, s.getObjectLockConfiguration)).
+		Build().
+		// Get bucket ACL
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("acl", "").
+		Handler(s.authorize(actionGetBucketAcl, s.getBucketAcl)).
+		Build().
+		// Put bucket ACL
+		Method("PUT").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("acl", "").
+		Handler(s.authorize(actionPutBucketAcl, s.putBucketAcl)).
+		Build().
+		// Get bucket policy
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("policy", "").
+		Handler(s.authorize(actionGetBucketPolicy, s.getBucketPolicy)).
+		Build().
+		// Put bucket policy
+		Method("PUT").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("policy", "").
+		Handler(s.authorize(actionPutBucketPolicy, s.putBucketPolicy)).
+		Build().
+		// Delete bucket policy
+		Method("DELETE").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("policy", "").
+		Handler(s.authorize(actionDeleteBucketPolicy, s.deleteBucketPolicy)).
+		Build().
+		// Get bucket tags
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("tagging", "").
+		Handler(s.authorize(actionGetBucketTagging, s.getBucketTagging)).
+		Build().
+		// Put bucket tags
+		Method("PUT").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("tagging", "").
+		Handler(s.authorize(actionPutBucketTagging, s.putBucketTagging)).
+		Build().
+		// Delete bucket tags
+		Method("DELETE").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("tagging", "").
+		Handler(s.authorize(actionPutBucketTagging, s.deleteBucketTagging)).
+		Build().
+		// Get bucket website
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("website", "").
+		Handler(s.authorize(actionGetBucketWebsite, s.getBucketWebsite)).
+		Build().
+		// Put bucket website
+		Method("PUT").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("website", "").
+		Handler(s.authorize(actionPutBucketWebsite, s.putBucketWebsite)).
+		Build().
+		// Delete bucket website
+		Method("DELETE").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("website", "").
+		Handler(s.authorize(actionDeleteBucketWebsite, s.deleteBucketWebsite)).
+		Build().
+		// Get bucket CORS
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("cors", "").
+		Handler(s.authorize(actionGetBucketCORS, s.getBucketCors)).
+		Build().
+		// Put bucket CORS
+		Method("PUT").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("cors", "").
+		Handler(s.authorize(actionPutBucketCORS, s.putBucketCors)).
+		Build().
+		// Delete bucket CORS
+		Method("DELETE").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("cors", "").
+		Handler(s.authorize(actionPutBucketCORS, s.deleteBucketCors)).
 		Build().
-		// GetBucket
+		// Get bucket lifecycle
 		Method("GET").
 		Path("/{BucketName}", "/{BucketName}/").
-		Handler(s.GetBucket).
+		Queries("lifecycle", "").
+		Handler(s.authorize(actionGetLifecycleConfiguration, s.getBucketLifecycle)).
+		Build().
+		// Put bucket lifecycle
+		Method("PUT").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("lifecycle", "").
+		Handler(s.authorize(actionPutLifecycleConfiguration, s.putBucketLifecycle)).
+		Build().
+		// Delete bucket lifecycle
+		Method("DELETE").
+		Path("/{BucketName}", "/{BucketName}/").
+		Queries("lifecycle", "").
+		Handler(s.authorize(actionPutLifecycleConfiguration, s.deleteBucketLifecycle)).
+		Build().
+		// GetBucket, or for website requests the bucket's index page
+		Method("GET").
+		Path("/{BucketName}", "/{BucketName}/").
+		Handler(s.websiteHandler(actionListBucket, s.GetBucket)).
 		Build().
 		// Check existence of bucket
 		Method("HEAD").
 		Path("/{BucketName}", "/{BucketName}/").
-		Handler(s.HeadBucket).
+		Handler(s.websiteHandler(actionListBucket, s.HeadBucket)).
 		Build().
 		// Create bucket
 		Method("PUT").
 		Path("/{BucketName}", "/{BucketName}/").
-		Handler(s.CreateBucket).
+		Handler(s.authorize(actionCreateBucket, s.CreateBucket)).
 		Build().
 		// Delete Bucket
 		Method("DELETE").
 		Path("/{BucketName}", "/{BucketName}/").
-		Handler(s.DeleteBucket).
+		Handler(s.authorize(actionDeleteBucket, s.DeleteBucket)).
 		Build()
 
-	// Browser upload
+	// Browser upload, this authorizes the request itself as the credentials
+	// are in the form
 	builder.
 		Method("POST").
-		Path("/{BucketName}/").
+		Path("/{BucketName}", "/{BucketName}/").
 		Handler(s.CreateObjectBrowserUpload).
 		Build()
 
@@ -120,7 +292,7 @@ func (s *ObjectStore) PostInit() error {
 		Method("POST").
 		Path("/{BucketName}/{ObjectName:.{1,}}").
 		Queries("uploads", "").
-		Handler(s.initiateMultipart).
+		Handler(s.authorize(actionPutObject, s.initiateMultipart)).
 		Build().
 		// uploadPart
 		Method("PUT").
@@ -129,19 +301,55 @@ func (s *ObjectStore) PostInit() error {
 			"partNumber", "{PartNumber}",
 			"uploadId", "{UploadId}",
 		).
-		Handler(s.uploadPart).
+		Handler(s.authorize(actionPutObject, s.uploadPart)).
 		Build().
 		// completeMultipart
 		Method("POST").
 		Path("/{BucketName}/{ObjectName:.{1,}}").
 		Queries("uploadId", "{UploadId}").
-		Handler(s.completeMultipart).
+		Handler(s.authorize(actionPutObject, s.completeMultipart)).
 		Build().
 		// abortMultipart
 		Method("DELETE").
 		Path("/{BucketName}/{ObjectName:.{1,}}").
 		Queries("uploadId", "{UploadId}").
-		Handler(s.abortMultipart).
+		Handler(s.authorize(actionAbortMultipartUpload, s.abortMultipart)).
+		Build()
+
+	// Object ACL's
+	builder.
+		// Get Object ACL
+		Method("GET").
+		Path("/{BucketName}/{ObjectName:.{1,}}").
+		Queries("acl", "").
+		Handler(s.authorize(actionGetObjectAcl, s.getObjectAcl)).
+		Build().
+		// Put Object ACL
+		Method("PUT").
+		Path("/{BucketName}/{ObjectName:.{1,}}").
+		Queries("acl", "").
+		Handler(s.authorize(actionPutObjectAcl, s.putObjectAcl)).
+		Build()
+
+	// Object tagging
+	builder.
+		// Get object tags
+		Method("GET").
+		Path("/{BucketName}/{ObjectName:.{1,}}").
+		Queries("tagging", "").
+		Handler(s.authorize(actionGetObjectTagging, s.getObjectTagging)).
+		Build().
+		// Put object tags
+		Method("PUT").
+		Path("/{BucketName}/{ObjectName:.{1,}}").
+		Queries("tagging", "").
+		Handler(s.authorize(actionPutObjectTagging, s.putObjectTagging)).
+		Build().
+		// Delete object tags
+		Method("DELETE").
+		Path("/{BucketName}/{ObjectName:.{1,}}").
+		Queries("tagging", "").
+		Handler(s.authorize(actionDeleteObjectTagging, s.deleteObjectTagging)).
 		Build()
 
 	builder.
@@ -149,43 +357,24 @@ func (s *ObjectStore) PostInit() error {
 		Method("PUT").
 		Path("/{DestBucketName}/{DestObjectName:.{1,}}").
 		Headers("X-Amz-Copy-Source", "").
-		Handler(s.copyObject).
+		Handler(s.authorize(actionPutObject, s.copyObject)).
 		Build().
 		// Object upload - non multipart
 		Method("PUT").
 		Path("/{BucketName}/{ObjectName:.{1,}}").
-		Handler(s.uploadObject).
+		Handler(s.authorize(actionPutObject, s.uploadObject)).
 		Build().
 		// Post new object
 		Method("POST").
 		Path("/{BucketName}/{ObjectName:.{0,}}").
-		Handler(s.uploadObject).
-		Build().
-		// Post new object - Browser upload
-		Method("POST").
-		Path("/{BucketName}/").
-		Handler(s.CreateObjectBrowserUpload).
+		Handler(s.authorize(actionPutObject, s.uploadObject)).
 		Build()
 
 	// Check object exists
 	builder.
 		Method("HEAD").
 		Path("/{BucketName}/{ObjectName:.{0,}}").
-		Handler(s.HeadObject).
-		Build()
-
-	builder.
-		// Get Object ACL
-		Method("GET").
-		Path("/{BucketName}/{ObjectName:.{1,}}").
-		Queries("acl", "").
-		Handler(s.getObjectAcl).
-		Build().
-		// Put Object ACL
-		Method("PUT").
-		Path("/{BucketName}/{ObjectName:.{1,}}").
-		Queries("acl", "").
-		Handler(s.putObjectAcl).
+		Handler(s.websiteHandler(actionGetObject, s.HeadObject)).
 		Build()
 
 	// Get object
@@ -193,19 +382,31 @@ func (s *ObjectStore) PostInit() error {
 		// Get object
 		Method("GET").
 		Path("/{BucketName}/{ObjectName:.{1,}}").
-		Handler(s.GetObject).
+		Handler(s.websiteHandler(actionGetObject, s.GetObject)).
 		Build()
 
 	// Delete object
 	builder.
 		Method("DELETE").
 		Path("/{BucketName}/{ObjectName:.{1,}}").
-		Handler(s.DeleteObject).
+		Handler(s.authorize(actionDeleteObject, s.DeleteObject)).
 		Build()
 
 	return nil
 }
 
+// Start deletes objects expired by the buckets' lifecycle rules
+func (s *ObjectStore) Start() error {
+	s.stopLifecycle = s.startLifecycle()
+	return nil
+}
+
+func (s *ObjectStore) Stop() {
+	if s.stopLifecycle != nil {
+		s.stopLifecycle()
+	}
+}
+
 func (s *ObjectStore) timeNow() time.Time {
 	return time.Now().In(s.timeLocation)
 }
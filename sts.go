@@ -0,0 +1,217 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// The namespace of sts responses
+const stsXmlns = "https://sts.amazonaws.com/doc/2011-06-15/"
+
+// STSCredentials are the temporary credentials returned by sts
+type STSCredentials struct {
+	AccessKeyId     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+// AssumedRoleUser identifies the session returned by AssumeRole
+type AssumedRoleUser struct {
+	AssumedRoleId string `xml:"AssumedRoleId"`
+	Arn           string `xml:"Arn"`
+}
+
+// AssumeRoleResponse is the response of AssumeRole
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRole.html
+type AssumeRoleResponse struct {
+	XMLName xml.Name `xml:"AssumeRoleResponse"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Result  struct {
+		Credentials     STSCredentials  `xml:"Credentials"`
+		AssumedRoleUser AssumedRoleUser `xml:"AssumedRoleUser"`
+	} `xml:"AssumeRoleResult"`
+}
+
+// AssumeRoleWithWebIdentityResponse is the response of AssumeRoleWithWebIdentity
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_AssumeRoleWithWebIdentity.html
+type AssumeRoleWithWebIdentityResponse struct {
+	XMLName xml.Name `xml:"AssumeRoleWithWebIdentityResponse"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Result  struct {
+		Credentials                 STSCredentials  `xml:"Credentials"`
+		SubjectFromWebIdentityToken string          `xml:"SubjectFromWebIdentityToken"`
+		AssumedRoleUser             AssumedRoleUser `xml:"AssumedRoleUser"`
+		Provider                    string          `xml:"Provider"`
+		Audience                    string          `xml:"Audience"`
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+// GetSessionTokenResponse is the response of GetSessionToken
+// https://docs.aws.amazon.com/STS/latest/APIReference/API_GetSessionToken.html
+type GetSessionTokenResponse struct {
+	XMLName xml.Name `xml:"GetSessionTokenResponse"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Result  struct {
+		Credentials STSCredentials `xml:"Credentials"`
+	} `xml:"GetSessionTokenResult"`
+}
+
+func newSTSCredentials(session *auth.Session) STSCredentials {
+	return STSCredentials{
+		AccessKeyId:     session.AccessKey,
+		SecretAccessKey: session.SecretKey,
+		SessionToken:    session.SessionToken,
+		Expiration:      session.Expiration.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+// isSTSRequest returns true if a request is for sts. These are form POSTs to /
+func isSTSRequest(r *rest.Rest) bool {
+	req := r.Request()
+	return req.Method == http.MethodPost && req.URL.Path == "/"
+}
+
+// requestService returns the service a request must be signed for
+func (s *ObjectStore) requestService(r *rest.Rest) string {
+	if isSTSRequest(r) {
+		return auth.ServiceSTS
+	}
+	return auth.ServiceS3
+}
+
+// sts handles the sts api, the action is a form parameter
+func (s *ObjectStore) sts(r *rest.Rest) error {
+	req := r.Request()
+	if err := req.ParseForm(); err != nil {
+		return awserror.InvalidArgument("%s", err.Error())
+	}
+
+	switch action := req.Form.Get("Action"); action {
+	case "AssumeRole":
+		return s.assumeRole(r, req.Form)
+	case "AssumeRoleWithWebIdentity":
+		return s.assumeRoleWithWebIdentity(r, req.Form)
+	case "GetSessionToken":
+		return s.getSessionToken(r, req.Form)
+	default:
+		return awserror.InvalidAction(action)
+	}
+}
+
+// durationSeconds returns the DurationSeconds parameter, 0 if not set
+func durationSeconds(form url.Values) (time.Duration, error) {
+	v := form.Get("DurationSeconds")
+	if v == "" {
+		return 0, nil
+	}
+
+	d, err := strconv.Atoi(v)
+	if err != nil || d <= 0 {
+		return 0, awserror.ValidationError("Invalid DurationSeconds %q", v)
+	}
+
+	return time.Duration(d) * time.Second, nil
+}
+
+func (s *ObjectStore) assumeRole(r *rest.Rest, form url.Values) error {
+	duration, err := durationSeconds(form)
+	if err != nil {
+		return err
+	}
+
+	cred := auth.RequestCredential(r)
+	session, err := s.authService.AssumeRole(
+		cred,
+		s.requestContext(r, cred),
+		form.Get("RoleArn"),
+		form.Get("RoleSessionName"),
+		duration,
+		form.Get("Policy"),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp := &AssumeRoleResponse{Xmlns: stsXmlns}
+	resp.Result.Credentials = newSTSCredentials(session)
+	resp.Result.AssumedRoleUser = AssumedRoleUser{
+		AssumedRoleId: session.AssumedRoleId,
+		Arn:           session.AssumedRoleArn,
+	}
+
+	r.Status(200).
+		XML().
+		Value(resp)
+
+	return nil
+}
+
+// assumeRoleWithWebIdentity does not need the request to be signed, the
+// caller is identified by their web identity token
+func (s *ObjectStore) assumeRoleWithWebIdentity(r *rest.Rest, form url.Values) error {
+	duration, err := durationSeconds(form)
+	if err != nil {
+		return err
+	}
+
+	token := form.Get("WebIdentityToken")
+	if token == "" {
+		return awserror.ValidationError("WebIdentityToken is required")
+	}
+
+	session, err := s.authService.AssumeRoleWithWebIdentity(
+		s.requestContext(r, auth.RequestCredential(r)),
+		form.Get("RoleArn"),
+		form.Get("RoleSessionName"),
+		token,
+		duration,
+		form.Get("Policy"),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp := &AssumeRoleWithWebIdentityResponse{Xmlns: stsXmlns}
+	resp.Result.Credentials = newSTSCredentials(session)
+	resp.Result.SubjectFromWebIdentityToken = session.Subject
+	resp.Result.AssumedRoleUser = AssumedRoleUser{
+		AssumedRoleId: session.AssumedRoleId,
+		Arn:           session.AssumedRoleArn,
+	}
+	resp.Result.Provider = session.Issuer
+	resp.Result.Audience = session.Audience
+
+	r.Status(200).
+		XML().
+		Value(resp)
+
+	return nil
+}
+
+func (s *ObjectStore) getSessionToken(r *rest.Rest, form url.Values) error {
+	duration, err := durationSeconds(form)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.authService.GetSessionToken(auth.RequestCredential(r), duration)
+	if err != nil {
+		return err
+	}
+
+	resp := &GetSessionTokenResponse{Xmlns: stsXmlns}
+	resp.Result.Credentials = newSTSCredentials(session)
+
+	r.Status(200).
+		XML().
+		Value(resp)
+
+	return nil
+}
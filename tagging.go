@@ -0,0 +1,287 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/awserror"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Maximum number of tags allowed on an object
+	maxObjectTags = 10
+	// Maximum number of tags allowed on a bucket
+	maxBucketTags = 50
+	// Maximum length of a tag key
+	maxTagKeyLength = 128
+	// Maximum length of a tag value
+	maxTagValueLength = 256
+)
+
+// Tagging is the request/response body used by the ?tagging sub-resource
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_Tagging.html
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// newTagging returns a Tagging for a map of tags, sorted by key so the
+// response is consistent between calls
+func newTagging(tags map[string]string) *Tagging {
+	t := &Tagging{
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		TagSet: []Tag{},
+	}
+
+	for k, v := range tags {
+		t.TagSet = append(t.TagSet, Tag{k, v})
+	}
+
+	sort.SliceStable(t.TagSet, func(i, j int) bool {
+		return t.TagSet[i].Key < t.TagSet[j].Key
+	})
+
+	return t
+}
+
+// tags converts the TagSet into a map validating each tag.
+// max is the maximum number of tags permitted.
+func (t *Tagging) tags(max int) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, tag := range t.TagSet {
+		if _, exists := tags[tag.Key]; exists {
+			return nil, awserror.InvalidTag("Cannot provide multiple Tags with the same key")
+		}
+		if err := validateTag(tag.Key, tag.Value); err != nil {
+			return nil, err
+		}
+		tags[tag.Key] = tag.Value
+	}
+
+	if len(tags) > max {
+		return nil, awserror.InvalidTag("Tag count cannot be greater than %d", max)
+	}
+
+	return tags, nil
+}
+
+// validateTag checks a tag's key and value are valid
+func validateTag(k, v string) error {
+	kl := utf8.RuneCountInString(k)
+	if kl == 0 || kl > maxTagKeyLength {
+		return awserror.InvalidTag("The TagKey you have provided is invalid")
+	}
+
+	if strings.HasPrefix(strings.ToLower(k), "aws:") {
+		return awserror.InvalidTag("Your TagKey cannot be prefixed with aws:")
+	}
+
+	if utf8.RuneCountInString(v) > maxTagValueLength {
+		return awserror.InvalidTag("The TagValue you have provided is invalid")
+	}
+
+	return nil
+}
+
+// parseTaggingHeader parses the x-amz-tagging header.
+// This is in the form of url query parameters, e.g. "Key1=Value1&Key2=Value2"
+// Returns nil if the header is not present.
+func parseTaggingHeader(headers map[string][]string) (map[string]string, error) {
+	h, ok := headers["X-Amz-Tagging"]
+	if !ok || len(h) == 0 {
+		return nil, nil
+	}
+
+	q, err := url.ParseQuery(h[0])
+	if err != nil {
+		return nil, awserror.InvalidArgument("The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+
+	t := &Tagging{}
+	for k, v := range q {
+		for _, e := range v {
+			t.TagSet = append(t.TagSet, Tag{k, e})
+		}
+	}
+
+	return t.tags(maxObjectTags)
+}
+
+// readTagging reads a Tagging document from the request body
+func (s *ObjectStore) readTagging(r *rest.Rest, max int) (map[string]string, error) {
+	reader, err := r.BodyReader()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.getBody(r.Request().Header, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tagging{}
+	err = xml.Unmarshal(body, t)
+	if err != nil {
+		return nil, awserror.MalformedXML()
+	}
+
+	return t.tags(max)
+}
+
+// getObjectTagging returns the tags on an object
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObjectTagging.html
+func (s *ObjectStore) getObjectTagging(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+	objectName := r.Var("ObjectName")
+
+	obj := &Object{}
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		b, err := s.getBucket(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		return obj.get(b, objectName)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200).
+		XML().
+		Value(newTagging(obj.Tags))
+
+	return nil
+}
+
+// putObjectTagging replaces the tags on an object
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObjectTagging.html
+func (s *ObjectStore) putObjectTagging(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+	objectName := r.Var("ObjectName")
+
+	tags, err := s.readTagging(r, maxObjectTags)
+	if err != nil {
+		return err
+	}
+
+	return s.updateObjectTags(r, "ObjectTagging:Put", bucketName, objectName, tags, 200)
+}
+
+// deleteObjectTagging removes all tags from an object
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteObjectTagging.html
+func (s *ObjectStore) deleteObjectTagging(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+	objectName := r.Var("ObjectName")
+
+	return s.updateObjectTags(r, "ObjectTagging:Delete", bucketName, objectName, nil, 204)
+}
+
+// updateObjectTags replaces the tags on an existing object
+func (s *ObjectStore) updateObjectTags(r *rest.Rest, eventName, bucketName, objectName string, tags map[string]string, status int) error {
+	obj := &Object{}
+	err := s.boltService.Update(func(tx *bolt.Tx) error {
+		b, err := s.getBucket(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		err = obj.get(b, objectName)
+		if err != nil {
+			return err
+		}
+
+		obj.Tags = tags
+		return obj.put(b)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendObjectEvent(eventName, bucketName, obj)
+
+	r.Status(status)
+
+	return nil
+}
+
+// getBucketTagging returns the tags on a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketTagging.html
+func (s *ObjectStore) getBucketTagging(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	var tags map[string]string
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		tags = meta.Tags
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		return awserror.NoSuchTagSet()
+	}
+
+	r.Status(200).
+		XML().
+		Value(newTagging(tags))
+
+	return nil
+}
+
+// putBucketTagging replaces the tags on a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketTagging.html
+func (s *ObjectStore) putBucketTagging(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	tags, err := s.readTagging(r, maxBucketTags)
+	if err != nil {
+		return err
+	}
+
+	err = s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Tags = tags
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(204)
+
+	return nil
+}
+
+// deleteBucketTagging removes all tags from a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketTagging.html
+func (s *ObjectStore) deleteBucketTagging(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	err := s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Tags = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(204)
+
+	return nil
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"errors"
 	"github.com/peter-mount/go-kernel/v2"
 	"github.com/peter-mount/objectstore"
+	"github.com/peter-mount/objectstore/auth"
 	"log"
 )
 
 func main() {
 	err := kernel.Launch(&kernel.MemUsage{}, &objectstore.ObjectStore{})
-	if err != nil {
+	if err != nil && !errors.Is(err, auth.ErrConfigChecked) {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,59 @@
+package utils
+
+import (
+  "os"
+  "os/signal"
+  "strconv"
+  "syscall"
+  "time"
+)
+
+// How often WatchFile checks if the file has changed
+const WatchInterval = 5 * time.Second
+
+// WatchFile calls reload when the process receives SIGHUP or when the file is
+// modified on disk, checking every interval.
+// The returned function stops watching.
+func WatchFile( filename string, interval time.Duration, reload func() ) func() {
+  hup := make( chan os.Signal, 1 )
+  signal.Notify( hup, syscall.SIGHUP )
+
+  stop := make( chan struct{} )
+  ticker := time.NewTicker( interval )
+
+  go func() {
+    defer ticker.Stop()
+    defer signal.Stop( hup )
+
+    last := fileVersion( filename )
+    for {
+      select {
+        case <-stop:
+          return
+        case <-hup:
+          last = fileVersion( filename )
+          reload()
+        case <-ticker.C:
+          // Only reload once the file exists again if it's being replaced
+          if v := fileVersion( filename ); v != last && v != "" {
+            last = v
+            reload()
+          }
+      }
+    }
+  }()
+
+  return func() {
+    close( stop )
+  }
+}
+
+// fileVersion returns a string which changes when the file is modified,
+// "" if the file does not exist
+func fileVersion( filename string ) string {
+  fi, err := os.Stat( filename )
+  if err != nil {
+    return ""
+  }
+  return fi.ModTime().String() + "/" + strconv.FormatInt( fi.Size(), 10 )
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+  "io/ioutil"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func TestWatchFile( t *testing.T ) {
+  dir, err := ioutil.TempDir( "", "watch" )
+  if err != nil {
+    t.Fatal( err )
+  }
+  defer os.RemoveAll( dir )
+
+  filename := filepath.Join( dir, "config.yaml" )
+  if err := ioutil.WriteFile( filename, []byte( "a: 1\n" ), 0644 ); err != nil {
+    t.Fatal( err )
+  }
+
+  reloaded := make( chan bool, 10 )
+  stop := WatchFile( filename, 10 * time.Millisecond, func() {
+    reloaded <- true
+  } )
+  defer stop()
+
+  // Nothing changed so no reload
+  select {
+    case <-reloaded:
+      t.Fatal( "Reloaded when file not changed" )
+    case <-time.After( 50 * time.Millisecond ):
+  }
+
+  if err := ioutil.WriteFile( filename, []byte( "a: 12\n" ), 0644 ); err != nil {
+    t.Fatal( err )
+  }
+
+  select {
+    case <-reloaded:
+    case <-time.After( time.Second ):
+      t.Fatal( "Not reloaded when file changed" )
+  }
+}
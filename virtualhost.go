@@ -0,0 +1,51 @@
+package objectstore
+
+import (
+	"github.com/peter-mount/objectstore/auth"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// virtualHostBucket returns the bucket name if host is <bucket>.<domain> for
+// one of our domains, otherwise ""
+func (s *ObjectStore) virtualHostBucket(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	for _, d := range s.domains {
+		if strings.HasSuffix(host, "."+d) {
+			return host[:len(host)-len(d)-1]
+		}
+	}
+	return ""
+}
+
+// virtualHost wraps the router, rewriting virtual hosted style requests,
+// where the bucket name is in the Host header, into path style requests
+// before they are routed.
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/VirtualHosting.html
+func (s *ObjectStore) virtualHost(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if bucketName := s.virtualHostBucket(req.Host); bucketName != "" {
+			// V4 signatures are of the path as sent
+			req = auth.WithOriginalPath(req, req.URL.Path)
+
+			path := req.URL.Path
+			if path == "" {
+				path = "/"
+			}
+
+			u := *req.URL
+			u.Path = "/" + bucketName + path
+			if u.RawPath != "" {
+				u.RawPath = "/" + bucketName + u.RawPath
+			}
+			req.URL = &u
+		}
+
+		h.ServeHTTP(w, req)
+	})
+}
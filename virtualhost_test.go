@@ -0,0 +1,95 @@
+package objectstore
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/peter-mount/objectstore/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestObjectStore_virtualHostBucket(t *testing.T) {
+	s := &ObjectStore{domains: []string{"example.com"}}
+
+	tests := []struct {
+		host   string
+		bucket string
+	}{
+		{host: "bucket.example.com", bucket: "bucket"},
+		{host: "bucket.example.com:8080", bucket: "bucket"},
+		{host: "Bucket.Example.COM", bucket: "bucket"},
+		{host: "my.bucket.example.com", bucket: "my.bucket"},
+		{host: "example.com"},
+		{host: "bucket.example.org"},
+		{host: "bucketexample.com"},
+		{host: "localhost:8080"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.host, func(t *testing.T) {
+			if got := s.virtualHostBucket(test.host); got != test.bucket {
+				t.Errorf("got %q want %q", got, test.bucket)
+			}
+		})
+	}
+}
+
+func TestObjectStore_virtualHost(t *testing.T) {
+	s := &ObjectStore{domains: []string{"example.com"}}
+
+	// The route shapes of the S3 api
+	router := mux.NewRouter()
+	var route, bucketName, key, canonicalPath string
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, req *http.Request) {
+			route = name
+			bucketName = mux.Vars(req)["BucketName"]
+			key = mux.Vars(req)["ObjectName"]
+			canonicalPath = auth.OriginalPath(req)
+		}
+	}
+	router.Handle("/", handler("ListBuckets"))
+	router.Handle("/{BucketName}", handler("Bucket"))
+	router.Handle("/{BucketName}/", handler("Bucket"))
+	router.Handle("/{BucketName}/{ObjectName:.{1,}}", handler("Object"))
+
+	server := s.virtualHost(router)
+
+	tests := []struct {
+		url           string
+		route         string
+		bucketName    string
+		key           string
+		canonicalPath string
+	}{
+		{url: "http://bucket.example.com/key", route: "Object", bucketName: "bucket", key: "key", canonicalPath: "/key"},
+		{url: "http://bucket.example.com/dir/key", route: "Object", bucketName: "bucket", key: "dir/key", canonicalPath: "/dir/key"},
+		{url: "http://bucket.example.com/", route: "Bucket", bucketName: "bucket", canonicalPath: "/"},
+		{url: "http://bucket.example.com:8080/key", route: "Object", bucketName: "bucket", key: "key", canonicalPath: "/key"},
+		// Path style requests are unchanged
+		{url: "http://example.com/bucket/key", route: "Object", bucketName: "bucket", key: "key", canonicalPath: "/bucket/key"},
+		{url: "http://example.com/bucket", route: "Bucket", bucketName: "bucket", canonicalPath: "/bucket"},
+		{url: "http://example.com/", route: "ListBuckets", canonicalPath: "/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			route, bucketName, key, canonicalPath = "", "", "", ""
+
+			server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, test.url, nil))
+
+			if route != test.route {
+				t.Errorf("route got %q want %q", route, test.route)
+			}
+			if bucketName != test.bucketName {
+				t.Errorf("BucketName got %q want %q", bucketName, test.bucketName)
+			}
+			if key != test.key {
+				t.Errorf("ObjectName got %q want %q", key, test.key)
+			}
+			if canonicalPath != test.canonicalPath {
+				t.Errorf("canonical path got %q want %q", canonicalPath, test.canonicalPath)
+			}
+		})
+	}
+}
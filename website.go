@@ -0,0 +1,497 @@
+package objectstore
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/peter-mount/go-kernel/v2/bolt"
+	"github.com/peter-mount/go-kernel/v2/rest"
+	"github.com/peter-mount/objectstore/auth"
+	"github.com/peter-mount/objectstore/awserror"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The index document used when a bucket has no website configuration
+	defaultIndexDocument = "index.html"
+	// Maximum number of routing rules in a website configuration
+	maxRoutingRules = 50
+	// The object metadata holding an object's redirect
+	websiteRedirectLocation = "X-Amz-Website-Redirect-Location"
+)
+
+// WebsiteConfiguration is the request/response body used by the ?website sub-resource
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration" bson:"-"`
+	Xmlns                 string                 `xml:"xmlns,attr,omitempty" bson:"-"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RoutingRules          []RoutingRule          `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+type RoutingRule struct {
+	Condition *RoutingRuleCondition `xml:"Condition,omitempty"`
+	Redirect  RoutingRuleRedirect   `xml:"Redirect"`
+}
+
+type RoutingRuleCondition struct {
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+}
+
+type RoutingRuleRedirect struct {
+	HostName             string  `xml:"HostName,omitempty"`
+	HttpRedirectCode     string  `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string  `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith,omitempty"`
+	ReplaceKeyWith       string  `xml:"ReplaceKeyWith,omitempty"`
+}
+
+// validate checks a website configuration is valid
+func (c *WebsiteConfiguration) validate() error {
+	if c.RedirectAllRequestsTo != nil {
+		if c.IndexDocument != nil || c.ErrorDocument != nil || len(c.RoutingRules) > 0 {
+			return awserror.InvalidArgument("RedirectAllRequestsTo cannot be provided in conjunction with other Routing Rules.")
+		}
+		if c.RedirectAllRequestsTo.HostName == "" {
+			return awserror.InvalidArgument("A host name must be provided in RedirectAllRequestsTo")
+		}
+		return validProtocol(c.RedirectAllRequestsTo.Protocol)
+	}
+
+	if c.IndexDocument == nil {
+		return awserror.InvalidArgument("A value for IndexDocument Suffix must be provided if RedirectAllRequestsTo is empty")
+	}
+	if c.IndexDocument.Suffix == "" || strings.Contains(c.IndexDocument.Suffix, "/") {
+		return awserror.InvalidArgument("The IndexDocument Suffix is not well formed")
+	}
+
+	if c.ErrorDocument != nil && c.ErrorDocument.Key == "" {
+		return awserror.InvalidArgument("The ErrorDocument Key is not well formed")
+	}
+
+	if len(c.RoutingRules) > maxRoutingRules {
+		return awserror.InvalidArgument("Routing rules cannot exceed %d", maxRoutingRules)
+	}
+
+	for _, rule := range c.RoutingRules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rule *RoutingRule) validate() error {
+	if c := rule.Condition; c != nil {
+		if c.HttpErrorCodeReturnedEquals == "" && c.KeyPrefixEquals == "" {
+			return awserror.InvalidArgument("Condition cannot be empty. To redirect all requests without a condition, the condition element shouldn't be present.")
+		}
+		if c.HttpErrorCodeReturnedEquals != "" {
+			code, err := strconv.Atoi(c.HttpErrorCodeReturnedEquals)
+			if err != nil || code < 400 || code > 599 {
+				return awserror.InvalidArgument("The provided HTTP error code (%s) is not valid. Valid codes are 4XX or 5XX.", c.HttpErrorCodeReturnedEquals)
+			}
+		}
+	}
+
+	d := rule.Redirect
+	if d.ReplaceKeyWith != "" && d.ReplaceKeyPrefixWith != nil {
+		return awserror.InvalidArgument("You can only define ReplaceKeyPrefix or ReplaceKey but not both.")
+	}
+
+	if d.HttpRedirectCode != "" {
+		code, err := strconv.Atoi(d.HttpRedirectCode)
+		if err != nil || code < 300 || code > 399 {
+			return awserror.InvalidArgument("The provided HTTP redirect code (%s) is not valid. Valid codes are 3XX except 300.", d.HttpRedirectCode)
+		}
+	}
+
+	return validProtocol(d.Protocol)
+}
+
+func validProtocol(p string) error {
+	if p == "" || p == "http" || p == "https" {
+		return nil
+	}
+	return awserror.InvalidArgument("Invalid protocol, protocol can be http or https. If not defined the protocol will be selected automatically.")
+}
+
+// validRedirectLocation returns true if v is a valid x-amz-website-redirect-location
+func validRedirectLocation(v string) bool {
+	return strings.HasPrefix(v, "/") || strings.HasPrefix(v, "http://") || strings.HasPrefix(v, "https://")
+}
+
+// matches returns true if a routing rule applies to a key.
+// status is the http status of the request or 0 before the object has been looked up.
+func (rule *RoutingRule) matches(key string, status int) bool {
+	c := rule.Condition
+	if c == nil {
+		return status == 0
+	}
+
+	if c.HttpErrorCodeReturnedEquals == "" {
+		if status != 0 {
+			return false
+		}
+	} else if c.HttpErrorCodeReturnedEquals != strconv.Itoa(status) {
+		return false
+	}
+
+	return strings.HasPrefix(key, c.KeyPrefixEquals)
+}
+
+// getBucketWebsite returns the website configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetBucketWebsite.html
+func (s *ObjectStore) getBucketWebsite(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	var c *WebsiteConfiguration
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		c = meta.Website
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if c == nil {
+		return awserror.NoSuchWebsiteConfiguration()
+	}
+
+	c.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	r.Status(200).
+		XML().
+		Value(c)
+
+	return nil
+}
+
+// putBucketWebsite validates and stores the website configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketWebsite.html
+func (s *ObjectStore) putBucketWebsite(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	reader, err := r.BodyReader()
+	if err != nil {
+		return err
+	}
+
+	body, err := s.getBody(r.Request().Header, reader)
+	if err != nil {
+		return err
+	}
+
+	c := &WebsiteConfiguration{}
+	err = xml.Unmarshal(body, c)
+	if err != nil {
+		return awserror.MalformedXML()
+	}
+
+	err = c.validate()
+	if err != nil {
+		return err
+	}
+
+	err = s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Website = c
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(200)
+
+	return nil
+}
+
+// deleteBucketWebsite removes the website configuration of a bucket
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_DeleteBucketWebsite.html
+func (s *ObjectStore) deleteBucketWebsite(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+
+	err := s.updateBucketMeta(bucketName, func(meta *BucketMeta) error {
+		meta.Website = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status(204)
+
+	return nil
+}
+
+// websiteHandler returns the handler for GET & HEAD requests of buckets and
+// objects. Website requests are served as a static website, otherwise the
+// request is authorized and passed to h.
+func (s *ObjectStore) websiteHandler(action *s3Action, h rest.RestHandler) rest.RestHandler {
+	api := s.authorize(action, h)
+	return func(r *rest.Rest) error {
+		if s.isWebsiteRequest(r) {
+			return s.serveWebsite(r)
+		}
+		return api(r)
+	}
+}
+
+// isWebsiteRequest returns true if a request is for a bucket's website rather
+// than the api. In website mode every request is, otherwise anonymous requests
+// to a bucket with a website configuration are as browsers don't sign requests.
+func (s *ObjectStore) isWebsiteRequest(r *rest.Rest) bool {
+	if *s.website {
+		return true
+	}
+
+	if auth.RequestCredential(r).IsAuthenticated() {
+		return false
+	}
+
+	website := false
+	_ = s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, r.Var("BucketName"))
+		website = err == nil && meta.Website != nil
+		return nil
+	})
+	return website
+}
+
+// serveWebsite serves a bucket as a static website.
+// Errors are sent as html pages rather than the xml used by the api.
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/WebsiteHosting.html
+func (s *ObjectStore) serveWebsite(r *rest.Rest) error {
+	bucketName := r.Var("BucketName")
+	key := r.Var("ObjectName")
+
+	// Buckets without a configuration have a default index document
+	c := &WebsiteConfiguration{IndexDocument: &IndexDocument{Suffix: defaultIndexDocument}}
+	err := s.boltService.View(func(tx *bolt.Tx) error {
+		_, meta, err := s.getBucketMeta(tx, bucketName)
+		if err == nil && meta.Website != nil {
+			c = meta.Website
+		}
+		return err
+	})
+	if err != nil {
+		return s.websiteError(r, c, bucketName, key, err)
+	}
+
+	if a := c.RedirectAllRequestsTo; a != nil {
+		s.websiteRedirect(r, &websiteRedirectTo{http.StatusMovedPermanently, a.Protocol, a.HostName, key}, bucketName)
+		return nil
+	}
+
+	if to := c.redirect(key, 0); to != nil {
+		s.websiteRedirect(r, to, bucketName)
+		return nil
+	}
+
+	key = c.indexKey(key)
+
+	err = s.serveWebsiteObject(r, c, bucketName, key)
+	if err != nil {
+		return s.websiteError(r, c, bucketName, key, err)
+	}
+
+	return nil
+}
+
+// indexKey returns the key of the index document if key is the root or a
+// "folder", otherwise key
+func (c *WebsiteConfiguration) indexKey(key string) string {
+	if key == "" || strings.HasSuffix(key, "/") {
+		return key + c.IndexDocument.Suffix
+	}
+	return key
+}
+
+// serveWebsiteObject sends an object as part of a website, following the
+// object's redirect if it has one
+func (s *ObjectStore) serveWebsiteObject(r *rest.Rest, c *WebsiteConfiguration, bucketName, key string) error {
+	err := s.authorizeRequest(r, actionGetObject, bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	obj := &Object{}
+	found := false
+	err = s.boltService.View(func(tx *bolt.Tx) error {
+		b, err := s.getBucket(tx, bucketName)
+		if err != nil {
+			return err
+		}
+
+		err = obj.get(b, key)
+		if err == nil {
+			found = true
+			return nil
+		}
+
+		// key is a "folder" so redirect to key/ so relative links work
+		if obj.get(b, c.indexKey(key+"/")) == nil {
+			return nil
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		s.websiteRedirect(r, &websiteRedirectTo{Code: http.StatusFound, Key: key + "/"}, bucketName)
+		return nil
+	}
+
+	if location := obj.Metadata[websiteRedirectLocation]; location != "" {
+		if strings.HasPrefix(location, "/") {
+			location = s.websiteURL(r.Request(), "", "", bucketName, location[1:])
+		}
+		r.Status(http.StatusMovedPermanently).
+			AddHeader("Location", location)
+		return nil
+	}
+
+	if r.Request().Method == http.MethodHead {
+		return s.headObject(r, bucketName, key, 200)
+	}
+	return s.getObject(r, bucketName, key, 200)
+}
+
+// websiteRedirectTo is where a request is redirected to
+type websiteRedirectTo struct {
+	Code     int
+	Protocol string
+	HostName string
+	Key      string
+}
+
+// redirect returns where the first routing rule matching a key redirects it
+// to, nil if no rule matches.
+// status is the http status of the request or 0 before the object has been looked up.
+func (c *WebsiteConfiguration) redirect(key string, status int) *websiteRedirectTo {
+	for _, rule := range c.RoutingRules {
+		if !rule.matches(key, status) {
+			continue
+		}
+
+		d := rule.Redirect
+		if d.ReplaceKeyWith != "" {
+			key = d.ReplaceKeyWith
+		} else if d.ReplaceKeyPrefixWith != nil {
+			prefix := ""
+			if rule.Condition != nil {
+				prefix = rule.Condition.KeyPrefixEquals
+			}
+			key = *d.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+		}
+
+		code := http.StatusMovedPermanently
+		if d.HttpRedirectCode != "" {
+			code, _ = strconv.Atoi(d.HttpRedirectCode)
+		}
+
+		return &websiteRedirectTo{code, d.Protocol, d.HostName, key}
+	}
+
+	return nil
+}
+
+// websiteError sends an error for a website request.
+// The error is either redirected by a routing rule, the configured error
+// document or a generated html page.
+func (s *ObjectStore) websiteError(r *rest.Rest, c *WebsiteConfiguration, bucketName, key string, err error) error {
+	e := awserror.ToError(err)
+
+	if to := c.redirect(key, e.Status); to != nil {
+		s.websiteRedirect(r, to, bucketName)
+		return nil
+	}
+
+	if d := c.ErrorDocument; d != nil && s.authorizeRequest(r, actionGetObject, bucketName, d.Key) == nil {
+		if r.Request().Method == http.MethodHead {
+			err = s.headObject(r, bucketName, d.Key, e.Status)
+		} else {
+			err = s.getObject(r, bucketName, d.Key, e.Status)
+		}
+		if err == nil {
+			return nil
+		}
+	}
+
+	r.Status(e.Status).
+		ContentType("text/html; charset=utf-8").
+		Reader(strings.NewReader(websiteErrorPage(e, key)))
+
+	return nil
+}
+
+// websiteErrorPage returns the html page of an error when there's no error document
+func websiteErrorPage(e *awserror.Error, key string) string {
+	status := fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status))
+
+	var b strings.Builder
+	b.WriteString("<html>\n<head><title>" + status + "</title></head>\n<body>\n")
+	b.WriteString("<h1>" + status + "</h1>\n<ul>\n")
+	b.WriteString("<li>Code: " + html.EscapeString(e.Code) + "</li>\n")
+	b.WriteString("<li>Message: " + html.EscapeString(e.Message) + "</li>\n")
+	if key != "" {
+		b.WriteString("<li>Key: " + html.EscapeString(key) + "</li>\n")
+	}
+	b.WriteString("</ul>\n<hr/>\n</body>\n</html>\n")
+	return b.String()
+}
+
+// websiteRedirect sends a redirect
+func (s *ObjectStore) websiteRedirect(r *rest.Rest, to *websiteRedirectTo, bucketName string) {
+	r.Status(to.Code).
+		AddHeader("Location", s.websiteURL(r.Request(), to.Protocol, to.HostName, bucketName, to.Key))
+}
+
+// websiteURL returns the url of a key. If hostName is "" then the url is for
+// this bucket, otherwise the key is on that host.
+func (s *ObjectStore) websiteURL(req *http.Request, protocol, hostName, bucketName, key string) string {
+	if protocol == "" {
+		protocol = "http"
+		if req.TLS != nil {
+			protocol = "https"
+		}
+	}
+
+	path := "/" + key
+	if hostName == "" {
+		hostName = req.Host
+		// Path style requests have the bucket in the path
+		if s.virtualHostBucket(hostName) == "" {
+			path = "/" + bucketName + path
+		}
+	}
+
+	return protocol + "://" + hostName + path
+}
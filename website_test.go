@@ -0,0 +1,170 @@
+package objectstore
+
+import (
+	"crypto/tls"
+	"encoding/xml"
+	"github.com/peter-mount/objectstore/awserror"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testWebsiteConfig is based on the routing rule examples in
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/how-to-page-redirect.html
+const testWebsiteConfig = `<WebsiteConfiguration>
+  <IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+  <ErrorDocument><Key>error.html</Key></ErrorDocument>
+  <RoutingRules>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+      <Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>images/</KeyPrefixEquals></Condition>
+      <Redirect><ReplaceKeyWith>folderdeleted.html</ReplaceKeyWith><HttpRedirectCode>302</HttpRedirectCode></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>
+      <Redirect><HostName>example.com</HostName><Protocol>https</Protocol><ReplaceKeyPrefixWith>report-404/</ReplaceKeyPrefixWith></Redirect>
+    </RoutingRule>
+  </RoutingRules>
+</WebsiteConfiguration>`
+
+func testWebsiteConfiguration(t *testing.T, body string) *WebsiteConfiguration {
+	c := &WebsiteConfiguration{}
+	if err := xml.Unmarshal([]byte(body), c); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestWebsiteConfiguration_validate(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		body string
+		code string
+	}{
+		{name: "example", body: testWebsiteConfig},
+		{name: "redirect all", body: `<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`},
+		{name: "redirect all and index", body: `<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>`, code: "InvalidArgument"},
+		{name: "redirect all bad protocol", body: `<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>ftp</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>`, code: "InvalidArgument"},
+		{name: "no index", body: `<WebsiteConfiguration></WebsiteConfiguration>`, code: "InvalidArgument"},
+		{name: "index with /", body: `<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`, code: "InvalidArgument"},
+		{name: "empty condition", body: `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`, code: "InvalidArgument"},
+		{name: "bad error code", body: `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition><HttpErrorCodeReturnedEquals>200</HttpErrorCodeReturnedEquals></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`, code: "InvalidArgument"},
+		{name: "bad redirect code", body: `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`, code: "InvalidArgument"},
+		{name: "replace key & prefix", body: `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><ReplaceKeyWith>a</ReplaceKeyWith><ReplaceKeyPrefixWith>b</ReplaceKeyPrefixWith></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`, code: "InvalidArgument"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := testWebsiteConfiguration(t, test.body).validate()
+			switch {
+			case test.code == "" && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case test.code != "" && err == nil:
+				t.Errorf("expected %s", test.code)
+			case test.code != "" && awserror.ToError(err).Code != test.code:
+				t.Errorf("got %s expected %s", awserror.ToError(err).Code, test.code)
+			}
+		})
+	}
+}
+
+func TestWebsiteConfiguration_indexKey(t *testing.T) {
+	c := testWebsiteConfiguration(t, testWebsiteConfig)
+
+	for _, test := range []struct {
+		key  string
+		want string
+	}{
+		{key: "", want: "index.html"},
+		{key: "blog/", want: "blog/index.html"},
+		{key: "blog", want: "blog"},
+		{key: "blog/post.html", want: "blog/post.html"},
+	} {
+		if got := c.indexKey(test.key); got != test.want {
+			t.Errorf("%q got %q want %q", test.key, got, test.want)
+		}
+	}
+}
+
+func TestWebsiteConfiguration_redirect(t *testing.T) {
+	c := testWebsiteConfiguration(t, testWebsiteConfig)
+
+	for _, test := range []struct {
+		name   string
+		key    string
+		status int
+		want   *websiteRedirectTo
+	}{
+		{name: "no rule", key: "index.html"},
+		{name: "replace prefix", key: "docs/a.html", want: &websiteRedirectTo{Code: 301, Key: "documents/a.html"}},
+		{name: "replace key", key: "images/a.png", want: &websiteRedirectTo{Code: 302, Key: "folderdeleted.html"}},
+		// Rules with a key condition only apply before the object is looked up
+		{name: "prefix after lookup", key: "docs/a.html", status: 404, want: &websiteRedirectTo{Code: 301, Protocol: "https", HostName: "example.com", Key: "report-404/docs/a.html"}},
+		{name: "error code", key: "missing.html", status: 404, want: &websiteRedirectTo{Code: 301, Protocol: "https", HostName: "example.com", Key: "report-404/missing.html"}},
+		{name: "other error code", key: "private.html", status: 403},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := c.redirect(test.key, test.status)
+			switch {
+			case test.want == nil && got != nil:
+				t.Errorf("unexpected redirect %+v", got)
+			case test.want != nil && got == nil:
+				t.Errorf("expected redirect %+v", test.want)
+			case test.want != nil && *got != *test.want:
+				t.Errorf("got %+v want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestObjectStore_websiteURL(t *testing.T) {
+	s := &ObjectStore{domains: []string{"example.com"}}
+
+	for _, test := range []struct {
+		name     string
+		url      string
+		tls      bool
+		protocol string
+		hostName string
+		want     string
+	}{
+		{name: "path style", url: "http://localhost:8080/bucket/a", want: "http://localhost:8080/bucket/docs/a.html"},
+		{name: "virtual host", url: "http://bucket.example.com/a", want: "http://bucket.example.com/docs/a.html"},
+		{name: "tls", url: "https://bucket.example.com/a", tls: true, want: "https://bucket.example.com/docs/a.html"},
+		{name: "protocol", url: "http://bucket.example.com/a", protocol: "https", want: "https://bucket.example.com/docs/a.html"},
+		{name: "other host", url: "http://localhost:8080/bucket/a", hostName: "example.org", want: "http://example.org/docs/a.html"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			if test.tls {
+				req.TLS = &tls.ConnectionState{}
+			} else {
+				req.TLS = nil
+			}
+
+			if got := s.websiteURL(req, test.protocol, test.hostName, "bucket", "docs/a.html"); got != test.want {
+				t.Errorf("got %q want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWebsiteErrorPage(t *testing.T) {
+	page := websiteErrorPage(awserror.NoSuchKey(), "<script>.html")
+
+	for _, want := range []string{
+		"<title>404 Not Found</title>",
+		"<li>Code: NoSuchKey</li>",
+		"<li>Key: &lt;script&gt;.html</li>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q\n%s", want, page)
+		}
+	}
+
+	if page = websiteErrorPage(awserror.AccessDenied(), ""); strings.Contains(page, "Key:") {
+		t.Errorf("page has a key\n%s", page)
+	}
+}